@@ -0,0 +1,253 @@
+// Package bridge forwards the logs emitted by EVM contracts to a Keystone game
+// engine. Logs are decoded with registered contract ABIs, matched against
+// routing rules and delivered, in the order they were emitted, as Go callbacks
+// or as system inputs queued for the next game tick.
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/curio-research/keystone/server"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var errUnknownEvent = errors.New("bridge: no registered ABI describes this event")
+
+// Event is an EVM log as delivered to the game engine.
+type Event struct {
+	Seq         uint64                 `json:"seq"`         // Position of the event in the bridge's delivery order
+	Address     common.Address         `json:"address"`     // Contract that emitted the log
+	Name        string                 `json:"name"`        // Event name from the registered ABI, empty if unknown
+	Args        map[string]interface{} `json:"args"`        // Decoded event arguments, nil if unknown
+	Topics      []common.Hash          `json:"topics"`      // Raw log topics
+	Data        []byte                 `json:"data"`        // Raw log data
+	BlockNumber uint64                 `json:"blockNumber"` // Block the log was emitted in
+	TxHash      common.Hash            `json:"txHash"`      // Transaction that emitted the log
+	LogIndex    uint                   `json:"logIndex"`    // Index of the log in the block
+
+	abiEvent *abi.Event
+}
+
+// Decode unpacks the event arguments into out, which must be a pointer to a
+// struct whose fields follow the go-ethereum abi naming rules.
+func (ev Event) Decode(out interface{}) error {
+	if ev.abiEvent == nil {
+		return errUnknownEvent
+	}
+	if len(ev.Data) > 0 {
+		values, err := ev.abiEvent.Inputs.Unpack(ev.Data)
+		if err != nil {
+			return err
+		}
+		if err := ev.abiEvent.Inputs.Copy(out, values); err != nil {
+			return err
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range ev.abiEvent.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if len(indexed) == 0 {
+		return nil
+	}
+	return abi.ParseTopics(out, indexed, ev.Topics[1:])
+}
+
+// Target receives the events matched by a route.
+type Target interface {
+	Deliver(ctx *server.EngineCtx, ev Event) error
+}
+
+// HandlerFunc delivers events by calling a Go function on the game tick.
+type HandlerFunc func(ctx *server.EngineCtx, ev Event) error
+
+// Deliver implements Target.
+func (f HandlerFunc) Deliver(ctx *server.EngineCtx, ev Event) error {
+	return f(ctx, ev)
+}
+
+// SystemInput returns a target that queues events as Keystone transactions for
+// the next tick. Systems created with server.CreateSystemFromRequestHandler[T]
+// receive them like any other request. T is either Event itself or a struct
+// the event arguments are decoded into.
+func SystemInput[T any]() Target {
+	return HandlerFunc(func(ctx *server.EngineCtx, ev Event) error {
+		var req T
+		if p, ok := any(&req).(*Event); ok {
+			*p = ev
+		} else if err := ev.Decode(&req); err != nil {
+			return err
+		}
+		tx := server.NewKeystoneTx(req, nil)
+		return server.QueueTxFromInternal(ctx.World, ctx.GameTick.TickNumber+1, tx, fmt.Sprint(ev.Seq))
+	})
+}
+
+// Route selects the logs delivered to a target.
+type Route struct {
+	Address *common.Address // Emitting contract to match, nil matches any contract
+	Topic   *common.Hash    // First topic (event ID) to match, nil matches any event
+	Target  Target          // Receiver of the matching events
+}
+
+func (r *Route) matches(log *types.Log) bool {
+	if r.Address != nil && *r.Address != log.Address {
+		return false
+	}
+	if r.Topic != nil && (len(log.Topics) == 0 || log.Topics[0] != *r.Topic) {
+		return false
+	}
+	return true
+}
+
+// delivery is an event waiting to be handed to a target.
+type delivery struct {
+	event  Event
+	target Target
+}
+
+// Bridge decodes and routes EVM logs to the game engine. Logs are queued as
+// they are emitted and delivered when the bridge is flushed, normally once per
+// game tick. A failed delivery stays at the head of the queue and is retried on
+// the next flush, so targets see every event at least once and in order.
+//
+// Bridge implements core.LogHandler and is safe for concurrent use.
+type Bridge struct {
+	flushMu sync.Mutex // serializes flushes so deliveries stay ordered
+
+	mu      sync.Mutex
+	abis    map[common.Address]*abi.ABI
+	routes  []Route
+	pending []delivery
+	seq     uint64
+}
+
+// New creates a bridge with the given routes.
+func New(routes ...Route) *Bridge {
+	return &Bridge{
+		abis:   make(map[common.Address]*abi.ABI),
+		routes: routes,
+	}
+}
+
+// RegisterABI sets the ABI used to decode the logs of the contract at addr.
+func (b *Bridge) RegisterABI(addr common.Address, contractABI abi.ABI) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.abis[addr] = &contractABI
+}
+
+// AddRoute adds a routing rule. Events are delivered to every matching route.
+func (b *Bridge) AddRoute(r Route) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.routes = append(b.routes, r)
+}
+
+// HandleLogs decodes and queues the logs of an executed transaction.
+func (b *Bridge) HandleLogs(logs []*types.Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, log := range logs {
+		var ev *Event
+		for i := range b.routes {
+			route := &b.routes[i]
+			if !route.matches(log) {
+				continue
+			}
+			if ev == nil {
+				ev = b.decode(log)
+			}
+			b.pending = append(b.pending, delivery{event: *ev, target: route.Target})
+		}
+	}
+}
+
+// decode turns a log into an event, decoding its arguments when the emitting
+// contract has a registered ABI.
+func (b *Bridge) decode(log *types.Log) *Event {
+	ev := &Event{
+		Seq:         b.seq,
+		Address:     log.Address,
+		Topics:      log.Topics,
+		Data:        log.Data,
+		BlockNumber: log.BlockNumber,
+		TxHash:      log.TxHash,
+		LogIndex:    log.Index,
+	}
+	b.seq++
+
+	contractABI, ok := b.abis[log.Address]
+	if !ok || len(log.Topics) == 0 {
+		return ev
+	}
+	abiEvent, err := contractABI.EventByID(log.Topics[0])
+	if err != nil {
+		return ev
+	}
+	args := make(map[string]interface{})
+	if err := contractABI.UnpackIntoMap(args, abiEvent.Name, log.Data); err != nil {
+		return ev
+	}
+	var indexed abi.Arguments
+	for _, arg := range abiEvent.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:]); err != nil {
+		return ev
+	}
+	ev.Name, ev.Args, ev.abiEvent = abiEvent.Name, args, abiEvent
+	return ev
+}
+
+// Flush delivers the queued events in order. It stops at the first failed
+// delivery, leaving it and every later event queued for the next flush.
+func (b *Bridge) Flush(ctx *server.EngineCtx) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		// targets run without holding the queue lock, since they may execute
+		// transactions that emit further logs.
+		b.mu.Lock()
+		if len(b.pending) == 0 {
+			b.mu.Unlock()
+			return nil
+		}
+		d := b.pending[0]
+		b.mu.Unlock()
+
+		if err := d.target.Deliver(ctx, d.event); err != nil {
+			return fmt.Errorf("bridge: delivering event %d: %w", d.event.Seq, err)
+		}
+
+		b.mu.Lock()
+		b.pending[0] = delivery{}
+		b.pending = b.pending[1:]
+		b.mu.Unlock()
+	}
+}
+
+// Pending returns the number of queued deliveries.
+func (b *Bridge) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.pending)
+}
+
+// Attach schedules the bridge to be flushed on every tick of the engine.
+func (b *Bridge) Attach(ctx *server.EngineCtx) {
+	ctx.AddSystem(ctx.GameTick.TickRateMs, b.Flush)
+}
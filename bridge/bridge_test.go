@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/curio-research/keystone/server"
+	"github.com/curio-research/keystone/state"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tradeABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"player","type":"address"},{"indexed":false,"name":"amount","type":"uint256"}],"name":"Trade","type":"event"}]`
+
+var (
+	market = common.HexToAddress("0xabcd")
+	player = common.HexToAddress("0x1234")
+)
+
+func newEngine() *server.EngineCtx {
+	world := state.NewWorld()
+	server.RegisterDefaultTables(world)
+	return &server.EngineCtx{
+		World:    world,
+		GameTick: server.NewGameTick(server.DefaultTickRate),
+	}
+}
+
+func tradeLog(t *testing.T, parsed abi.ABI, amount int64) *types.Log {
+	data, err := parsed.Events["Trade"].Inputs.NonIndexed().Pack(big.NewInt(amount))
+	require.NoError(t, err)
+	return &types.Log{
+		Address: market,
+		Topics:  []common.Hash{parsed.Events["Trade"].ID, common.BytesToHash(player.Bytes())},
+		Data:    data,
+	}
+}
+
+func TestBridgeDecodesAndRoutes(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(tradeABI))
+	require.NoError(t, err)
+
+	var got []Event
+	topic := parsed.Events["Trade"].ID
+	b := New(Route{
+		Address: &market,
+		Topic:   &topic,
+		Target: HandlerFunc(func(ctx *server.EngineCtx, ev Event) error {
+			got = append(got, ev)
+			return nil
+		}),
+	})
+	b.RegisterABI(market, parsed)
+
+	other := tradeLog(t, parsed, 1)
+	other.Address = common.HexToAddress("0xdead")
+	b.HandleLogs([]*types.Log{tradeLog(t, parsed, 7), other})
+	require.Equal(t, 1, b.Pending())
+
+	require.NoError(t, b.Flush(newEngine()))
+	require.Len(t, got, 1)
+	assert.Equal(t, "Trade", got[0].Name)
+	assert.Equal(t, big.NewInt(7), got[0].Args["amount"])
+	assert.Equal(t, player, got[0].Args["player"])
+
+	var trade struct {
+		Player common.Address
+		Amount *big.Int
+	}
+	require.NoError(t, got[0].Decode(&trade))
+	assert.Equal(t, player, trade.Player)
+	assert.Equal(t, big.NewInt(7), trade.Amount)
+}
+
+func TestBridgeRetriesInOrder(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(tradeABI))
+	require.NoError(t, err)
+
+	var (
+		seen []uint64
+		fail = true
+	)
+	b := New(Route{Target: HandlerFunc(func(ctx *server.EngineCtx, ev Event) error {
+		if ev.Seq == 1 && fail {
+			fail = false
+			return errors.New("engine busy")
+		}
+		seen = append(seen, ev.Seq)
+		return nil
+	})})
+	b.HandleLogs([]*types.Log{tradeLog(t, parsed, 1), tradeLog(t, parsed, 2), tradeLog(t, parsed, 3)})
+
+	engine := newEngine()
+	assert.Error(t, b.Flush(engine))
+	assert.Equal(t, []uint64{0}, seen)
+	assert.Equal(t, 2, b.Pending())
+
+	require.NoError(t, b.Flush(engine))
+	assert.Equal(t, []uint64{0, 1, 2}, seen)
+	assert.Equal(t, 0, b.Pending())
+}
+
+func TestBridgeSystemInput(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(tradeABI))
+	require.NoError(t, err)
+
+	type trade struct {
+		Player common.Address
+		Amount *big.Int
+	}
+	b := New(Route{Address: &market, Target: SystemInput[trade]()})
+	b.RegisterABI(market, parsed)
+	b.HandleLogs([]*types.Log{tradeLog(t, parsed, 5)})
+
+	engine := newEngine()
+	require.NoError(t, b.Flush(engine))
+
+	engine.GameTick.TickNumber++
+	ids := server.GetSystemTransactionsOfType[server.KeystoneTx[trade]](engine)
+	require.Len(t, ids, 1)
+	req := server.DecodeTxData[trade](engine, ids[0])
+	assert.Equal(t, player, req.Data.Player)
+	assert.Equal(t, big.NewInt(5), req.Data.Amount)
+}
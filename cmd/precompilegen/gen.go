@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// gasDirective sets the gas charged for a method, in its doc comment:
+//
+//	//gevm:gas 200
+const gasDirective = "//gevm:gas"
+
+// abiType is a Go type that can be passed to and returned from a precompile.
+type abiType struct {
+	Go     string // Go type, as written in the interface
+	ABI    string // Solidity ABI type
+	Unpack string // Go type produced by abi.Arguments.Unpack
+	Import string // package the Go type needs, if any
+}
+
+// Memory reports whether the type is passed in memory in Solidity.
+func (t abiType) Memory() bool {
+	return t.ABI == "bytes" || t.ABI == "string"
+}
+
+// Convert returns the expression converting an unpacked argument to t.
+func (t abiType) Convert(arg string) string {
+	if t.Go == t.Unpack {
+		return fmt.Sprintf("%s.(%s)", arg, t.Go)
+	}
+	return fmt.Sprintf("%s(%s.(%s))", t.Go, arg, t.Unpack)
+}
+
+var basicTypes = map[string]abiType{
+	"bool":           {Go: "bool", ABI: "bool", Unpack: "bool"},
+	"string":         {Go: "string", ABI: "string", Unpack: "string"},
+	"uint8":          {Go: "uint8", ABI: "uint8", Unpack: "uint8"},
+	"uint16":         {Go: "uint16", ABI: "uint16", Unpack: "uint16"},
+	"uint32":         {Go: "uint32", ABI: "uint32", Unpack: "uint32"},
+	"uint64":         {Go: "uint64", ABI: "uint64", Unpack: "uint64"},
+	"int8":           {Go: "int8", ABI: "int8", Unpack: "int8"},
+	"int16":          {Go: "int16", ABI: "int16", Unpack: "int16"},
+	"int32":          {Go: "int32", ABI: "int32", Unpack: "int32"},
+	"int64":          {Go: "int64", ABI: "int64", Unpack: "int64"},
+	"[]byte":         {Go: "[]byte", ABI: "bytes", Unpack: "[]byte"},
+	"[32]byte":       {Go: "[32]byte", ABI: "bytes32", Unpack: "[32]byte"},
+	"common.Address": {Go: "common.Address", ABI: "address", Unpack: "common.Address", Import: "github.com/ethereum/go-ethereum/common"},
+	"common.Hash":    {Go: "common.Hash", ABI: "bytes32", Unpack: "[32]byte", Import: "github.com/ethereum/go-ethereum/common"},
+	"*big.Int":       {Go: "*big.Int", ABI: "uint256", Unpack: "*big.Int", Import: "math/big"},
+}
+
+type arg struct {
+	Name string
+	Type abiType
+}
+
+type method struct {
+	Name    string   // Go method name
+	ABIName string   // Solidity function name
+	Doc     []string // doc comment lines, without directives
+	Gas     uint64
+	Inputs  []arg
+	Outputs []arg
+	Error   bool // whether the last result is an error
+}
+
+// Signature returns the canonical signature the selector is computed from.
+func (m method) Signature() string {
+	types := make([]string, len(m.Inputs))
+	for i, in := range m.Inputs {
+		types[i] = in.Type.ABI
+	}
+	return fmt.Sprintf("%s(%s)", m.ABIName, strings.Join(types, ","))
+}
+
+// contract is a precompile described by a Go interface, or by the exported
+// methods of a Go type.
+type contract struct {
+	Package  string
+	Name     string
+	Impl     string // Go type of the implementation, a pointer for pointer receivers
+	Address  common.Address
+	External bool
+	Methods  []method
+}
+
+// config holds the generator options that don't come from the source.
+type config struct {
+	Type     string
+	Address  common.Address
+	External bool
+	Gas      uint64 // gas of methods without a gas directive
+}
+
+// parse reads the type cfg.Type from a Go source file: an interface, whose
+// methods make the precompile, or a named type, such as a struct, whose
+// exported methods declared in the same file do.
+func parse(filename string, src []byte, cfg config) (*contract, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var spec *ast.TypeSpec
+	ast.Inspect(file, func(n ast.Node) bool {
+		if s, ok := n.(*ast.TypeSpec); ok && s.Name.Name == cfg.Type {
+			spec = s
+		}
+		return spec == nil
+	})
+	if spec == nil {
+		return nil, fmt.Errorf("type %s not found in %s", cfg.Type, filename)
+	}
+
+	c := &contract{
+		Package:  file.Name.Name,
+		Name:     cfg.Type,
+		Impl:     cfg.Type,
+		Address:  cfg.Address,
+		External: cfg.External,
+	}
+	if iface, ok := spec.Type.(*ast.InterfaceType); ok {
+		for _, field := range iface.Methods.List {
+			fn, ok := field.Type.(*ast.FuncType)
+			if !ok || len(field.Names) != 1 {
+				return nil, fmt.Errorf("%s: embedded interfaces are not supported", cfg.Type)
+			}
+			m, err := parseMethod(field.Names[0].Name, fn, field.Doc, cfg.Gas)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", cfg.Type, field.Names[0].Name, err)
+			}
+			c.Methods = append(c.Methods, m)
+		}
+	} else {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || !fn.Name.IsExported() {
+				continue
+			}
+			recv := typeString(fn.Recv.List[0].Type)
+			if recv != cfg.Type && recv != "*"+cfg.Type {
+				continue
+			}
+			if recv != cfg.Type {
+				// the methods of the pointer include those of the value
+				c.Impl = recv
+			}
+			m, err := parseMethod(fn.Name.Name, fn.Type, fn.Doc, cfg.Gas)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", cfg.Type, fn.Name.Name, err)
+			}
+			c.Methods = append(c.Methods, m)
+		}
+	}
+	if len(c.Methods) == 0 {
+		return nil, fmt.Errorf("%s has no methods", cfg.Type)
+	}
+	return c, nil
+}
+
+func parseMethod(name string, fn *ast.FuncType, doc *ast.CommentGroup, gas uint64) (method, error) {
+	m := method{Name: name, ABIName: lowerFirst(name), Gas: gas}
+	if doc != nil {
+		for _, comment := range doc.List {
+			if rest, ok := strings.CutPrefix(comment.Text, gasDirective); ok {
+				g, err := strconv.ParseUint(strings.TrimSpace(rest), 10, 64)
+				if err != nil {
+					return m, fmt.Errorf("invalid gas directive %q", comment.Text)
+				}
+				m.Gas = g
+				continue
+			}
+			line := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if len(m.Doc) == 0 && strings.HasPrefix(line, name+" ") {
+				// the Solidity function is named in lower camel case
+				line = m.ABIName + strings.TrimPrefix(line, name)
+			}
+			m.Doc = append(m.Doc, line)
+		}
+	}
+
+	var err error
+	if m.Inputs, err = parseArgs(fn.Params, "arg"); err != nil {
+		return m, err
+	}
+	if fn.Results != nil {
+		results := fn.Results.List
+		if last := results[len(results)-1]; typeString(last.Type) == "error" {
+			if len(last.Names) > 1 {
+				return m, errors.New("only the last result may be an error")
+			}
+			m.Error = true
+			results = results[:len(results)-1]
+		}
+		if m.Outputs, err = parseArgs(&ast.FieldList{List: results}, ""); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// parseArgs converts a parameter list. Unnamed parameters are named with
+// prefix and their position, if a prefix is given.
+func parseArgs(fields *ast.FieldList, prefix string) ([]arg, error) {
+	var args []arg
+	for _, field := range fields.List {
+		t, ok := basicTypes[typeString(field.Type)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %s", typeString(field.Type))
+		}
+		if len(field.Names) == 0 {
+			name := ""
+			if prefix != "" {
+				name = fmt.Sprintf("%s%d", prefix, len(args))
+			}
+			args = append(args, arg{Name: name, Type: t})
+		}
+		for _, n := range field.Names {
+			args = append(args, arg{Name: n.Name, Type: t})
+		}
+	}
+	return args, nil
+}
+
+func typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + typeString(t.Elt)
+		}
+		return "[" + typeString(t.Len) + "]" + typeString(t.Elt)
+	case *ast.BasicLit:
+		return t.Value
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// ABI returns the JSON ABI of the contract.
+func (c *contract) ABI() (string, error) {
+	type param struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	type function struct {
+		Type            string  `json:"type"`
+		Name            string  `json:"name"`
+		Inputs          []param `json:"inputs"`
+		Outputs         []param `json:"outputs"`
+		StateMutability string  `json:"stateMutability"`
+	}
+	params := func(args []arg) []param {
+		ps := make([]param, len(args))
+		for i, a := range args {
+			ps[i] = param{Name: a.Name, Type: a.Type.ABI}
+		}
+		return ps
+	}
+	fns := make([]function, len(c.Methods))
+	for i, m := range c.Methods {
+		fns[i] = function{
+			Type:            "function",
+			Name:            m.ABIName,
+			Inputs:          params(m.Inputs),
+			Outputs:         params(m.Outputs),
+			StateMutability: "view",
+		}
+	}
+	out, err := json.Marshal(fns)
+	return string(out), err
+}
+
+// imports returns the packages the generated Go code needs.
+func (c *contract) imports() []string {
+	imports := map[string]bool{
+		"errors":  true,
+		"strings": true,
+		"github.com/ethereum/go-ethereum/accounts/abi": true,
+		"github.com/ethereum/go-ethereum/common":       true,
+	}
+	for _, m := range c.Methods {
+		for _, a := range append(append([]arg{}, m.Inputs...), m.Outputs...) {
+			if a.Type.Import != "" {
+				imports[a.Type.Import] = true
+			}
+		}
+	}
+	var std, other []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	return append(append(std, ""), other...)
+}
+
+// shortAddress returns the address without leading zeros, like 0x0c.
+func shortAddress(addr common.Address) string {
+	hex := new(big.Int).SetBytes(addr.Bytes()).Text(16)
+	if len(hex)%2 == 1 {
+		hex = "0" + hex
+	}
+	return "0x" + hex
+}
+
+// solidityAddress returns the address as a Solidity literal.
+func solidityAddress(addr common.Address) string {
+	if short := shortAddress(addr); len(short) < 2+2*common.AddressLength {
+		return "address(" + short + ")"
+	}
+	return addr.Hex()
+}
+
+var funcs = template.FuncMap{
+	"lower":   lowerFirst,
+	"quote":   strconv.Quote,
+	"solAddr": solidityAddress,
+	"short":   shortAddress,
+	"raw":     func(s string) string { return "`" + s + "`" },
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(funcs).Parse(`// Code generated by precompilegen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{if .}}{{quote .}}{{end}}
+{{- end}}
+)
+
+// {{.Name}}Address is the address the {{.Name}} precompile is registered at.
+var {{.Name}}Address = common.HexToAddress({{quote .Address.Hex}})
+
+// {{.Name}}ABI is the ABI of the {{.Name}} precompile.
+const {{.Name}}ABI = {{raw .ABI}}
+
+var {{lower .Name}}ParsedABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader({{.Name}}ABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// {{.Name}}Gas holds optional gas hooks of the {{.Name}} precompile, one per
+// method. A hook returns the gas charged for a call with the given arguments;
+// methods without a hook are charged their declared gas.
+type {{.Name}}Gas struct {
+{{- range .Methods}}
+	{{.Name}} func({{range $i, $in := .Inputs}}{{if $i}}, {{end}}{{$in.Name}} {{$in.Type.Go}}{{end}}) uint64
+{{- end}}
+}
+
+// {{.Name}}Precompile runs a {{.Name}} implementation as a precompiled contract.
+type {{.Name}}Precompile struct {
+	Impl {{.Impl}}
+	Gas  {{.Name}}Gas
+}
+{{if .External}}
+// ReadsExternalState marks the precompile as reading state outside of the chain.
+func (p *{{.Name}}Precompile) ReadsExternalState() {}
+{{end}}
+// RequiredGas returns the gas charged for the call encoded in input.
+func (p *{{.Name}}Precompile) RequiredGas(input []byte) uint64 {
+	method, args, err := {{lower .Name}}Decode(input)
+	if err != nil {
+		return 0
+	}
+	switch method.Name {
+{{- range .Methods}}
+	case {{quote .ABIName}}:
+		if p.Gas.{{.Name}} != nil {
+			return p.Gas.{{.Name}}({{range $i, $in := .Inputs}}{{if $i}}, {{end}}{{$in.Type.Convert (printf "args[%d]" $i)}}{{end}})
+		}
+		return {{.Gas}}
+{{- end}}
+	}
+	return 0
+}
+
+// Run decodes the call encoded in input, calls the implementation and encodes
+// its results.
+func (p *{{.Name}}Precompile) Run(input []byte) ([]byte, error) {
+	method, args, err := {{lower .Name}}Decode(input)
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+{{- range .Methods}}
+	case {{quote .ABIName}}:
+		{{range $i, $out := .Outputs}}{{if $i}}, {{end}}r{{$i}}{{end}}{{if .Error}}{{if .Outputs}}, {{end}}err{{end}}{{if or .Outputs .Error}} {{if .Outputs}}:{{end}}= {{end}}p.Impl.{{.Name}}({{range $i, $in := .Inputs}}{{if $i}}, {{end}}{{$in.Type.Convert (printf "args[%d]" $i)}}{{end}})
+		{{- if .Error}}
+		if err != nil {
+			return nil, err
+		}
+		{{- end}}
+		return method.Outputs.Pack({{range $i, $out := .Outputs}}{{if $i}}, {{end}}r{{$i}}{{end}})
+{{- end}}
+	}
+	return nil, errors.New({{quote (printf "%s: unknown method" (lower .Name))}})
+}
+
+func {{lower .Name}}Decode(input []byte) (*abi.Method, []interface{}, error) {
+	if len(input) < 4 {
+		return nil, nil, errors.New({{quote (printf "%s: input too short" (lower .Name))}})
+	}
+	method, err := {{lower .Name}}ParsedABI.MethodById(input[:4])
+	if err != nil {
+		return nil, nil, err
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return method, args, nil
+}
+`))
+
+var solTemplate = template.Must(template.New("sol").Funcs(funcs).Parse(`// Code generated by precompilegen. DO NOT EDIT.
+
+pragma solidity ^0.6.0;
+
+// {{.Name}} calls the {{.Name}} precompile at {{short .Address}}.
+library {{.Name}} {
+
+    address constant PRECOMPILE = {{solAddr .Address}};
+{{range .Methods}}
+{{range .Doc}}    // {{.}}
+{{end}}    function {{.ABIName}}({{range $i, $in := .Inputs}}{{if $i}}, {{end}}{{$in.Type.ABI}}{{if $in.Type.Memory}} memory{{end}} {{$in.Name}}{{end}}) internal view{{if .Outputs}} returns ({{range $i, $out := .Outputs}}{{if $i}}, {{end}}{{$out.Type.ABI}}{{if $out.Type.Memory}} memory{{end}}{{if $out.Name}} {{$out.Name}}{{end}}{{end}}){{end}} {
+        (bool success, {{if .Outputs}}bytes memory out{{end}}) = PRECOMPILE.staticcall(abi.encodeWithSignature({{quote .Signature}}{{range .Inputs}}, {{.Name}}{{end}}));
+        require(success, {{quote (printf "%s: %s failed" $.Name .ABIName)}});
+{{- if .Outputs}}
+        return abi.decode(out, ({{range $i, $out := .Outputs}}{{if $i}}, {{end}}{{$out.Type.ABI}}{{end}}));
+{{- end}}
+    }
+{{end}}}
+`))
+
+// Go returns the generated Go code, formatted.
+func (c *contract) Go() ([]byte, error) {
+	abiJSON, err := c.ABI()
+	if err != nil {
+		return nil, err
+	}
+	data := struct {
+		*contract
+		ABI     string
+		Imports []string
+	}{c, abiJSON, c.imports()}
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return out, nil
+}
+
+// Solidity returns the generated Solidity library.
+func (c *contract) Solidity() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := solTemplate.Execute(&buf, c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestGeneratedStats checks that the generated stats example is up to date.
+func TestGeneratedStats(t *testing.T) {
+	const dir = "../../examples/precompile/stats/"
+	src, err := os.ReadFile(dir + "stats.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parse("stats.go", src, config{
+		Type:     "Stats",
+		Address:  common.BytesToAddress([]byte{0x0d}),
+		External: true,
+		Gas:      100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := c.Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol, err := c.Solidity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, have := range map[string][]byte{"stats_precompile.go": code, "Stats.sol": sol} {
+		want, err := os.ReadFile(dir + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != string(want) {
+			t.Errorf("%s is out of date, run go generate in %s", file, dir)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"type Stats interface{ Health(player int) uint64 }", "unsupported type int"},
+		{"type Stats interface{ Health() (error, uint64) }", "unsupported type error"},
+		{"type Stats interface{ io.Reader }", "embedded interfaces are not supported"},
+		{"type Stats interface{\n//gevm:gas many\nHealth() uint64 }", "invalid gas directive"},
+		{"type Stats interface{}", "has no methods"},
+		{"type Other interface{ Health() uint64 }", "type Stats not found"},
+		{"type Stats struct{}\nfunc (s Stats) health() uint64 { return 0 }", "has no methods"},
+		{"type Stats struct{}\nfunc (s Stats) Health(player int) uint64 { return 0 }", "Stats.Health: unsupported type int"},
+	}
+	for _, test := range tests {
+		_, err := parse("stats.go", []byte("package stats\n"+test.src), config{Type: "Stats"})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: have error %v, want %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestParseStruct(t *testing.T) {
+	const src = `package stats
+
+type Stats struct{ n uint64 }
+
+// Health returns the health of a player.
+//gevm:gas 300
+func (s Stats) Health(player uint64) uint64 { return s.n }
+
+// Heal restores the health of a player.
+func (s *Stats) Heal(player uint64) error { return nil }
+
+func (s *Stats) reset() {}
+
+type Other struct{}
+
+func (o Other) Health() uint64 { return 0 }
+`
+	c, err := parse("stats.go", []byte(src), config{Type: "Stats", Gas: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Impl != "*Stats" {
+		t.Errorf("implementation type %s, want *Stats", c.Impl)
+	}
+	if len(c.Methods) != 2 {
+		t.Fatalf("%d methods, want 2", len(c.Methods))
+	}
+	if m := c.Methods[0]; m.Signature() != "health(uint64)" || m.Gas != 300 || len(m.Outputs) != 1 {
+		t.Errorf("unexpected method %+v", m)
+	}
+	if m := c.Methods[1]; m.Signature() != "heal(uint64)" || m.Gas != 100 || !m.Error || len(m.Outputs) != 0 {
+		t.Errorf("unexpected method %+v", m)
+	}
+	if _, err := c.Go(); err != nil {
+		t.Fatal(err)
+	}
+}
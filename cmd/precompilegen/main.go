@@ -0,0 +1,83 @@
+// precompilegen generates a precompiled contract from a Go interface, or from
+// the exported methods of a Go type declared in the same file.
+//
+// For a type T it writes the ABI decoding and dispatch glue that runs an
+// implementation of T as a vm.PrecompiledContract, gas hooks for each method,
+// and a Solidity library that contracts import to call the precompile.
+//
+// Usage:
+//
+//	//go:generate go run github.com/daweth/gevm/cmd/precompilegen -type Stats -addr 0x0d
+//
+// The gas charged for a method is set with a directive in its doc comment:
+//
+//	//gevm:gas 200
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	var (
+		input    = flag.String("input", os.Getenv("GOFILE"), "Go source file containing the type")
+		typ      = flag.String("type", "", "interface or type to generate the precompile for")
+		addr     = flag.String("addr", "", "address the precompile is registered at")
+		external = flag.Bool("external", false, "mark the precompile as reading state outside of the chain")
+		gas      = flag.Uint64("gas", 100, "gas of methods without a gas directive")
+		goOut    = flag.String("out", "", "output Go file (default <type>_precompile.go)")
+		solOut   = flag.String("sol", "", "output Solidity file (default <Type>.sol)")
+	)
+	flag.Parse()
+
+	if *input == "" || *typ == "" || *addr == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := filepath.Dir(*input)
+	if *goOut == "" {
+		*goOut = filepath.Join(dir, strings.ToLower(*typ)+"_precompile.go")
+	}
+	if *solOut == "" {
+		*solOut = filepath.Join(dir, *typ+".sol")
+	}
+
+	src, err := os.ReadFile(*input)
+	if err != nil {
+		fatal(err)
+	}
+	c, err := parse(*input, src, config{
+		Type:     *typ,
+		Address:  common.HexToAddress(*addr),
+		External: *external,
+		Gas:      *gas,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	code, err := c.Go()
+	if err != nil {
+		fatal(err)
+	}
+	sol, err := c.Solidity()
+	if err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(*goOut, code, 0644); err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(*solOut, sol, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "precompilegen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,334 @@
+// Package config holds the settings of a gevm node, read from a YAML file and
+// overridden by command line flags, so that one binary can run every
+// environment.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of a node.
+type Config struct {
+	HTTP     HTTP     `yaml:"http"`
+	Database string   `yaml:"database"` // pebble, or memory to keep nothing on disk
+	DataDir  string   `yaml:"datadir"`  // directory of the Pebble database
+	Chain    Chain    `yaml:"chain"`
+	Genesis  Genesis  `yaml:"genesis"`
+	Mining   Mining   `yaml:"mining"`
+	RPC      RPC      `yaml:"rpc"`
+	Log      Log      `yaml:"log"`
+	Keystone Keystone `yaml:"keystone"`
+	Fork     Fork     `yaml:"fork"`
+	DAP      DAP      `yaml:"dap"`
+
+	// Artifacts are the outputs of solc --combined-json the failures of
+	// transactions are mapped to, see sourcemap.LoadCombinedJSON.
+	Artifacts []string `yaml:"artifacts"`
+	// Console collects the messages contracts print with Hardhat's
+	// console.log, on in dev mode.
+	Console bool `yaml:"console"`
+}
+
+// HTTP configures the JSON-RPC server.
+type HTTP struct {
+	Listen string `yaml:"listen"` // address to listen on, e.g. ":8080"
+	Admin  bool   `yaml:"admin"`  // serve the admin API creating and deleting chains
+
+	// OracleToken is the bearer token /setWeather and /setOracle require, which
+	// are disabled without one.
+	OracleToken string `yaml:"oracleToken"`
+}
+
+// Chain selects the rules of the EVM.
+type Chain struct {
+	Preset  string `yaml:"preset"`  // test, mainnet, sepolia, goerli or holesky
+	ChainID uint64 `yaml:"chainId"` // overrides the chain id of the preset if set
+}
+
+// Genesis configures the first block, see core.Genesis.
+type Genesis struct {
+	Number   uint64   `yaml:"number"`
+	Time     uint64   `yaml:"time"`
+	GasLimit uint64   `yaml:"gasLimit"`
+	GasUsed  uint64   `yaml:"gasUsed"`
+	Accounts []string `yaml:"accounts"`
+}
+
+// Mining says when blocks are sealed, see core.MiningMode.
+type Mining struct {
+	Mode     core.MiningMode `yaml:"mode"`
+	Interval time.Duration   `yaml:"interval"` // for the interval mode
+}
+
+// RPC selects the JSON-RPC namespaces served, e.g. eth, or evm, hardhat and
+// anvil for the test methods.
+type RPC struct {
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// Log configures logging.
+type Log struct {
+	Level string `yaml:"level"` // trace, debug, info, warn, error or crit
+}
+
+// Keystone configures the Keystone game engine run alongside the node.
+type Keystone struct {
+	Enabled       bool `yaml:"enabled"`
+	Port          int  `yaml:"port"`          // HTTP port of the game server
+	WebsocketPort int  `yaml:"websocketPort"` // port of the game stream
+	TickRateMs    int  `yaml:"tickRateMs"`
+}
+
+// Fork configures fork mode, see core.NewForkNode.
+type Fork struct {
+	URL   string `yaml:"url"`   // upstream JSON-RPC endpoint, fork mode is off if empty
+	Block uint64 `yaml:"block"` // block to fork at, the latest if zero
+}
+
+// DAP configures the Debug Adapter Protocol server debugging the default
+// chain, see debugger.Server.
+type DAP struct {
+	Listen string `yaml:"listen"` // address to listen on, off if empty
+}
+
+// devNamespaces are the namespaces of the test methods.
+var devNamespaces = []string{"evm", "hardhat", "anvil"}
+
+// Default returns the configuration gevm used to be hardcoded with.
+func Default() *Config {
+	genesis := core.DefaultGenesis()
+	accounts := make([]string, len(genesis.Accounts))
+	for i, addr := range genesis.Accounts {
+		accounts[i] = addr.Hex()
+	}
+	return &Config{
+		HTTP:     HTTP{Listen: ":8080"},
+		Database: "pebble",
+		DataDir:  "gevm-db",
+		Chain:    Chain{Preset: "test"},
+		Genesis: Genesis{
+			Number:   genesis.Number,
+			Time:     genesis.Time,
+			GasLimit: genesis.GasLimit,
+			GasUsed:  genesis.GasUsed,
+			Accounts: accounts,
+		},
+		Mining:   Mining{Mode: core.MineManual, Interval: 2 * time.Second},
+		RPC:      RPC{Namespaces: []string{"eth"}},
+		Log:      Log{Level: "info"},
+		Keystone: Keystone{Port: 9000, WebsocketPort: 9001, TickRateMs: 100},
+	}
+}
+
+// Load reads the YAML file at path on top of the default configuration.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if err := cfg.load(path); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Parse reads the configuration from the command line arguments: the file
+// given with -config, if any, on top of the defaults, then the flags, which
+// take precedence over the file.
+func Parse(name string, args []string) (*Config, error) {
+	cfg := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", "", "YAML configuration file")
+	dev := fs.Bool("dev", false, "serve the evm_*, hardhat_* and anvil_* test methods and print console.log messages")
+	cfg.flags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *path != "" {
+		if err := cfg.load(*path); err != nil {
+			return nil, err
+		}
+		// the flags are bound to the configuration, parse them again so
+		// they win over the file
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+	if *dev {
+		cfg.RPC.Namespaces = append(cfg.RPC.Namespaces, devNamespaces...)
+		cfg.Console = true
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.HTTP.Listen, "http.listen", c.HTTP.Listen, "address of the JSON-RPC server")
+	fs.BoolVar(&c.HTTP.Admin, "http.admin", c.HTTP.Admin, "serve the admin API creating and deleting chains")
+	fs.StringVar(&c.HTTP.OracleToken, "http.oracletoken", c.HTTP.OracleToken, "bearer token of /setWeather and /setOracle, disabled if empty")
+	fs.StringVar(&c.Database, "db", c.Database, "database: pebble, or memory to keep nothing on disk")
+	fs.StringVar(&c.DataDir, "datadir", c.DataDir, "directory of the Pebble database")
+	fs.StringVar(&c.Chain.Preset, "chain", c.Chain.Preset, "chain rules: test, mainnet, sepolia, goerli or holesky")
+	fs.Uint64Var(&c.Chain.ChainID, "chain.id", c.Chain.ChainID, "chain id, overriding the one of the chain rules")
+	fs.Uint64Var(&c.Genesis.Number, "genesis.number", c.Genesis.Number, "number of the genesis block")
+	fs.Uint64Var(&c.Genesis.Time, "genesis.time", c.Genesis.Time, "timestamp of the genesis block")
+	fs.Uint64Var(&c.Genesis.GasLimit, "genesis.gaslimit", c.Genesis.GasLimit, "block gas limit")
+	fs.Var((*list)(&c.Genesis.Accounts), "genesis.accounts", "comma separated accounts funded at genesis")
+	fs.Var((*miningMode)(&c.Mining.Mode), "mine", "when blocks are sealed: manual, instant, interval or tick")
+	fs.DurationVar(&c.Mining.Interval, "mine.interval", c.Mining.Interval, "block interval in the interval mining mode")
+	fs.Var((*list)(&c.RPC.Namespaces), "rpc.namespaces", "comma separated JSON-RPC namespaces to serve")
+	fs.StringVar(&c.Log.Level, "log.level", c.Log.Level, "log level: trace, debug, info, warn, error or crit")
+	fs.BoolVar(&c.Keystone.Enabled, "keystone", c.Keystone.Enabled, "run the Keystone game engine")
+	fs.IntVar(&c.Keystone.Port, "keystone.port", c.Keystone.Port, "HTTP port of the game server")
+	fs.IntVar(&c.Keystone.WebsocketPort, "keystone.wsport", c.Keystone.WebsocketPort, "websocket port of the game server")
+	fs.IntVar(&c.Keystone.TickRateMs, "keystone.tickrate", c.Keystone.TickRateMs, "game tick rate in milliseconds")
+	fs.StringVar(&c.Fork.URL, "fork", c.Fork.URL, "JSON-RPC endpoint of a chain to fork")
+	fs.Uint64Var(&c.Fork.Block, "fork.block", c.Fork.Block, "block to fork at (default latest)")
+	fs.StringVar(&c.DAP.Listen, "dap.listen", c.DAP.Listen, "address of the Debug Adapter Protocol server, off if empty")
+	fs.BoolVar(&c.Console, "console", c.Console, "log the messages contracts print with console.log and return them with the response")
+	fs.Var((*list)(&c.Artifacts), "artifacts", "comma separated solc --combined-json outputs of the deployed contracts")
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := c.ChainConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.GenesisBlock(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Database != "pebble" && c.Database != "memory" {
+		errs = append(errs, fmt.Errorf("config: unknown database %q", c.Database))
+	}
+	switch c.Mining.Mode {
+	case core.MineManual, core.MineInstant:
+	case core.MineInterval:
+		if c.Mining.Interval <= 0 {
+			errs = append(errs, errors.New("config: the interval mining mode needs a positive interval"))
+		}
+	case core.MineTick:
+		if !c.Keystone.Enabled {
+			errs = append(errs, errors.New("config: the tick mining mode needs keystone"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("config: unknown mining mode %q", c.Mining.Mode))
+	}
+	if c.Keystone.Enabled && c.Keystone.TickRateMs <= 0 {
+		errs = append(errs, errors.New("config: the keystone tick rate must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// OpenDatabase opens the database of the node.
+func (c *Config) OpenDatabase() (ethdb.Database, error) {
+	if c.Database == "memory" {
+		return core.NewMemoryDatabase(), nil
+	}
+	return core.OpenDatabase(c.DataDir)
+}
+
+// ChainConfig returns the rules of the EVM.
+func (c *Config) ChainConfig() (*params.ChainConfig, error) {
+	var preset *params.ChainConfig
+	switch strings.ToLower(c.Chain.Preset) {
+	case "", "test":
+		preset = params.TestChainConfig
+	case "mainnet":
+		preset = params.MainnetChainConfig
+	case "sepolia":
+		preset = params.SepoliaChainConfig
+	case "goerli":
+		preset = params.GoerliChainConfig
+	case "holesky":
+		preset = params.HoleskyChainConfig
+	default:
+		return nil, fmt.Errorf("config: unknown chain preset %q", c.Chain.Preset)
+	}
+	chainConfig := *preset
+	if c.Chain.ChainID != 0 {
+		chainConfig.ChainID = new(big.Int).SetUint64(c.Chain.ChainID)
+	}
+	return &chainConfig, nil
+}
+
+// GenesisBlock returns the genesis of the node.
+func (c *Config) GenesisBlock() (*core.Genesis, error) {
+	chainConfig, err := c.ChainConfig()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]common.Address, len(c.Genesis.Accounts))
+	for i, s := range c.Genesis.Accounts {
+		if !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("config: invalid genesis account %q", s)
+		}
+		accounts[i] = common.HexToAddress(s)
+	}
+	if len(accounts) < 2 {
+		return nil, errors.New("config: at least two genesis accounts are needed")
+	}
+	return &core.Genesis{
+		Config:   chainConfig,
+		Number:   c.Genesis.Number,
+		Time:     c.Genesis.Time,
+		GasLimit: c.Genesis.GasLimit,
+		GasUsed:  c.Genesis.GasUsed,
+		Accounts: accounts,
+	}, nil
+}
+
+// list is a flag holding comma separated values.
+type list []string
+
+func (l *list) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// miningMode is a flag holding a mining mode.
+type miningMode core.MiningMode
+
+func (m *miningMode) String() string {
+	if m == nil {
+		return ""
+	}
+	return string(*m)
+}
+
+func (m *miningMode) Set(s string) error {
+	*m = miningMode(s)
+	return nil
+}
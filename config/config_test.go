@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+http:
+  listen: ":9545"
+datadir: /tmp/gevm-staging
+chain:
+  preset: sepolia
+  chainId: 4242
+genesis:
+  gasLimit: 30000000
+  accounts:
+    - "0x0000000000000000000000000000000000000001"
+    - "0x0000000000000000000000000000000000000002"
+mining:
+  mode: interval
+  interval: 5s
+rpc:
+  namespaces: [eth, evm]
+log:
+  level: debug
+keystone:
+  enabled: true
+  tickRateMs: 250
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "gevm.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg, err := Parse("gevm", nil)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+
+	genesis, err := cfg.GenesisBlock()
+	require.NoError(t, err)
+	assert.Equal(t, core.DefaultGenesis(), genesis)
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9545", cfg.HTTP.Listen)
+	assert.Equal(t, "/tmp/gevm-staging", cfg.DataDir)
+	assert.Equal(t, core.MineInterval, cfg.Mining.Mode)
+	assert.Equal(t, 5*time.Second, cfg.Mining.Interval)
+	assert.Equal(t, []string{"eth", "evm"}, cfg.RPC.Namespaces)
+	assert.Equal(t, 250, cfg.Keystone.TickRateMs)
+	assert.Equal(t, 9000, cfg.Keystone.Port, "unset values keep their default")
+
+	chainConfig, err := cfg.ChainConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4242), chainConfig.ChainID.Uint64())
+	assert.NotNil(t, chainConfig.ShanghaiTime, "sepolia rules")
+
+	genesis, err := cfg.GenesisBlock()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(30000000), genesis.GasLimit)
+	assert.Equal(t, []common.Address{common.HexToAddress("0x1"), common.HexToAddress("0x2")}, genesis.Accounts)
+}
+
+func TestFlagsOverrideFile(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	cfg, err := Parse("gevm", []string{"-config", path, "-http.listen", ":1234", "-mine", "instant", "-rpc.namespaces", "eth", "-dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, ":1234", cfg.HTTP.Listen)
+	assert.Equal(t, core.MineInstant, cfg.Mining.Mode)
+	assert.Equal(t, []string{"eth", "evm", "hardhat", "anvil"}, cfg.RPC.Namespaces)
+	assert.True(t, cfg.Console)
+	assert.Equal(t, "/tmp/gevm-staging", cfg.DataDir, "not overridden")
+}
+
+func TestValidate(t *testing.T) {
+	for name, args := range map[string][]string{
+		"unknown preset":     {"-chain", "nowhere"},
+		"bad account":        {"-genesis.accounts", "0x1,alice"},
+		"one account":        {"-genesis.accounts", "0x0000000000000000000000000000000000000001"},
+		"unknown mining":     {"-mine", "sometimes"},
+		"unknown database":   {"-db", "floppy"},
+		"tick needs engine":  {"-mine", "tick"},
+		"interval needs one": {"-mine", "interval", "-mine.interval", "0s"},
+	} {
+		_, err := Parse("gevm", args)
+		assert.Error(t, err, name)
+	}
+	_, err := Parse("gevm", []string{"-mine", "tick", "-keystone"})
+	assert.NoError(t, err)
+}
+
+func TestExampleShowsDefaults(t *testing.T) {
+	cfg, err := Load("../gevm.example.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestOpenMemoryDatabase(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := Parse("gevm", []string{"-db", "memory", "-datadir", dir})
+	require.NoError(t, err)
+	db, err := cfg.OpenDatabase()
+	require.NoError(t, err)
+	node, err := core.NewNode(db, core.DefaultGenesis())
+	require.NoError(t, err)
+	_, err = node.Close()
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing written to disk")
+}
@@ -0,0 +1,149 @@
+// Package console decodes the calls of Hardhat's console.sol, so that the
+// console.log print debugging of contracts works on gevm.
+package console
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Address is the address console.sol calls. Nothing is deployed there, so the
+// calls do nothing on chain and are only seen by a Logger.
+var Address = common.HexToAddress("0x000000000000000000636F6e736F6c652e6c6f67")
+
+// ErrUnknownSignature is returned when decoding the call of a function
+// console.sol doesn't have.
+var ErrUnknownSignature = errors.New("console: unknown signature")
+
+// signatures are the arguments of the functions of console.sol by selector.
+var signatures = make(map[[4]byte]abi.Arguments)
+
+// register adds the function name(types...). Older versions of console.sol
+// encode their calls with the signatures spelled with uint and int instead of
+// uint256 and int256, so both spellings are registered.
+func register(name string, types ...string) {
+	args := make(abi.Arguments, len(types))
+	for i, t := range types {
+		typ, err := abi.NewType(t, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		args[i] = abi.Argument{Type: typ}
+	}
+	signature := name + "(" + strings.Join(types, ",") + ")"
+	short := strings.NewReplacer("uint256", "uint", "int256", "int").Replace(signature)
+	for _, sig := range []string{signature, short} {
+		var selector [4]byte
+		copy(selector[:], crypto.Keccak256([]byte(sig)))
+		signatures[selector] = args
+	}
+}
+
+func init() {
+	register("log")
+	for _, t := range []string{"uint256", "int256", "string", "bool", "address", "bytes"} {
+		register("log"+strings.ToUpper(t[:1])+strings.TrimSuffix(t[1:], "256"), t)
+	}
+	for n := 1; n <= 32; n++ {
+		register(fmt.Sprintf("logBytes%d", n), fmt.Sprintf("bytes%d", n))
+	}
+	register("log", "int256")
+	// log with one to four parameters of these types
+	types := []string{"uint256", "string", "bool", "address"}
+	var combine func(params []string)
+	combine = func(params []string) {
+		if len(params) > 0 {
+			register("log", params...)
+		}
+		if len(params) == 4 {
+			return
+		}
+		for _, t := range types {
+			combine(append(params[:len(params):len(params)], t))
+		}
+	}
+	combine(nil)
+}
+
+// Decode returns the message printed by a call of console.sol with input. The
+// arguments are formatted like console.log formats them: a first string
+// argument may hold %s, %d, %i and %o placeholders, and the arguments left
+// are appended, separated by spaces.
+func Decode(input []byte) (string, error) {
+	if len(input) < 4 {
+		return "", ErrUnknownSignature
+	}
+	var selector [4]byte
+	copy(selector[:], input)
+	args, ok := signatures[selector]
+	if !ok {
+		return "", ErrUnknownSignature
+	}
+	values, err := args.Unpack(input[4:])
+	if err != nil {
+		return "", fmt.Errorf("console: %w", err)
+	}
+	return format(values), nil
+}
+
+// format formats values like util.format of Node.js, which Hardhat uses.
+func format(values []interface{}) string {
+	var parts []string
+	if len(values) > 0 {
+		if s, ok := values[0].(string); ok && strings.Contains(s, "%") {
+			var b strings.Builder
+			rest := values[1:]
+			for i := 0; i < len(s); i++ {
+				if s[i] != '%' || i+1 == len(s) {
+					b.WriteByte(s[i])
+					continue
+				}
+				switch s[i+1] {
+				case 's', 'd', 'i', 'o', 'O':
+					if len(rest) == 0 {
+						b.WriteString(s[i : i+2])
+					} else {
+						b.WriteString(formatValue(rest[0]))
+						rest = rest[1:]
+					}
+					i++
+				case '%':
+					b.WriteByte('%')
+					i++
+				default:
+					b.WriteByte('%')
+				}
+			}
+			parts = append(parts, b.String())
+			values = rest
+		}
+	}
+	for _, v := range values {
+		parts = append(parts, formatValue(v))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string:
+		return v
+	case bool:
+		return fmt.Sprint(v)
+	}
+	// fixed size byte arrays
+	return fmt.Sprintf("%#x", v)
+}
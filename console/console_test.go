@@ -0,0 +1,94 @@
+package console
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// call encodes a call of console.sol's signature with args.
+func call(t *testing.T, signature string, types []string, args ...interface{}) []byte {
+	arguments := make(abi.Arguments, len(types))
+	for i, typ := range types {
+		ty, err := abi.NewType(typ, "", nil)
+		require.NoError(t, err)
+		arguments[i] = abi.Argument{Type: ty}
+	}
+	data, err := arguments.Pack(args...)
+	require.NoError(t, err)
+	return append(crypto.Keccak256([]byte(signature))[:4], data...)
+}
+
+func TestDecode(t *testing.T) {
+	addr := common.HexToAddress("0xca11")
+	tests := []struct {
+		input []byte
+		want  string
+	}{
+		{call(t, "log()", nil), ""},
+		{call(t, "log(string)", []string{"string"}, "hello"), "hello"},
+		{call(t, "log(uint)", []string{"uint256"}, big.NewInt(42)), "42"},
+		{call(t, "logInt(int256)", []string{"int256"}, big.NewInt(-1)), "-1"},
+		{call(t, "logBytes4(bytes4)", []string{"bytes4"}, [4]byte{0xde, 0xad, 0xbe, 0xef}), "0xdeadbeef"},
+		{call(t, "logBytes(bytes)", []string{"bytes"}, []byte{1, 2}), "0x0102"},
+		{
+			call(t, "log(string,uint256,address)", []string{"string", "uint256", "address"}, "balance of %s: %d%%", big.NewInt(7), addr),
+			"balance of 7: " + addr.Hex() + "%",
+		},
+		{
+			call(t, "log(bool,string,uint256,address)", []string{"bool", "string", "uint256", "address"}, true, "x", big.NewInt(1), addr),
+			"true x 1 " + addr.Hex(),
+		},
+		{call(t, "log(string,string)", []string{"string", "string"}, "a %s %s", "b"), "a b %s"},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.input)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := Decode(call(t, "log(bytes)", []string{"bytes"}, []byte{1}))
+	assert.ErrorIs(t, err, ErrUnknownSignature)
+	_, err = Decode(nil)
+	assert.ErrorIs(t, err, ErrUnknownSignature)
+	_, err = Decode(crypto.Keccak256([]byte("log(string)"))[:4])
+	assert.Error(t, err)
+}
+
+func TestLogger(t *testing.T) {
+	// forwards its calldata to the console: CALLDATACOPY, then
+	// STATICCALL(gas, console, 0, calldatasize, 0, 0)
+	printer := common.HexToAddress("0x9417")
+	code := append(common.FromHex("0x3660006000376000600036600073"), Address.Bytes()...)
+	code = append(code, common.FromHex("0x5afa00")...)
+
+	statedb, err := gstate.New(common.Hash{}, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	require.NoError(t, err)
+	statedb.SetCode(printer, code)
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+		Difficulty:  big.NewInt(0),
+	}
+	l := NewLogger()
+	evm := vm.NewEVM(blockCtx, vm.TxContext{GasPrice: big.NewInt(0)}, statedb, params.TestChainConfig, vm.Config{Tracer: l})
+	for _, input := range [][]byte{
+		call(t, "log(string,uint256)", []string{"string", "uint256"}, "hp", big.NewInt(100)),
+		[]byte("not console.sol"),
+		call(t, "log(bool)", []string{"bool"}, false),
+	} {
+		_, _, err := evm.Call(vm.AccountRef(common.HexToAddress("0x0b")), printer, input, 100000, big.NewInt(0))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"hp 100", "false"}, l.Messages())
+}
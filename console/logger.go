@@ -0,0 +1,71 @@
+package console
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Logger is an EVM logger collecting the messages printed with console.log by
+// the executions it is attached to. Messages printed by calls that reverted
+// are kept, like Hardhat does.
+type Logger struct {
+	messages []string
+}
+
+// NewLogger returns a logger without messages.
+func NewLogger() *Logger {
+	return new(Logger)
+}
+
+// Messages returns the messages printed, in order.
+func (l *Logger) Messages() []string {
+	return l.messages
+}
+
+// GetResult returns the messages printed, as a JSON array of strings.
+func (l *Logger) GetResult() (json.RawMessage, error) {
+	if l.messages == nil {
+		return json.RawMessage("[]"), nil
+	}
+	return json.Marshal(l.messages)
+}
+
+func (l *Logger) capture(to common.Address, input []byte) {
+	if to != Address {
+		return
+	}
+	msg, err := Decode(input)
+	if err != nil {
+		return
+	}
+	l.messages = append(l.messages, msg)
+}
+
+func (l *Logger) CaptureTxStart(gasLimit uint64) {}
+
+func (l *Logger) CaptureTxEnd(restGas uint64) {}
+
+func (l *Logger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if !create {
+		l.capture(to, input)
+	}
+}
+
+func (l *Logger) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (l *Logger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if typ != vm.CREATE && typ != vm.CREATE2 {
+		l.capture(to, input)
+	}
+}
+
+func (l *Logger) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (l *Logger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (l *Logger) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
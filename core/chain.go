@@ -0,0 +1,287 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// SystemAddress is the sender of the calls the node makes on its own behalf,
+// such as scheduled system calls. Contracts may restrict privileged functions
+// to it.
+var SystemAddress = vm.SystemAddress
+
+// ErrSystemSender is returned for a transaction sent from SystemAddress by
+// anyone but the node. Transactions are unsigned, so the sender is checked
+// instead.
+var ErrSystemSender = errors.New("transactions from the system address are reserved to the node")
+
+// isSystemSender reports whether txn is sent from SystemAddress, read the way
+// the EVM reads the sender.
+func isSystemSender(txn gevmtypes.Transaction) bool {
+	return common.HexToAddress(txn.From) == SystemAddress
+}
+
+// Block is a sealed batch of transactions together with the header committing
+// to the state they produced.
+type Block struct {
+	Header       *types.Header
+	Transactions []gevmtypes.Transaction
+	TxHashes     []common.Hash
+	Witness      []vm.WitnessEntry // outputs of the external precompiles called in the block
+}
+
+// Hash returns the hash of the block header.
+func (b *Block) Hash() common.Hash {
+	return b.Header.Hash()
+}
+
+// NumberU64 returns the block number.
+func (b *Block) NumberU64() uint64 {
+	return b.Header.Number.Uint64()
+}
+
+// chain holds the blocks sealed by a node and the block currently being built.
+// It lives behind a pointer so the EVM block context and every copy of the
+// NodeCtx see the same history. Sealed blocks are read concurrently by the
+// EVMs of read-only calls, so they are guarded by mu; the block being built
+// belongs to the writer.
+type chain struct {
+	mu     sync.RWMutex
+	blocks []*Block
+	byHash map[common.Hash]*Block
+
+	// txIndex locates the transactions of the sealed blocks and of the block
+	// being built. It is only used by the writer, and entries of dropped
+	// blocks may linger, see transactionByHash.
+	txIndex map[common.Hash]txLocation
+
+	pending *types.Header // header of the block being built
+	txs     []gevmtypes.Transaction
+	hashes  []common.Hash
+	witness *vm.Witness
+
+	flushed int // number of sealed blocks whose state is on disk, see flush
+
+	timeOffset uint64      // seconds added to the clock, see IncreaseTime
+	snapshots  []*snapshot // dev mode snapshots, see Snapshot
+}
+
+// txLocation is the block number and the index in the block of a
+// transaction.
+type txLocation struct {
+	number uint64
+	index  int
+}
+
+func newChain(pending *types.Header) *chain {
+	return &chain{
+		byHash:  make(map[common.Hash]*Block),
+		txIndex: make(map[common.Hash]txLocation),
+		pending: pending,
+		witness: vm.NewWitness(),
+	}
+}
+
+// GetHeader implements ChainContext.
+func (c *chain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if b, ok := c.byHash[hash]; ok && b.NumberU64() == number {
+		return b.Header
+	}
+	return nil
+}
+
+// PendingHeader returns the header of the block being built.
+func (n *NodeCtx) PendingHeader() *types.Header {
+	return n.chain.pending
+}
+
+// PendingTxHashes returns the hashes of the transactions of the block being
+// built, in the order they were executed.
+func (n *NodeCtx) PendingTxHashes() []common.Hash {
+	return append([]common.Hash(nil), n.chain.hashes...)
+}
+
+// Head returns the latest sealed block, or nil if no block was sealed yet.
+func (n *NodeCtx) Head() *Block {
+	n.chain.mu.RLock()
+	defer n.chain.mu.RUnlock()
+
+	if len(n.chain.blocks) == 0 {
+		return nil
+	}
+	return n.chain.blocks[len(n.chain.blocks)-1]
+}
+
+// BlockByNumber returns the sealed block with the given number, if any.
+func (n *NodeCtx) BlockByNumber(number uint64) *Block {
+	n.chain.mu.RLock()
+	defer n.chain.mu.RUnlock()
+
+	if len(n.chain.blocks) == 0 {
+		return nil
+	}
+	first := n.chain.blocks[0].NumberU64()
+	if number < first || number-first >= uint64(len(n.chain.blocks)) {
+		return nil
+	}
+	return n.chain.blocks[number-first]
+}
+
+// BlockByHash returns the sealed block with the given hash, if any.
+func (n *NodeCtx) BlockByHash(hash common.Hash) *Block {
+	n.chain.mu.RLock()
+	defer n.chain.mu.RUnlock()
+
+	return n.chain.byHash[hash]
+}
+
+// TransactionHash derives the hash of a gevm transaction. gevm transactions
+// carry no nonce, so the position of the transaction in the chain is mixed in
+// to tell repeated submissions apart.
+func TransactionHash(txn gevmtypes.Transaction, block uint64, index int) common.Hash {
+	enc, err := rlp.EncodeToBytes(txn)
+	must(err)
+	pos := make([]byte, 16)
+	binary.BigEndian.PutUint64(pos[:8], block)
+	binary.BigEndian.PutUint64(pos[8:], uint64(index))
+	return crypto.Keccak256Hash(enc, pos)
+}
+
+// beginTransaction adds txn to the block being built and points the state at
+// it, so that the logs it emits can be told apart from earlier ones.
+func (n *NodeCtx) beginTransaction(txn gevmtypes.Transaction) common.Hash {
+	index := len(n.chain.txs)
+	hash := TransactionHash(txn, n.chain.pending.Number.Uint64(), index)
+	n.StateDB.SetTxContext(hash, index)
+	n.chain.witness.SetTx(index)
+	n.chain.txs = append(n.chain.txs, txn)
+	n.chain.hashes = append(n.chain.hashes, hash)
+	n.chain.txIndex[hash] = txLocation{n.chain.pending.Number.Uint64(), index}
+	return hash
+}
+
+// ApplySystemCall calls contract from SystemAddress as part of the block being
+// built. The call is charged gas like any other transaction.
+func (n *NodeCtx) ApplySystemCall(contract common.Address, input []byte, gas uint64) ([]byte, uint64, error) {
+	return n.applyTransaction(gevmtypes.Transaction{
+		From: SystemAddress.Hex(),
+		To:   contract.Hex(),
+		Gas:  gas,
+		Data: string(input),
+	})
+}
+
+// SealBlock closes the block being built. The state is committed, the block is
+// stored with its witness and appended to the chain, and a new block is opened
+// on top of it.
+func (n *NodeCtx) SealBlock() (*Block, error) {
+	header := n.chain.pending
+
+	// empty accounts are kept, since upserted accounts must survive the commit
+	root, err := n.StateDB.Commit(header.Number.Uint64(), false)
+	if err != nil {
+		return nil, err
+	}
+	header.Root = root
+
+	block := &Block{
+		Header:       header,
+		Transactions: n.chain.txs,
+		TxHashes:     n.chain.hashes,
+		Witness:      n.chain.witness.Entries,
+	}
+	if err := writeBlock(n.db.DiskDB(), block); err != nil {
+		return nil, err
+	}
+	n.chain.mu.Lock()
+	n.chain.blocks = append(n.chain.blocks, block)
+	n.chain.byHash[block.Hash()] = block
+	n.chain.mu.Unlock()
+	if err := n.maybeFlush(); err != nil {
+		return nil, err
+	}
+
+	return block, n.openBlock(newChildHeader(header, n.chain.timeOffset), root, vm.NewWitness())
+}
+
+// ImportBlock executes a block sealed by another node on top of the head of
+// this one. External precompiles replay the outputs recorded in the block's
+// witness instead of querying the local game engine. The block is rejected,
+// and the block being built is reset, if it doesn't reproduce the state root.
+func (n *NodeCtx) ImportBlock(block *Block) (*Block, error) {
+	head := n.Head()
+	if len(n.chain.txs) > 0 {
+		return nil, errors.New("import: the block being built has transactions")
+	}
+	if block.Header.ParentHash != head.Hash() {
+		return nil, fmt.Errorf("import: block %d does not extend head %v", block.NumberU64(), head.Hash())
+	}
+
+	header := types.CopyHeader(block.Header)
+	header.Root, header.GasUsed = common.Hash{}, 0
+	if err := n.openBlock(header, head.Header.Root, vm.NewReplayWitness(block.Witness)); err != nil {
+		return nil, err
+	}
+	// failed transactions are part of the block like any other
+	n.ApplyTransactions(block.Transactions, 0)
+	if root := n.StateDB.IntermediateRoot(false); root != block.Header.Root {
+		err := fmt.Errorf("import: block %d has state root %v, execution gave %v", block.NumberU64(), block.Header.Root, root)
+		if rerr := n.openBlock(newChildHeader(head.Header, n.chain.timeOffset), head.Header.Root, vm.NewWitness()); rerr != nil {
+			return nil, rerr
+		}
+		return nil, err
+	}
+	return n.SealBlock()
+}
+
+// openBlock starts building a block with the given header on top of the state
+// with the given root.
+func (n *NodeCtx) openBlock(header *types.Header, root common.Hash, witness *vm.Witness) error {
+	statedb, err := gstate.New(root, n.db, nil)
+	if err != nil {
+		return err
+	}
+	n.chain.pending = header
+	n.chain.txs, n.chain.hashes = nil, nil
+	n.chain.witness = witness
+
+	n.StateDB = statedb
+	n.Evm.Reset(n.Evm.TxContext, statedb)
+	n.Evm.SetBlockContext(NewEVMBlockContext(header, n.chain, nil))
+	n.Evm.Config.Witness = witness
+	return nil
+}
+
+// newChildHeader opens a block on top of parent, with the clock moved forward
+// by offset seconds.
+func newChildHeader(parent *types.Header, offset uint64) *types.Header {
+	now := uint64(time.Now().Unix()) + offset
+	if now < parent.Time {
+		now = parent.Time
+	}
+	return &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Difficulty: new(big.Int).Set(parent.Difficulty),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       now,
+		Nonce:      parent.Nonce,
+	}
+}
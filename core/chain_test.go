@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/curio-research/keystone-starter-kit/server/constants"
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/curio-research/keystone-starter-kit/server/helper"
+	"github.com/curio-research/keystone/server"
+	"github.com/curio-research/keystone/state"
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// weatherStoreCode deploys a contract that reads the precompile at 0x0b and
+// stores the result in slot 0. Test nodes serve engineWeather there.
+var weatherStoreCode = hexutil.MustDecode("0x6014600c60003960146000f3" + "6020600060006000600b5afa5060005160005500")
+
+func newTestNode() *NodeCtx {
+	node := newNodeContext(rawdb.NewMemoryDatabase(), gasLimit, gasUsed, admin, account1)
+	node.RegisterPrecompile(common.BytesToAddress([]byte{0x0b}), engineWeather{})
+	return &node
+}
+
+// engineWeather is a game precompile reading the weather of the engine of the
+// EVM, an external input recorded in the witness.
+type engineWeather struct{}
+
+func (engineWeather) ReadsExternalState()             {}
+func (engineWeather) RequiredGas(input []byte) uint64 { return 15 }
+func (engineWeather) Run(input []byte) ([]byte, error) {
+	return nil, errors.New("engineWeather: run without an engine")
+}
+func (engineWeather) RunEngine(engine *server.EngineCtx, input []byte) ([]byte, error) {
+	return common.BytesToHash([]byte{byte(helper.GetWeather(engine))}).Bytes(), nil
+}
+
+func setWeather(weather data.Weather) {
+	vm.InitializeEngine(newWeatherEngine(weather))
+}
+
+// newWeatherEngine returns a game engine whose world has the given weather.
+func newWeatherEngine(weather data.Weather) *server.EngineCtx {
+	world := state.NewWorld()
+	world.AddTable(data.Game)
+	data.Game.AddSpecific(world, constants.GameEntity, data.GameSchema{Weather: weather})
+	return &server.EngineCtx{World: world}
+}
+
+func TestImportBlockReplaysWitness(t *testing.T) {
+	leader := newTestNode()
+	follower := newTestNode()
+	require.Equal(t, leader.Head().Hash(), follower.Head().Hash(), "genesis differs")
+
+	setWeather(data.Sunny)
+	created, _, err := leader.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), Gas: 1000000, Data: string(weatherStoreCode)})
+	require.NoError(t, err)
+	contract := common.BytesToAddress(created)
+	_, _, err = leader.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), To: contract.Hex(), Gas: 1000000})
+	require.NoError(t, err)
+	block, err := leader.SealBlock()
+	require.NoError(t, err)
+	require.Len(t, block.Witness, 1)
+	assert.Equal(t, 1, block.Witness[0].TxIndex)
+
+	// the follower's engine disagrees, so executing the block live would
+	// store a different weather
+	setWeather(data.Windy)
+
+	tampered := *block
+	tampered.Witness = nil
+	_, err = follower.ImportBlock(&tampered)
+	require.Error(t, err)
+	assert.Equal(t, block.NumberU64(), follower.PendingHeader().Number.Uint64())
+
+	imported, err := follower.ImportBlock(block)
+	require.NoError(t, err)
+	assert.Equal(t, block.Hash(), imported.Hash())
+	assert.Equal(t, block.TxHashes, imported.TxHashes)
+	assert.Equal(t, common.BigToHash(common.Big1), follower.StateDB.GetState(contract, common.Hash{}))
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+)
+
+// The methods in this file give tests the controls of a development chain, in
+// the manner of Hardhat and Anvil. They change the node outside of
+// transactions and must only be exposed in dev mode.
+
+// ErrUnknownSnapshot is returned when reverting to a snapshot that doesn't
+// exist, or was already reverted.
+var ErrUnknownSnapshot = errors.New("unknown snapshot")
+
+// snapshot is a copy of everything a revert restores.
+type snapshot struct {
+	blocks     int // number of sealed blocks
+	statedb    *gstate.StateDB
+	pending    *types.Header
+	txs        []gevmtypes.Transaction
+	hashes     []common.Hash
+	witness    []vm.WitnessEntry
+	timeOffset uint64
+}
+
+// Snapshot saves the chain and the block being built, and returns an id to
+// revert to them with RevertToSnapshot. Ids start at 1.
+func (n *NodeCtx) Snapshot() uint64 {
+	c := n.chain
+	c.mu.RLock()
+	blocks := len(c.blocks)
+	c.mu.RUnlock()
+
+	c.snapshots = append(c.snapshots, &snapshot{
+		blocks:     blocks,
+		statedb:    n.StateDB.Copy(),
+		pending:    types.CopyHeader(c.pending),
+		txs:        append([]gevmtypes.Transaction(nil), c.txs...),
+		hashes:     append([]common.Hash(nil), c.hashes...),
+		witness:    append([]vm.WitnessEntry(nil), c.witness.Entries...),
+		timeOffset: c.timeOffset,
+	})
+	return uint64(len(c.snapshots))
+}
+
+// RevertToSnapshot drops the blocks sealed and the transactions applied since
+// snapshot id was taken. The snapshot and the ones taken after it are
+// discarded, so each snapshot can be reverted to once.
+func (n *NodeCtx) RevertToSnapshot(id uint64) error {
+	c := n.chain
+	if id == 0 || id > uint64(len(c.snapshots)) {
+		return fmt.Errorf("%w: %d", ErrUnknownSnapshot, id)
+	}
+	snap := c.snapshots[id-1]
+	c.snapshots = c.snapshots[:id-1]
+
+	c.mu.Lock()
+	for _, b := range c.blocks[snap.blocks:] {
+		delete(c.byHash, b.Hash())
+		for _, hash := range b.TxHashes {
+			delete(c.txIndex, hash)
+		}
+	}
+	c.blocks = c.blocks[:snap.blocks]
+	c.mu.Unlock()
+	for _, hash := range c.hashes {
+		delete(c.txIndex, hash)
+	}
+	for i, hash := range snap.hashes {
+		c.txIndex[hash] = txLocation{snap.pending.Number.Uint64(), i}
+	}
+	if c.flushed > snap.blocks {
+		// a restart must not resume from the dropped blocks
+		c.flushed = snap.blocks
+		if err := writeHead(n.db.DiskDB(), c.blocks[len(c.blocks)-1].Header); err != nil {
+			return err
+		}
+	}
+
+	witness := vm.NewWitness()
+	witness.Entries = snap.witness
+	c.pending = snap.pending
+	c.txs, c.hashes = snap.txs, snap.hashes
+	c.witness = witness
+	c.timeOffset = snap.timeOffset
+
+	n.StateDB = snap.statedb
+	n.Evm.Reset(n.Evm.TxContext, snap.statedb)
+	n.Evm.SetBlockContext(NewEVMBlockContext(snap.pending, c, nil))
+	n.Evm.Config.Witness = witness
+	return nil
+}
+
+// IncreaseTime moves the clock of the node forward by the given number of
+// seconds, starting with the block being built. It returns the total number of
+// seconds the clock was moved.
+func (n *NodeCtx) IncreaseTime(seconds uint64) uint64 {
+	n.chain.timeOffset += seconds
+	n.setPendingTime(n.chain.pending.Time + seconds)
+	return n.chain.timeOffset
+}
+
+// SetNextBlockTimestamp sets the timestamp of the block being built. The
+// blocks after it keep counting from there. The timestamp must be after the
+// one of the head.
+func (n *NodeCtx) SetNextBlockTimestamp(timestamp uint64) error {
+	if head := n.Head(); head != nil && timestamp <= head.Header.Time {
+		return fmt.Errorf("timestamp %d is not after the head timestamp %d", timestamp, head.Header.Time)
+	}
+	now := uint64(time.Now().Unix())
+	if timestamp > now {
+		n.chain.timeOffset = timestamp - now
+	} else {
+		n.chain.timeOffset = 0
+	}
+	n.setPendingTime(timestamp)
+	return nil
+}
+
+func (n *NodeCtx) setPendingTime(timestamp uint64) {
+	n.chain.pending.Time = timestamp
+	n.Evm.Context.Time = timestamp
+}
+
+// SetBalance sets the balance of addr, creating the account if needed.
+func (n *NodeCtx) SetBalance(addr common.Address, balance *big.Int) {
+	upsert(n.StateDB, addr)
+	n.StateDB.SetBalance(addr, balance)
+	n.StateDB.Finalise(false)
+}
+
+// SetCode sets the code of addr, creating the account if needed.
+func (n *NodeCtx) SetCode(addr common.Address, code []byte) {
+	upsert(n.StateDB, addr)
+	n.StateDB.SetCode(addr, code)
+	n.StateDB.Finalise(false)
+}
+
+// SetStorageAt sets a storage slot of addr, creating the account if needed.
+func (n *NodeCtx) SetStorageAt(addr common.Address, slot, value common.Hash) {
+	upsert(n.StateDB, addr)
+	n.StateDB.SetState(addr, slot, value)
+	n.StateDB.Finalise(false)
+}
+
+// SetNonce sets the nonce of addr, creating the account if needed.
+func (n *NodeCtx) SetNonce(addr common.Address, nonce uint64) {
+	upsert(n.StateDB, addr)
+	n.StateDB.SetNonce(addr, nonce)
+	n.StateDB.Finalise(false)
+}
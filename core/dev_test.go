@@ -0,0 +1,109 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timestampCode stores the block timestamp in slot 0.
+var timestampCode = hexutil.MustDecode("0x4260005500")
+
+func TestSnapshotRevert(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	count := func() uint64 { return node.StateDB.GetState(counter, common.Hash{}).Big().Uint64() }
+
+	_, _, err := node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	head, pending := node.Head().Hash(), node.PendingHeader().Number.Uint64()
+
+	id := node.Snapshot()
+	assert.Equal(t, uint64(1), id)
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	_, err = node.SealBlock()
+	require.NoError(t, err)
+	node.SetBalance(account1, big.NewInt(7))
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	dropped := node.PendingTxHashes()[0]
+	assert.Equal(t, uint64(2), node.Snapshot())
+	require.Equal(t, uint64(3), count())
+
+	require.NoError(t, node.RevertToSnapshot(id))
+	assert.Equal(t, uint64(1), count())
+	assert.Equal(t, head, node.Head().Hash())
+	assert.Equal(t, pending, node.PendingHeader().Number.Uint64())
+	assert.Nil(t, node.BlockByNumber(pending))
+	assert.Equal(t, big.NewInt(1e18), node.StateDB.GetBalance(account1))
+	assert.ErrorIs(t, node.TraceTransaction(dropped, nil), ErrTxNotFound)
+	assert.NoError(t, node.TraceTransaction(node.PendingTxHashes()[0], nil))
+	assert.ErrorIs(t, node.RevertToSnapshot(id), ErrUnknownSnapshot)
+	assert.ErrorIs(t, node.RevertToSnapshot(2), ErrUnknownSnapshot)
+
+	// the chain goes on from the snapshot
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	block, err := node.SealBlock()
+	require.NoError(t, err)
+	assert.Len(t, block.Transactions, 2) // setting code is not a transaction
+	assert.Equal(t, uint64(2), count())
+}
+
+func TestIncreaseTime(t *testing.T) {
+	node := newTestNode()
+	clock := common.HexToAddress("0xc10c")
+	node.SetCode(clock, timestampCode)
+	stored := func() uint64 { return node.StateDB.GetState(clock, common.Hash{}).Big().Uint64() }
+	tick := gevmtypes.Transaction{From: account1.Hex(), To: clock.Hex(), Gas: 100000}
+
+	start := node.PendingHeader().Time
+	assert.Equal(t, uint64(3600), node.IncreaseTime(3600))
+	_, _, err := node.ApplyTransaction(tick)
+	require.NoError(t, err)
+	assert.Equal(t, start+3600, stored())
+
+	// later blocks keep the offset
+	_, err = node.SealBlock()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, node.PendingHeader().Time, uint64(time.Now().Unix())+3600-1)
+
+	next := node.PendingHeader().Time + 1000
+	require.NoError(t, node.SetNextBlockTimestamp(next))
+	_, _, err = node.ApplyTransaction(tick)
+	require.NoError(t, err)
+	assert.Equal(t, next, stored())
+	block, err := node.SealBlock()
+	require.NoError(t, err)
+	assert.Equal(t, next, block.Header.Time)
+	assert.GreaterOrEqual(t, node.PendingHeader().Time, next)
+
+	assert.Error(t, node.SetNextBlockTimestamp(next))
+}
+
+func TestSetAccount(t *testing.T) {
+	node := newTestNode()
+	addr := common.HexToAddress("0xabc")
+	slot, value := common.Hash{1}, common.Hash{2}
+
+	node.SetBalance(addr, big.NewInt(42))
+	node.SetNonce(addr, 9)
+	node.SetCode(addr, counterCode)
+	node.SetStorageAt(addr, slot, value)
+	_, err := node.SealBlock()
+	require.NoError(t, err)
+
+	assert.Equal(t, big.NewInt(42), node.StateDB.GetBalance(addr))
+	assert.Equal(t, uint64(9), node.StateDB.GetNonce(addr))
+	assert.Equal(t, counterCode, node.StateDB.GetCode(addr))
+	assert.Equal(t, value, node.StateDB.GetState(addr, slot))
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"github.com/curio-research/keystone/server"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BindEngine makes the game precompiles of the node read the given Keystone
+// world instead of the one given to vm.InitializeEngine, so that the nodes of
+// different matches each read their own. It must be called before the node
+// executes transactions.
+func (n *NodeCtx) BindEngine(engine *server.EngineCtx) {
+	n.Evm.Config.Engine = engine
+}
+
+// RegisterPrecompile adds a precompile to this node only, such as a game
+// precompile generated by cmd/precompilegen and bound to the world of the
+// node. It takes precedence over the precompiles registered with
+// vm.RegisterPrecompile and must be called before the node executes
+// transactions.
+func (n *NodeCtx) RegisterPrecompile(addr common.Address, p vm.PrecompiledContract) {
+	if n.Evm.Config.Precompiles == nil {
+		n.Evm.Config.Precompiles = make(map[common.Address]vm.PrecompiledContract)
+	}
+	n.Evm.Config.Precompiles[addr] = p
+}
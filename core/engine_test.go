@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// constant is a precompile returning the same word on every call.
+type constant byte
+
+func (c constant) RequiredGas(input []byte) uint64 { return 15 }
+func (c constant) Run(input []byte) ([]byte, error) {
+	return common.BytesToHash([]byte{byte(c)}).Bytes(), nil
+}
+
+// storedWeather deploys weatherStoreCode on node, calls it and returns what it
+// stored.
+func storedWeather(t *testing.T, node *NodeCtx) common.Hash {
+	created, _, err := node.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), Gas: 1000000, Data: string(weatherStoreCode)})
+	require.NoError(t, err)
+	contract := common.BytesToAddress(created)
+	_, _, err = node.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), To: contract.Hex(), Gas: 1000000})
+	require.NoError(t, err)
+	return node.StateDB.GetState(contract, common.Hash{})
+}
+
+func weatherHash(weather data.Weather) common.Hash {
+	return common.BytesToHash([]byte{byte(weather)})
+}
+
+func TestBindEngine(t *testing.T) {
+	setWeather(data.Sunny)
+	sunny, windy, unbound := newTestNode(), newTestNode(), newTestNode()
+	sunny.BindEngine(newWeatherEngine(data.Sunny))
+	windy.BindEngine(newWeatherEngine(data.Windy))
+
+	assert.Equal(t, weatherHash(data.Windy), storedWeather(t, windy))
+	assert.Equal(t, weatherHash(data.Sunny), storedWeather(t, sunny))
+	assert.Equal(t, weatherHash(data.Sunny), storedWeather(t, unbound), "the engine given to vm.InitializeEngine")
+}
+
+func TestRegisterPrecompile(t *testing.T) {
+	setWeather(data.Sunny)
+	own, other := newTestNode(), newTestNode()
+	own.RegisterPrecompile(common.BytesToAddress([]byte{0x0b}), constant(7))
+
+	assert.Equal(t, common.BytesToHash([]byte{7}), storedWeather(t, own), "overrides the weather")
+	assert.Equal(t, weatherHash(data.Sunny), storedWeather(t, other))
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrExecutorClosed is returned for work submitted after the executor closed.
+var ErrExecutorClosed = errors.New("executor closed")
+
+// Executor gives concurrent callers, such as the HTTP handlers and the game
+// tick, safe access to a node. Neither the EVM nor the StateDB are thread safe,
+// so state changing work runs one job at a time on a single writer goroutine,
+// while read-only calls run concurrently, each on its own copy of the state.
+type Executor struct {
+	node *NodeCtx
+
+	jobs    chan job
+	quit    chan struct{}
+	stopped chan struct{} // closed when the writer returns
+	closing sync.Once
+
+	version atomic.Uint64 // bumped by every write, to expire the view
+	instant atomic.Bool   // seal after every write, see SealInstantly
+	viewMu  sync.Mutex
+	view    *view // state read-only calls are copied from
+}
+
+type job struct {
+	fn       func(n *NodeCtx) error
+	done     chan error
+	readOnly bool
+}
+
+// view is a copy of the state of the block being built, together with what an
+// EVM needs to execute on it.
+type view struct {
+	version     uint64
+	statedb     *gstate.StateDB
+	header      *types.Header
+	txContext   vm.TxContext
+	config      vm.Config
+	chainConfig *params.ChainConfig
+}
+
+// NewExecutor starts the writer of the node. From then on the node must only be
+// used through the executor, until it is closed.
+func NewExecutor(node *NodeCtx) *Executor {
+	e := &Executor{
+		node:    node,
+		jobs:    make(chan job),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *Executor) loop() {
+	defer close(e.stopped)
+	for {
+		select {
+		case j := <-e.jobs:
+			err := run(j.fn, e.node)
+			if !j.readOnly && e.instant.Load() && len(e.node.chain.txs) > 0 {
+				if _, serr := e.node.SealBlock(); serr != nil && err == nil {
+					err = serr
+				}
+			}
+			if !j.readOnly {
+				e.version.Add(1)
+			}
+			j.done <- err
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// run calls fn, turning a panic into an error so that a failing job doesn't
+// take the writer down with it.
+func run(fn func(n *NodeCtx) error, n *NodeCtx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("executor: %v", r)
+		}
+	}()
+	return fn(n)
+}
+
+func (e *Executor) submit(j job) error {
+	j.done = make(chan error, 1)
+	select {
+	case e.jobs <- j:
+		return <-j.done
+	case <-e.quit:
+		return ErrExecutorClosed
+	}
+}
+
+// Do runs fn on the writer. Jobs run one at a time, in the order they were
+// submitted, and fn has exclusive access to the node while it runs.
+func (e *Executor) Do(fn func(n *NodeCtx) error) error {
+	return e.submit(job{fn: fn})
+}
+
+// ApplyTransaction executes txn as part of the block being built.
+func (e *Executor) ApplyTransaction(txn gevmtypes.Transaction) (outputs []byte, gasLeft uint64, err error) {
+	err = e.Do(func(n *NodeCtx) error {
+		var vmerr error
+		outputs, gasLeft, vmerr = n.ApplyTransaction(txn)
+		return vmerr
+	})
+	return outputs, gasLeft, err
+}
+
+// ApplyTransactionWithTracer executes txn as part of the block being built,
+// with tracer attached to the EVM for this transaction only.
+func (e *Executor) ApplyTransactionWithTracer(txn gevmtypes.Transaction, tracer vm.EVMLogger) (outputs []byte, gasLeft uint64, err error) {
+	err = e.Do(func(n *NodeCtx) error {
+		var vmerr error
+		outputs, gasLeft, vmerr = n.ApplyTransactionWithTracer(txn, tracer)
+		return vmerr
+	})
+	return outputs, gasLeft, err
+}
+
+// ApplyTransactions executes txs in parallel as part of the block being built,
+// see NodeCtx.ApplyTransactions.
+func (e *Executor) ApplyTransactions(txs []gevmtypes.Transaction, workers int) (results []TxResult, err error) {
+	err = e.Do(func(n *NodeCtx) error {
+		results = n.ApplyTransactions(txs, workers)
+		return nil
+	})
+	return results, err
+}
+
+// TraceTransaction re-executes the transaction with the given hash with tracer
+// attached, see NodeCtx.TraceTransaction. The node is left as it is.
+func (e *Executor) TraceTransaction(hash common.Hash, tracer vm.EVMLogger) error {
+	return e.submit(job{readOnly: true, fn: func(n *NodeCtx) error {
+		return n.TraceTransaction(hash, tracer)
+	}})
+}
+
+// State returns a copy of the state of the block being built. The copy belongs
+// to the caller, who may read and modify it without affecting the node.
+func (e *Executor) State() (*gstate.StateDB, error) {
+	v, err := e.currentView()
+	if err != nil {
+		return nil, err
+	}
+	return v.statedb, nil
+}
+
+// Call executes txn on a copy of the state of the block being built and
+// discards the changes it made. Calls run concurrently with each other and
+// with the writer.
+func (e *Executor) Call(txn gevmtypes.Transaction) ([]byte, uint64, error) {
+	return e.CallWithTracer(txn, nil)
+}
+
+// CallWithTracer is Call with tracer attached to the EVM running the call.
+func (e *Executor) CallWithTracer(txn gevmtypes.Transaction, tracer vm.EVMLogger) ([]byte, uint64, error) {
+	return e.CallAndRead(txn, tracer, nil)
+}
+
+// CallAndRead is CallWithTracer, calling read, if not nil, with the state the
+// call left before it is discarded, e.g. to read the code of the contracts it
+// called without copying the state again.
+func (e *Executor) CallAndRead(txn gevmtypes.Transaction, tracer vm.EVMLogger, read func(statedb *gstate.StateDB)) ([]byte, uint64, error) {
+	v, err := e.currentView()
+	if err != nil {
+		return nil, 0, err
+	}
+	v.config.Tracer = tracer
+	evm := vm.NewEVM(NewEVMBlockContext(v.header, e.node.chain, nil), v.txContext, v.statedb, v.chainConfig, v.config)
+	output, gasLeft, err := execute(evm, v.statedb, txn)
+	if read != nil {
+		read(v.statedb)
+	}
+	return output, gasLeft, err
+}
+
+// GetBalance returns the balance of addr in the block being built.
+func (e *Executor) GetBalance(addr common.Address) (*big.Int, error) {
+	statedb, err := e.State()
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetBalance(addr), nil
+}
+
+// currentView returns a private copy of the view, refreshing the view first if
+// a write happened since it was taken.
+func (e *Executor) currentView() (*view, error) {
+	e.viewMu.Lock()
+	defer e.viewMu.Unlock()
+
+	if e.view == nil || e.view.version != e.version.Load() {
+		var fresh *view
+		err := e.submit(job{readOnly: true, fn: func(n *NodeCtx) error {
+			config := n.Evm.Config
+			// tracers and witnesses belong to the block being built
+			config.Tracer, config.Witness = nil, nil
+			fresh = &view{
+				version:     e.version.Load(),
+				statedb:     n.StateDB.Copy(),
+				header:      types.CopyHeader(n.chain.pending),
+				txContext:   n.Evm.TxContext,
+				config:      config,
+				chainConfig: n.Evm.ChainConfig(),
+			}
+			return nil
+		}})
+		if err != nil {
+			return nil, err
+		}
+		e.view = fresh
+	}
+	v := *e.view
+	v.statedb = e.view.statedb.Copy()
+	return &v, nil
+}
+
+// Close stops the writer, waiting for the job it is running to finish. Jobs
+// submitted afterwards fail with ErrExecutorClosed, and the node may be used
+// directly again. Close must not be called from a job.
+func (e *Executor) Close() {
+	e.closing.Do(func() { close(e.quit) })
+	<-e.stopped
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterCode increments slot 0 and returns nothing.
+var counterCode = hexutil.MustDecode("0x600054600101600055" + "00")
+
+func TestExecutorConcurrentAccess(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.StateDB.SetCode(counter, counterCode)
+
+	exec := NewExecutor(node)
+	defer exec.Close()
+
+	const workers, writes = 8, 25
+	increment := gevmtypes.Transaction{From: admin.Hex(), To: counter.Hex(), Gas: 100000}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				_, _, err := exec.ApplyTransaction(increment)
+				assert.NoError(t, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				// calls see a consistent state and leave it untouched
+				_, _, err := exec.Call(increment)
+				assert.NoError(t, err)
+				_, err = exec.GetBalance(admin)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	statedb, err := exec.State()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(workers*writes), statedb.GetState(counter, common.Hash{}).Big().Uint64())
+
+	// a panicking job fails without stopping the writer
+	assert.Error(t, exec.Do(func(n *NodeCtx) error { panic("boom") }))
+	_, _, err = exec.ApplyTransaction(increment)
+	assert.NoError(t, err)
+
+	exec.Close()
+	_, _, err = exec.ApplyTransaction(increment)
+	assert.ErrorIs(t, err, ErrExecutorClosed)
+}
+
+func TestExecutorSealInstantly(t *testing.T) {
+	node := newTestNode()
+	exec := NewExecutor(node)
+	defer exec.Close()
+	exec.SealInstantly()
+
+	head := node.Head().NumberU64()
+	_, _, err := exec.ApplyTransaction(gevmtypes.Transaction{To: account1.Hex()})
+	require.NoError(t, err)
+	// writes without transactions don't seal
+	require.NoError(t, exec.Do(func(n *NodeCtx) error { return nil }))
+
+	var number uint64
+	require.NoError(t, exec.Do(func(n *NodeCtx) error {
+		number = n.Head().NumberU64()
+		return nil
+	}))
+	assert.Equal(t, head+1, number)
+}
+
+func TestExecutorReadsCodeHash(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	exec := NewExecutor(node)
+	defer exec.Close()
+
+	var read common.Hash
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	_, _, err := exec.CallAndRead(increment, nil, func(statedb *gstate.StateDB) {
+		read = statedb.GetCodeHash(counter)
+		assert.Equal(t, common.HexToHash("0x1"), statedb.GetState(counter, common.Hash{}), "the state the call left")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, crypto.Keccak256Hash(counterCode), read)
+}
+
+func TestSystemSenderIsRefused(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	exec := NewExecutor(node)
+	defer exec.Close()
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	spoofed := gevmtypes.Transaction{From: SystemAddress.Hex(), To: vm.OracleAddress.Hex(), Gas: 100000,
+		Data: string(vm.PackOracleSetUpdater(account1, true))}
+
+	// as eth_send, eth_sendRawTransaction and the debugger send them
+	_, _, err := exec.ApplyTransactionWithTracer(spoofed, nil)
+	assert.ErrorIs(t, err, ErrSystemSender)
+	_, _, err = exec.ApplyTransaction(spoofed)
+	assert.ErrorIs(t, err, ErrSystemSender)
+
+	// as eth_sendBatch sends them, the others are applied
+	results, err := exec.ApplyTransactions([]gevmtypes.Transaction{increment, spoofed, increment}, 0)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, ErrSystemSender)
+	assert.NoError(t, results[2].Err)
+
+	session, err := exec.Simulate()
+	require.NoError(t, err)
+	defer session.Close()
+	steps, err := session.Run(SimStep{Tx: spoofed})
+	require.NoError(t, err)
+	assert.Equal(t, ErrSystemSender.Error(), steps[0].Error)
+
+	require.NoError(t, exec.Do(func(n *NodeCtx) error {
+		assert.Len(t, n.PendingTxHashes(), 2, "refused transactions are not part of the block")
+		assert.Equal(t, common.HexToHash("0x2"), n.StateDB.GetState(counter, common.Hash{}))
+		assert.False(t, vm.IsOracleUpdater(n.StateDB, account1))
+		// the node itself still calls as the system
+		_, _, err := n.ApplySystemCall(vm.OracleAddress, vm.PackOracleSetUpdater(account1, true), 100000)
+		return err
+	}))
+}
+
+func TestPublishOracleValue(t *testing.T) {
+	node := newTestNode()
+	assert.False(t, vm.IsOracleUpdater(node.StateDB, admin), "no account writes to the oracle by default")
+	err := node.SetOracleValue(admin, vm.WeatherKey, big.NewInt(2))
+	assert.Error(t, err)
+
+	require.NoError(t, node.PublishOracleValue(vm.WeatherKey, big.NewInt(3)))
+	block, err := node.SealBlock()
+	require.NoError(t, err)
+	value, at, ok := node.OracleValue(vm.WeatherKey, block.NumberU64())
+	require.True(t, ok)
+	assert.Equal(t, int64(3), value.Int64())
+	assert.Equal(t, block.NumberU64(), at)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"github.com/daweth/gevm/fork"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// NewForkNodeContext creates a node on top of the state of the upstream node
+// at url, as of the given block, or the latest block if block is zero. The
+// upstream state is loaded on demand and cached in the local database; the
+// genesis of the node has the number and time of the pinned block.
+func NewForkNodeContext(url string, block uint64, gasLimit uint64, gasUsed uint64, accounts ...common.Address) (NodeCtx, error) {
+	client, err := fork.Dial(url, block)
+	if err != nil {
+		return NodeCtx{}, err
+	}
+	rdb, err := OpenDatabase("gevm-db")
+	if err != nil {
+		client.Close()
+		return NodeCtx{}, err
+	}
+	genesis := DefaultGenesis()
+	genesis.GasLimit, genesis.GasUsed, genesis.Accounts = gasLimit, gasUsed, accounts
+	return NewForkNode(rdb, client, genesis)
+}
+
+// DefaultFork creates a node with the default parameters on top of the state
+// of the upstream node at url, see NewForkNodeContext.
+func DefaultFork(url string, block uint64) (NodeCtx, error) {
+	return NewForkNodeContext(url, block, gasLimit, gasUsed, admin, account1)
+}
+
+// newForkNodeContext creates a node on top of the state of the upstream node
+// client is connected to, keeping local state in the given database.
+func newForkNodeContext(rdb ethdb.Database, client *fork.Client, gasLimit uint64, gasUsed uint64, accounts ...common.Address) NodeCtx {
+	genesis := DefaultGenesis()
+	genesis.GasLimit, genesis.GasUsed, genesis.Accounts = gasLimit, gasUsed, accounts
+	node, err := NewForkNode(rdb, client, genesis)
+	must(err)
+	return node
+}
+
+// NewForkNode creates a node on top of the state of the upstream node client
+// is connected to, keeping local state in db. The number and time of genesis
+// are those of the pinned block.
+func NewForkNode(db ethdb.Database, client *fork.Client, genesis *Genesis) (NodeCtx, error) {
+	if err := genesis.validate(); err != nil {
+		return NodeCtx{}, err
+	}
+	g := *genesis
+	g.Number, g.Time = client.Block(), client.Time()
+	return newNode(fork.NewDatabase(gstate.NewDatabaseWithConfig(db, nil), client), &g), nil
+}
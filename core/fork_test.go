@@ -0,0 +1,72 @@
+package core
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daweth/gevm/fork"
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forkUpstream is a stand-in for an upstream chain at block 500, holding a
+// counter at 0xc0ffee that counted to 41.
+type forkUpstream struct{}
+
+func (forkUpstream) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	return map[string]interface{}{"number": hexutil.Uint64(500), "timestamp": hexutil.Uint64(1700000000)}
+}
+
+func (forkUpstream) GetBalance(addr common.Address, block hexutil.Uint64) *hexutil.Big {
+	if addr == account1 {
+		return (*hexutil.Big)(big.NewInt(5))
+	}
+	return new(hexutil.Big)
+}
+
+func (forkUpstream) GetTransactionCount(addr common.Address, block hexutil.Uint64) hexutil.Uint64 {
+	return 0
+}
+
+func (forkUpstream) GetCode(addr common.Address, block hexutil.Uint64) hexutil.Bytes {
+	if addr == common.HexToAddress("0xc0ffee") {
+		return counterCode
+	}
+	return nil
+}
+
+func (forkUpstream) GetStorageAt(addr common.Address, slot common.Hash, block hexutil.Uint64) hexutil.Bytes {
+	if addr == common.HexToAddress("0xc0ffee") && slot == (common.Hash{}) {
+		return common.BigToHash(big.NewInt(41)).Bytes()
+	}
+	return common.Hash{}.Bytes()
+}
+
+func TestForkNode(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", forkUpstream{}))
+	upstream := httptest.NewServer(server)
+	defer upstream.Close()
+	client, err := fork.Dial(upstream.URL, 500)
+	require.NoError(t, err)
+	defer client.Close()
+
+	node := newForkNodeContext(rawdb.NewMemoryDatabase(), client, gasLimit, gasUsed, admin, account1)
+	assert.Equal(t, uint64(500), node.Head().NumberU64())
+	assert.Equal(t, uint64(1700000000), node.Head().Header.Time)
+	// the genesis balance is added to the upstream one
+	assert.Equal(t, big.NewInt(1e18+5), node.StateDB.GetBalance(account1))
+
+	counter := common.HexToAddress("0xc0ffee")
+	_, _, err = node.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), To: counter.Hex(), Gas: 100000})
+	require.NoError(t, err)
+	_, err = node.SealBlock()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), node.StateDB.GetState(counter, common.Hash{}).Big().Uint64())
+}
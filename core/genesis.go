@@ -0,0 +1,70 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/pebble"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Genesis describes the first block of a node. Nodes created from the same
+// genesis start from the same block.
+type Genesis struct {
+	Config   *params.ChainConfig
+	Number   uint64
+	Time     uint64
+	GasLimit uint64
+	GasUsed  uint64
+	// Accounts are funded with 1 ether each. The second one is the origin of
+	// the EVM.
+	Accounts []common.Address
+}
+
+// DefaultGenesis returns the genesis of the nodes created by Default.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		Config:   params.TestChainConfig,
+		Number:   18437836,
+		Time:     0,
+		GasLimit: gasLimit,
+		GasUsed:  gasUsed,
+		Accounts: []common.Address{admin, account1},
+	}
+}
+
+func (g *Genesis) validate() error {
+	if g.Config == nil {
+		return errors.New("genesis: missing chain config")
+	}
+	if len(g.Accounts) < 2 {
+		return errors.New("genesis: at least two accounts are needed")
+	}
+	return nil
+}
+
+// NewNode creates a node starting from genesis, keeping its state in db.
+func NewNode(db ethdb.Database, genesis *Genesis) (NodeCtx, error) {
+	if err := genesis.validate(); err != nil {
+		return NodeCtx{}, err
+	}
+	return newNode(gstate.NewDatabaseWithConfig(db, nil), genesis), nil
+}
+
+// OpenDatabase opens the Pebble database in dir, creating it if needed.
+func OpenDatabase(dir string) (ethdb.Database, error) {
+	pbl, err := pebble.New(dir, 0, 0, "gevm", false, false)
+	if err != nil {
+		return nil, err
+	}
+	return rawdb.NewDatabase(pbl), nil
+}
+
+// NewMemoryDatabase returns a database held in memory, which is thrown away
+// when it is closed or the process exits.
+func NewMemoryDatabase() ethdb.Database {
+	return rawdb.NewMemoryDatabase()
+}
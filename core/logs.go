@@ -0,0 +1,32 @@
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// LogHandler receives the logs emitted by each transaction the node executes.
+// Handlers are called synchronously after execution, so they should hand the
+// logs off rather than doing slow work in place.
+type LogHandler interface {
+	HandleLogs(logs []*gtypes.Log)
+}
+
+// AddLogHandler registers h to receive the logs of every following transaction.
+func (n *NodeCtx) AddLogHandler(h LogHandler) {
+	n.logHandlers = append(n.logHandlers, h)
+}
+
+// publishLogs hands the logs emitted by the transaction to the log handlers.
+func (n *NodeCtx) publishLogs(hash common.Hash) {
+	if len(n.logHandlers) == 0 {
+		return
+	}
+	logs := n.StateDB.GetLogs(hash, n.Evm.Context.BlockNumber.Uint64(), common.Hash{})
+	if len(logs) == 0 {
+		return
+	}
+	for _, h := range n.logHandlers {
+		h.HandleLogs(logs)
+	}
+}
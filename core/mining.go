@@ -0,0 +1,48 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// MiningMode says when the blocks of a node run by an executor are sealed.
+type MiningMode string
+
+const (
+	MineManual   MiningMode = "manual"   // only when asked, e.g. with evm_mine
+	MineInstant  MiningMode = "instant"  // after every job that applied transactions
+	MineInterval MiningMode = "interval" // at a fixed interval
+	MineTick     MiningMode = "tick"     // on every Keystone tick, by a scheduler
+)
+
+// SealInstantly makes the executor seal a block after every job that applied
+// transactions, so that each request gets its own block.
+func (e *Executor) SealInstantly() {
+	e.instant.Store(true)
+}
+
+// SealEvery seals a block at the given interval, if transactions were applied
+// since the previous one, until the executor is closed.
+func (e *Executor) SealEvery(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := e.Do(func(n *NodeCtx) error {
+					if len(n.chain.txs) == 0 {
+						return nil
+					}
+					_, err := n.SealBlock()
+					return err
+				})
+				if err != nil && err != ErrExecutorClosed {
+					fmt.Println("sealing block failed", err)
+				}
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
@@ -4,31 +4,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
-	"time"
 
 	// logger "github.com/daweth/gevm/logger"
 	// "github.com/daweth/gevm/state"
 	"github.com/daweth/gevm/types"
-	"github.com/daweth/gevm/util"
 	"github.com/daweth/gevm/vm"
 
 	"github.com/daweth/gevm/gevmtypes"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/rawdb"
 
 	gstate "github.com/ethereum/go-ethereum/core/state"
 	gtypes "github.com/ethereum/go-ethereum/core/types"
-	gvm "github.com/ethereum/go-ethereum/core/vm"
-	glogger "github.com/ethereum/go-ethereum/eth/tracers/logger"
-	"github.com/ethereum/go-ethereum/ethdb/pebble"
-	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/ethdb"
 )
 
 type NodeCtx struct {
 	Accounts []common.Address // accounts that this node handles.
 	StateDB  *gstate.StateDB
 	Evm      *vm.EVM
+
+	db          gstate.Database // database backing StateDB.
+	chain       *chain          // sealed blocks and the block being built.
+	logHandlers []LogHandler    // receivers of the logs emitted by each transaction.
 }
 
 type NodeParams struct {
@@ -37,14 +35,40 @@ type NodeParams struct {
 }
 
 func NewNodeContext(gasLimit uint64, gasUsed uint64, accounts ...common.Address) NodeCtx {
-	pbl, err := pebble.New("gevm-db", 0, 0, "gevm", false, false)
+	rdb, err := OpenDatabase("gevm-db")
+	must(err)
+	return newNodeContext(rdb, gasLimit, gasUsed, accounts...)
+}
+
+// NewMemoryNodeContext creates a node keeping its state in memory, for tests and
+// short-lived shards. Nothing is written to disk, and the state is thrown away
+// when the node is closed or the process exits.
+func NewMemoryNodeContext(gasLimit uint64, gasUsed uint64, accounts ...common.Address) NodeCtx {
+	return newNodeContext(NewMemoryDatabase(), gasLimit, gasUsed, accounts...)
+}
+
+// newNodeContext creates a node keeping its state in the given database.
+func newNodeContext(rdb ethdb.Database, gasLimit uint64, gasUsed uint64, accounts ...common.Address) NodeCtx {
+	genesis := DefaultGenesis()
+	genesis.GasLimit, genesis.GasUsed, genesis.Accounts = gasLimit, gasUsed, accounts
+	return newNode(gstate.NewDatabaseWithConfig(rdb, nil), genesis)
+}
+
+// newNode creates a node on top of db, starting from genesis.
+func newNode(db gstate.Database, genesis *Genesis) NodeCtx {
+	accounts := genesis.Accounts
+	// a node closed before resumes from its head instead of genesis
+	head, err := readHead(db.DiskDB())
+	must(err)
+	root := common.Hash{}
+	if head != nil {
+		root = head.Root
+	}
+	statedb, err := gstate.New(root, db, nil)
 	must(err)
-	rdb := rawdb.NewDatabase(pbl)
-	db := gstate.NewDatabaseWithConfig(rdb, nil)
-	statedb, err := gstate.New(common.Hash{}, db, nil)
 
 	// fill database with addresses
-	for i := 0; i < len(accounts); i++ {
+	for i := 0; i < len(accounts) && head == nil; i++ {
 		fmt.Println("seeding the balance of the new account", accounts[i])
 		statedb.GetOrNewStateObject(accounts[i])
 		statedb.AddBalance(accounts[i], big.NewInt(1e18))
@@ -59,21 +83,24 @@ func NewNodeContext(gasLimit uint64, gasUsed uint64, accounts ...common.Address)
 		ReceiptHash: common.Hash{},
 		Bloom:       types.BytesToBloom([]byte("daweth")),
 		Difficulty:  big.NewInt(1),
-		Number:      big.NewInt(18437836),
-		GasLimit:    gasLimit,
-		GasUsed:     gasUsed,
-		Time:        uint64(time.Now().Unix()),
+		Number:      new(big.Int).SetUint64(genesis.Number),
+		GasLimit:    genesis.GasLimit,
+		GasUsed:     genesis.GasUsed,
+		Time:        genesis.Time, // genesis is the same on every node with the same parameters
 		Extra:       nil,
 		MixDigest:   common.Hash{},
 		Nonce:       types.EncodeNonce(1),
 	}
+	if head != nil {
+		header = *head
+	}
 
 	message := core.Message{
 		To:                &accounts[0],
 		From:              accounts[1],
 		Nonce:             uint64(1),
 		Value:             big.NewInt(1),
-		GasLimit:          gasLimit,
+		GasLimit:          genesis.GasLimit,
 		GasPrice:          big.NewInt(0),
 		GasFeeCap:         big.NewInt(0),
 		GasTipCap:         big.NewInt(0),
@@ -84,45 +111,41 @@ func NewNodeContext(gasLimit uint64, gasUsed uint64, accounts ...common.Address)
 		SkipAccountChecks: false,
 	}
 
-	cc := EmptyChainContext{}
-	btx := NewEVMBlockContext(&header, cc, &accounts[0])
+	chain := newChain(&header)
+	btx := NewEVMBlockContext(&header, chain, &accounts[0])
 	ctx := NewEVMTxContext(&message)
 
-	// create structLogger (for EVM config)
-	chainConfig := params.TestChainConfig
-	logConfig := glogger.Config{
-		EnableMemory:     true,
-		DisableStack:     true,
-		DisableStorage:   false,
-		EnableReturnData: true,
-		Debug:            true,
-		Limit:            0,
-		Overrides:        chainConfig,
-	}
-	logger := glogger.NewStructLogger(&logConfig)
-	vmConfig := gvm.Config{
-		Tracer:                  logger,
-		NoBaseFee:               true,
-		EnablePreimageRecording: false,
-		ExtraEips:               []int{},
-	}
-	vmcfg := util.ConvertGConfigToConfig(vmConfig, logConfig)
+	// tracing is off, tracers are attached to the EVM of a traced request only
+	chainConfig := genesis.Config
+	vmcfg := vm.Config{
+		NoBaseFee: true,
+		Witness:   chain.witness,
+	}
 	// create new EVM
 	evm := vm.NewEVM(btx, ctx, statedb, chainConfig, vmcfg)
 
-	return NodeCtx{
+	node := NodeCtx{
 		Accounts: accounts,
 		StateDB:  statedb,
 		Evm:      evm,
+		db:       db,
+		chain:    chain,
+	}
+	if head != nil {
+		must(node.resume())
+		return node
 	}
+	_, err = node.SealBlock()
+	must(err)
+	return node
 
 }
 
 var (
 	gasLimit = uint64(1000000000000)
 	gasUsed  = uint64(1)
-	admin    = common.HexToAddress("alice")
-	account1 = common.HexToAddress("bob")
+	admin    = common.HexToAddress("0x00000000000000000000000000000000000A11cE")
+	account1 = common.HexToAddress("0x0000000000000000000000000000000000000B0b")
 )
 
 func Default() NodeCtx {
@@ -141,28 +164,38 @@ func (n *NodeCtx) HandleTransaction(txn gevmtypes.Transaction) ([]byte, uint64)
 		return []byte(""), 0
 	}
 
+	outputs, gasLeft, err := n.ApplyTransaction(txn)
+	must(err)
+	return outputs, gasLeft
+}
 
-	// only txn.To exists
-	if txn.From == txn.Data && txn.From == "" {
-		// upsert the account that exists
-		n.StateDB.GetOrNewStateObject(common.HexToAddress(txn.To)) // create entry in db
-		return n.handleSeedTransaction(txn)
+// ApplyTransaction executes txn as part of the block being built. It returns
+// the output, the gas left over and the execution error, if any. Transactions
+// from SystemAddress are refused with ErrSystemSender, see ApplySystemCall.
+func (n *NodeCtx) ApplyTransaction(txn gevmtypes.Transaction) ([]byte, uint64, error) {
+	if isSystemSender(txn) {
+		return nil, txn.Gas, ErrSystemSender
 	}
+	return n.applyTransaction(txn)
+}
 
-	// only txn.From exists, it must be a contract creation
-	if txn.To == "" {
-		// upsert the account
-		n.StateDB.GetOrNewStateObject(common.HexToAddress(txn.From)) // create entry in db
-		return n.handleCreateTransaction(txn)
-	} else {
-		// upsert both accounts since both exist
-		n.StateDB.GetOrNewStateObject(common.HexToAddress(txn.To)) // create entry in db
-		n.StateDB.GetOrNewStateObject(common.HexToAddress(txn.From)) // create entry in db
-		value := big.NewInt(0).SetUint64(txn.Value)
-		outputs, gasLeft, vmerr := n.Evm.Call(StringToContractRef(txn.From), StringToAddress(txn.To), []byte(txn.Data), txn.Gas, value)
-		must(vmerr)
-		return outputs, gasLeft
+// applyTransaction is ApplyTransaction, from any sender.
+func (n *NodeCtx) applyTransaction(txn gevmtypes.Transaction) ([]byte, uint64, error) {
+	hash := n.beginTransaction(txn)
+	// like on ethereum, a transaction sees the state the previous ones left
+	// and nothing else, which lets them run in parallel
+	defer n.StateDB.Finalise(false)
+
+	if isSeedTransaction(txn) {
+		outputs, gasLeft := handleSeedTransaction(n.StateDB, txn)
+		return outputs, gasLeft, nil
 	}
+
+	outputs, gasLeft, vmerr := execute(n.Evm, n.StateDB, txn)
+	n.chain.pending.GasUsed += txn.Gas - gasLeft
+
+	n.publishLogs(hash)
+	return outputs, gasLeft, vmerr
 }
 
  // READ ONLY 
@@ -171,21 +204,56 @@ func (n *NodeCtx) HandleGetBalance(txn gevmtypes.Transaction) (uint64) {
 	return bal
 }
 
-func (n *NodeCtx) handleCreateTransaction(txn gevmtypes.Transaction) ([]byte, uint64) {
+// execute runs txn on evm, whose state is statedb.
+func execute(evm *vm.EVM, statedb vm.StateDB, txn gevmtypes.Transaction) (ret []byte, gasLeft uint64, err error) {
+	if tracer := evm.Config.Tracer; tracer != nil {
+		tracer.CaptureTxStart(txn.Gas)
+		defer func() { tracer.CaptureTxEnd(gasLeft) }()
+	}
 	value := big.NewInt(0).SetUint64(txn.Value)
-	_, contractAddress, gasLeft, vmerr := n.Evm.Create(StringToContractRef(txn.From), []byte(txn.Data), txn.Gas, value)
-	must(vmerr)
-	return contractAddress[:], gasLeft
+	from := common.HexToAddress(txn.From)
+	var to *common.Address
+	if txn.To != "" {
+		addr := common.HexToAddress(txn.To)
+		to = &addr
+	}
+	// every transaction starts with a fresh access list
+	rules := evm.ChainConfig().Rules(evm.Context.BlockNumber, evm.Context.Random != nil, evm.Context.Time)
+	statedb.Prepare(rules, from, evm.Context.Coinbase, to, evm.ActivePrecompiles(), nil)
+
+	// only txn.From exists, it must be a contract creation
+	if to == nil {
+		// upsert the account
+		upsert(statedb, from)
+		_, contractAddress, gasLeft, vmerr := evm.Create(vm.AccountRef(from), []byte(txn.Data), txn.Gas, value)
+		return contractAddress[:], gasLeft, vmerr
+	}
+	// upsert both accounts since both exist
+	upsert(statedb, *to)
+	upsert(statedb, from)
+	return evm.Call(vm.AccountRef(from), *to, []byte(txn.Data), txn.Gas, value)
+}
+
+// only txn.To exists
+func isSeedTransaction(txn gevmtypes.Transaction) bool {
+	return txn.From == txn.Data && txn.From == ""
 }
 
-func (n *NodeCtx) handleSeedTransaction(txn gevmtypes.Transaction) ([]byte, uint64) {
+func handleSeedTransaction(statedb vm.StateDB, txn gevmtypes.Transaction) ([]byte, uint64) {
 	fmt.Println("handling ETHSeed")
 	amount := new(big.Int).Exp(big.NewInt(1000), big.NewInt(24), nil)
-	n.StateDB.GetOrNewStateObject(common.HexToAddress(txn.To)) // create entry in db
-	n.StateDB.AddBalance(common.HexToAddress(txn.To), amount)  // seed balance of address
+	upsert(statedb, common.HexToAddress(txn.To))            // create entry in db
+	statedb.AddBalance(common.HexToAddress(txn.To), amount) // seed balance of address
 	return []byte(""), 1
 }
 
+// upsert creates the account if it doesn't exist yet.
+func upsert(statedb vm.StateDB, addr common.Address) {
+	if !statedb.Exist(addr) {
+		statedb.CreateAccount(addr)
+	}
+}
+
 // HELPER FUNCTIONS
 func StringToContractRef(s string) vm.ContractRef {
 	hex := common.HexToAddress(s)
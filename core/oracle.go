@@ -0,0 +1,45 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// oracleGas is the gas given to oracle updates sent by the node.
+const oracleGas = uint64(100000)
+
+// SetOracleValue writes value under key in the game oracle on behalf of
+// updater. The update is an ordinary transaction of the block being built and
+// fails unless updater was authorized.
+func (n *NodeCtx) SetOracleValue(updater common.Address, key common.Hash, value *big.Int) error {
+	_, _, err := n.ApplyTransaction(gevmtypes.Transaction{
+		From: updater.Hex(),
+		To:   vm.OracleAddress.Hex(),
+		Gas:  oracleGas,
+		Data: string(vm.PackOracleSet(key, value)),
+	})
+	return err
+}
+
+// PublishOracleValue writes value under key in the game oracle on behalf of
+// the node, with a system call of the block being built.
+func (n *NodeCtx) PublishOracleValue(key common.Hash, value *big.Int) error {
+	_, _, err := n.ApplySystemCall(vm.OracleAddress, vm.PackOracleSet(key, value), oracleGas)
+	return err
+}
+
+// AuthorizeOracleUpdater grants or revokes the right to write oracle values.
+func (n *NodeCtx) AuthorizeOracleUpdater(updater common.Address, allowed bool) error {
+	_, _, err := n.ApplySystemCall(vm.OracleAddress, vm.PackOracleSetUpdater(updater, allowed), oracleGas)
+	return err
+}
+
+// OracleValue returns the value of key as of the given block and the block it
+// was written in. ok is false if the key had no value yet.
+func (n *NodeCtx) OracleValue(key common.Hash, block uint64) (value *big.Int, at uint64, ok bool) {
+	v, at, ok := vm.ReadOracle(n.StateDB, key, block)
+	return v.Big(), at, ok
+}
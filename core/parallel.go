@@ -0,0 +1,88 @@
+package core
+
+import (
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/parallel"
+	"github.com/daweth/gevm/vm"
+)
+
+// TxResult is the outcome of a transaction executed in a batch.
+type TxResult struct {
+	Output  []byte
+	GasLeft uint64
+	Err     error
+}
+
+// ApplyTransactions executes txs as part of the block being built, running
+// them in parallel on the given number of workers, or one per core if zero.
+// The block ends up exactly as if the transactions were applied one by one
+// with ApplyTransaction, in order.
+//
+// Transactions are not traced while they run in parallel.
+func (n *NodeCtx) ApplyTransactions(txs []gevmtypes.Transaction, workers int) []TxResult {
+	var (
+		index   = make([]int, len(txs)) // in the block, -1 for refused transactions
+		results = make([]TxResult, len(txs))
+		entries = make([][]vm.WitnessEntry, len(txs))
+
+		blockCtx    = n.Evm.Context
+		txCtx       = n.Evm.TxContext
+		chainConfig = n.Evm.ChainConfig()
+		config      = n.Evm.Config
+		replay      = n.chain.witness
+	)
+	config.Tracer = nil
+
+	next := len(n.chain.txs)
+	for i, txn := range txs {
+		if isSystemSender(txn) {
+			index[i], results[i] = -1, TxResult{GasLeft: txn.Gas, Err: ErrSystemSender}
+			continue
+		}
+		index[i] = next
+		next++
+	}
+
+	res := parallel.Execute(n.StateDB, len(txs), workers, func(i int, statedb vm.StateDB) {
+		txn := txs[i]
+		if index[i] < 0 {
+			return
+		}
+		if isSeedTransaction(txn) {
+			output, gasLeft := handleSeedTransaction(statedb, txn)
+			results[i] = TxResult{Output: output, GasLeft: gasLeft}
+			return
+		}
+		// every execution records its own witness, or replays the block's
+		witness := vm.NewWitness()
+		if replay.Replaying() {
+			witness = vm.NewReplayWitness(replay.Entries)
+		}
+		witness.SetTx(index[i])
+
+		cfg := config
+		cfg.Witness = witness
+		evm := vm.NewEVM(blockCtx, txCtx, statedb, chainConfig, cfg)
+		output, gasLeft, err := execute(evm, statedb, txn)
+		results[i] = TxResult{Output: output, GasLeft: gasLeft, Err: err}
+		if !replay.Replaying() {
+			entries[i] = witness.Entries
+		}
+	})
+
+	for i, txn := range txs {
+		if index[i] < 0 {
+			continue
+		}
+		hash := n.beginTransaction(txn)
+		res.Apply(i, n.StateDB)
+		n.StateDB.Finalise(false)
+
+		if !isSeedTransaction(txn) {
+			n.chain.pending.GasUsed += txn.Gas - results[i].GasLeft
+			n.publishLogs(hash)
+		}
+		n.chain.witness.Entries = append(n.chain.witness.Entries, entries[i]...)
+	}
+	return results
+}
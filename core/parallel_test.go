@@ -0,0 +1,87 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// loggingCounterCode increments slot 0 and emits an empty log.
+	loggingCounterCode = hexutil.MustDecode("0x600054600101600055" + "60006000a0" + "00")
+	// destructCode self-destructs, sending its balance to the caller.
+	destructCode = hexutil.MustDecode("0x33ff")
+)
+
+func TestApplyTransactionsMatchesSequential(t *testing.T) {
+	var (
+		counter  = common.HexToAddress("0xc0ffee")
+		destruct = common.HexToAddress("0xdead")
+		txs      []gevmtypes.Transaction
+	)
+	for i := 0; i < 16; i++ {
+		from := common.BytesToAddress([]byte{0xa0, byte(i)}).Hex()
+		to := common.BytesToAddress([]byte{0xb0, byte(i)}).Hex()
+		txs = append(txs,
+			// independent senders, funded in the same batch
+			gevmtypes.Transaction{To: from},
+			gevmtypes.Transaction{From: from, To: to, Gas: 100000, Value: 1000},
+			// all increment the same counter
+			gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000},
+		)
+	}
+	txs = append(txs,
+		gevmtypes.Transaction{From: admin.Hex(), Gas: 1000000, Data: string(weatherStoreCode)},
+		gevmtypes.Transaction{From: admin.Hex(), Gas: 1000000, Data: string(weatherStoreCode)},
+		// destroyed, then funded again
+		gevmtypes.Transaction{From: account1.Hex(), To: destruct.Hex(), Gas: 100000},
+		gevmtypes.Transaction{From: account1.Hex(), To: destruct.Hex(), Gas: 100000, Value: 5},
+		gevmtypes.Transaction{From: admin.Hex(), To: counter.Hex(), Gas: 10}, // out of gas
+	)
+
+	newNode := func() *NodeCtx {
+		node := newTestNode()
+		node.StateDB.SetCode(counter, loggingCounterCode)
+		node.StateDB.SetCode(destruct, destructCode)
+		node.StateDB.AddBalance(destruct, common.Big3)
+		return node
+	}
+	sequential, concurrent := newNode(), newNode()
+	concurrent.PendingHeader().Time = sequential.PendingHeader().Time
+	var logs, parallelLogs logCounter
+	sequential.AddLogHandler(&logs)
+	concurrent.AddLogHandler(&parallelLogs)
+
+	var want []TxResult
+	for _, txn := range txs {
+		output, gasLeft, err := sequential.ApplyTransaction(txn)
+		want = append(want, TxResult{Output: output, GasLeft: gasLeft, Err: err})
+	}
+	have := concurrent.ApplyTransactions(txs, 4)
+	require.Len(t, have, len(want))
+	for i := range want {
+		assert.Equal(t, want[i], have[i], "transaction %d", i)
+	}
+	assert.Equal(t, 16, parallelLogs.n)
+	assert.Equal(t, logs.n, parallelLogs.n)
+
+	wantBlock, err := sequential.SealBlock()
+	require.NoError(t, err)
+	haveBlock, err := concurrent.SealBlock()
+	require.NoError(t, err)
+	assert.Equal(t, wantBlock.Header.GasUsed, haveBlock.Header.GasUsed)
+	assert.Equal(t, wantBlock.Header.Root, haveBlock.Header.Root)
+	assert.Equal(t, wantBlock.Hash(), haveBlock.Hash())
+	assert.Equal(t, uint64(16), concurrent.StateDB.GetState(counter, common.Hash{}).Big().Uint64())
+	assert.Empty(t, concurrent.StateDB.GetCode(destruct))
+	assert.Equal(t, int64(5), concurrent.StateDB.GetBalance(destruct).Int64())
+}
+
+type logCounter struct{ n int }
+
+func (c *logCounter) HandleLogs(logs []*gtypes.Log) { c.n += len(logs) }
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"encoding/binary"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// flushInterval is the number of sealed blocks after which their state
+	// is flushed to the database.
+	flushInterval = 128
+	// flushSize is the size of the trie nodes held in memory past which the
+	// state of the sealed blocks is flushed to the database.
+	flushSize = 256 * 1024 * 1024
+)
+
+var (
+	// headKey stores the header of the head of a node that was flushed.
+	headKey = []byte("gevm-head")
+	// blockPrefix + number stores the sealed block with that number.
+	blockPrefix = []byte("gevm-block-")
+)
+
+func blockKey(number uint64) []byte {
+	key := make([]byte, len(blockPrefix)+8)
+	copy(key, blockPrefix)
+	binary.BigEndian.PutUint64(key[len(blockPrefix):], number)
+	return key
+}
+
+// storedBlock is the database encoding of a sealed block.
+type storedBlock struct {
+	Header       *types.Header
+	Transactions []gevmtypes.Transaction
+	TxHashes     []common.Hash
+	Witness      []storedWitnessEntry
+}
+
+// storedWitnessEntry is a vm.WitnessEntry, whose transaction index RLP can't
+// encode as an int.
+type storedWitnessEntry struct {
+	TxIndex uint64
+	Address common.Address
+	Input   []byte
+	Output  []byte
+	Err     string
+}
+
+// writeBlock stores a sealed block, with the witness needed to replay it.
+func writeBlock(db ethdb.KeyValueWriter, block *Block) error {
+	stored := storedBlock{
+		Header:       block.Header,
+		Transactions: block.Transactions,
+		TxHashes:     block.TxHashes,
+		Witness:      make([]storedWitnessEntry, len(block.Witness)),
+	}
+	for i, e := range block.Witness {
+		stored.Witness[i] = storedWitnessEntry{uint64(e.TxIndex), e.Address, e.Input, e.Output, e.Err}
+	}
+	enc, err := rlp.EncodeToBytes(&stored)
+	if err != nil {
+		return err
+	}
+	return db.Put(blockKey(block.NumberU64()), enc)
+}
+
+// readBlock returns the sealed block stored with the given number, or nil.
+func readBlock(db ethdb.KeyValueReader, number uint64) (*Block, error) {
+	key := blockKey(number)
+	if ok, err := db.Has(key); err != nil || !ok {
+		return nil, err
+	}
+	enc, err := db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var stored storedBlock
+	if err := rlp.DecodeBytes(enc, &stored); err != nil {
+		return nil, err
+	}
+	block := &Block{
+		Header:       stored.Header,
+		Transactions: stored.Transactions,
+		TxHashes:     stored.TxHashes,
+	}
+	if len(stored.Witness) > 0 {
+		block.Witness = make([]vm.WitnessEntry, len(stored.Witness))
+	}
+	for i, e := range stored.Witness {
+		block.Witness[i] = vm.WitnessEntry{TxIndex: int(e.TxIndex), Address: e.Address, Input: e.Input, Output: e.Output, Err: e.Err}
+	}
+	return block, nil
+}
+
+// readHead returns the head stored when the node was last flushed, or nil.
+func readHead(db ethdb.KeyValueReader) (*types.Header, error) {
+	if ok, err := db.Has(headKey); err != nil || !ok {
+		return nil, err
+	}
+	enc, err := db.Get(headKey)
+	if err != nil {
+		return nil, err
+	}
+	var head types.Header
+	if err := rlp.DecodeBytes(enc, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+func writeHead(db ethdb.KeyValueWriter, head *types.Header) error {
+	enc, err := rlp.EncodeToBytes(head)
+	if err != nil {
+		return err
+	}
+	return db.Put(headKey, enc)
+}
+
+// resume reads back the sealed blocks up to the stored head, which the block
+// being built holds, and opens a block on top of the head. Blocks are read
+// from the head down, as long as they were stored; a head stored without its
+// block starts the chain on its own.
+func (n *NodeCtx) resume() error {
+	var (
+		pending = n.chain.pending
+		blocks  []*Block
+		hash    = pending.Hash()
+	)
+	for number := pending.Number.Uint64(); ; number-- {
+		block, err := readBlock(n.db.DiskDB(), number)
+		if err != nil {
+			return err
+		}
+		if block == nil || block.Hash() != hash {
+			break
+		}
+		blocks = append(blocks, block)
+		if number == 0 {
+			break
+		}
+		hash = block.Header.ParentHash
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, &Block{Header: pending})
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		n.chain.blocks = append(n.chain.blocks, blocks[i])
+		n.chain.byHash[blocks[i].Hash()] = blocks[i]
+		for j, hash := range blocks[i].TxHashes {
+			n.chain.txIndex[hash] = txLocation{blocks[i].NumberU64(), j}
+		}
+	}
+	n.chain.flushed = len(n.chain.blocks)
+	head := blocks[0]
+	return n.openBlock(newChildHeader(head.Header, 0), head.Header.Root, vm.NewWitness())
+}
+
+// Close seals the block being built if it changed the state, flushes the state
+// of the sealed blocks to the database, and closes the database. The node must
+// not be used afterwards.
+func (n *NodeCtx) Close() (*Block, error) {
+	head, err := n.sealChanges()
+	if err != nil {
+		return nil, err
+	}
+	if err := n.flush(); err != nil {
+		return nil, err
+	}
+	return head, n.db.DiskDB().Close()
+}
+
+// maybeFlush flushes the state of the sealed blocks once flushInterval blocks
+// were sealed since the last flush, or their trie nodes take more than
+// flushSize in memory, so that a node that crashes loses only the last ones.
+func (n *NodeCtx) maybeFlush() error {
+	_, dirty, _ := n.db.TrieDB().Size()
+	if len(n.chain.blocks)-n.chain.flushed < flushInterval && dirty < flushSize {
+		return nil
+	}
+	return n.flush()
+}
+
+// flush writes the state of the blocks sealed since the last flush to the
+// database, and records the head so that the node resumes from it. The sealed
+// state is held in memory until then; the state of every block is kept so
+// that the blocks after it can be traced after a restart.
+func (n *NodeCtx) flush() error {
+	blocks := n.chain.blocks[n.chain.flushed:]
+	if len(blocks) == 0 {
+		return nil
+	}
+	for _, block := range blocks {
+		if err := n.db.TrieDB().Commit(block.Header.Root, false); err != nil {
+			return err
+		}
+	}
+	if err := writeHead(n.db.DiskDB(), blocks[len(blocks)-1].Header); err != nil {
+		return err
+	}
+	n.chain.flushed = len(n.chain.blocks)
+	return nil
+}
+
+// sealChanges seals the block being built if it changed the state and returns
+// the head.
+func (n *NodeCtx) sealChanges() (*Block, error) {
+	head := n.Head()
+	if len(n.chain.txs) > 0 || n.StateDB.IntermediateRoot(false) != head.Header.Root {
+		return n.SealBlock()
+	}
+	return head, nil
+}
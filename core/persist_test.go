@@ -0,0 +1,183 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseAndResume(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *NodeCtx {
+		db, err := OpenDatabase(dir)
+		require.NoError(t, err)
+		node, err := NewNode(db, DefaultGenesis())
+		require.NoError(t, err)
+		return &node
+	}
+	counter := common.HexToAddress("0xc0ffee")
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+
+	node := open()
+	node.SetCode(counter, counterCode)
+	_, err := node.SealBlock()
+	require.NoError(t, err)
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	exec := NewExecutor(node)
+	_, _, err = exec.ApplyTransaction(increment)
+	require.NoError(t, err)
+	exec.Close()
+	_, _, err = exec.ApplyTransaction(increment)
+	assert.ErrorIs(t, err, ErrExecutorClosed)
+
+	// the pending transactions are sealed on close
+	head, err := node.Close()
+	require.NoError(t, err)
+	assert.Len(t, head.Transactions, 2)
+
+	node = open()
+	assert.Equal(t, head.Hash(), node.Head().Hash())
+	assert.Equal(t, head.NumberU64()+1, node.PendingHeader().Number.Uint64())
+	assert.Equal(t, uint64(2), node.StateDB.GetState(counter, common.Hash{}).Big().Uint64())
+	// genesis is not applied again
+	assert.Equal(t, big.NewInt(1e18), node.StateDB.GetBalance(account1))
+
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	block, err := node.SealBlock()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash(), block.Header.ParentHash)
+	assert.Equal(t, uint64(3), node.StateDB.GetState(counter, common.Hash{}).Big().Uint64())
+
+	// changes made without transactions are kept too
+	node.SetBalance(counter, big.NewInt(9))
+	_, err = node.Close()
+	require.NoError(t, err)
+	node = open()
+	assert.Equal(t, big.NewInt(9), node.StateDB.GetBalance(counter))
+	_, err = node.Close()
+	require.NoError(t, err)
+}
+
+func TestResumeKeepsWitness(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *NodeCtx {
+		db, err := OpenDatabase(dir)
+		require.NoError(t, err)
+		node, err := NewNode(db, DefaultGenesis())
+		require.NoError(t, err)
+		return &node
+	}
+
+	node := open()
+	node.RegisterPrecompile(common.BytesToAddress([]byte{0x0b}), engineWeather{})
+	node.BindEngine(newWeatherEngine(data.Sunny))
+	storedWeather(t, node)
+	head, err := node.Close()
+	require.NoError(t, err)
+	require.Len(t, head.Witness, 1)
+
+	node = open()
+	resumed := node.Head()
+	assert.Equal(t, head.Hash(), resumed.Hash())
+	assert.Equal(t, head.Transactions, resumed.Transactions)
+	assert.Equal(t, head.TxHashes, resumed.TxHashes)
+	require.Len(t, resumed.Witness, 1)
+	recorded, read := head.Witness[0], resumed.Witness[0]
+	assert.Equal(t, recorded.TxIndex, read.TxIndex)
+	assert.Equal(t, recorded.Address, read.Address)
+	assert.Empty(t, read.Input)
+	assert.Equal(t, recorded.Output, read.Output)
+	_, err = node.Close()
+	require.NoError(t, err)
+}
+
+func TestResumeKeepsHistory(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *NodeCtx {
+		db, err := OpenDatabase(dir)
+		require.NoError(t, err)
+		node, err := NewNode(db, DefaultGenesis())
+		require.NoError(t, err)
+		return &node
+	}
+	counter := common.HexToAddress("0xc0ffee")
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+
+	node := open()
+	genesis := node.Head()
+	node.SetCode(counter, counterCode)
+	_, err := node.SealBlock()
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, _, err = node.ApplyTransaction(increment)
+		require.NoError(t, err)
+		_, err = node.SealBlock()
+		require.NoError(t, err)
+	}
+	first := node.BlockByNumber(genesis.NumberU64() + 2)
+	head, err := node.Close()
+	require.NoError(t, err)
+
+	// the blocks before the head are read back, with the state they were
+	// executed on
+	node = open()
+	assert.Equal(t, head.Hash(), node.Head().Hash())
+	assert.Equal(t, genesis.Hash(), node.BlockByNumber(genesis.NumberU64()).Hash())
+	require.NotNil(t, node.BlockByHash(first.Hash()))
+	assert.Equal(t, first.TxHashes, node.BlockByNumber(first.NumberU64()).TxHashes)
+
+	for i, block := range []*Block{first, head} {
+		tracer := logger.NewStructLogger(nil)
+		require.NoError(t, node.TraceTransaction(block.TxHashes[0], tracer))
+		sstore := tracer.StructLogs()[5]
+		require.Equal(t, "SSTORE", sstore.Op.String())
+		assert.Equal(t, common.BigToHash(big.NewInt(int64(i+1))), sstore.Storage[common.Hash{}])
+	}
+	_, err = node.Close()
+	require.NoError(t, err)
+}
+
+func TestResumeAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *NodeCtx {
+		db, err := OpenDatabase(dir)
+		require.NoError(t, err)
+		node, err := NewNode(db, DefaultGenesis())
+		require.NoError(t, err)
+		return &node
+	}
+	counter := common.HexToAddress("0xc0ffee")
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+
+	// the genesis block was sealed, then flushInterval-1 blocks fill the
+	// interval and the state is flushed
+	node := open()
+	node.SetCode(counter, counterCode)
+	var flushed *Block
+	for i := 1; i < flushInterval; i++ {
+		_, _, err := node.ApplyTransaction(increment)
+		require.NoError(t, err)
+		flushed, err = node.SealBlock()
+		require.NoError(t, err)
+	}
+	_, _, err := node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	_, err = node.SealBlock()
+	require.NoError(t, err)
+
+	// the node crashes without being closed: the unflushed block is lost
+	require.NoError(t, node.db.DiskDB().Close())
+	node = open()
+	assert.Equal(t, flushed.Hash(), node.Head().Hash())
+	assert.Equal(t, common.BigToHash(big.NewInt(flushInterval-1)), node.StateDB.GetState(counter, common.Hash{}))
+	_, err = node.Close()
+	require.NoError(t, err)
+}
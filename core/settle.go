@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SettlementABI is the interface of the settlement contract of a parent chain,
+// see examples/settlement/Settlement.sol.
+const SettlementABI = `[{"type":"function","name":"settle","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"chainId","type":"uint256"},
+	{"name":"number","type":"uint256"},
+	{"name":"stateRoot","type":"bytes32"},
+	{"name":"contracts","type":"address[]"},
+	{"name":"slots","type":"bytes32[]"},
+	{"name":"values","type":"bytes32[]"}]}]`
+
+var settlementABI = mustParseABI(SettlementABI)
+
+func mustParseABI(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// Settlement is the final state of a match chain, submitted to its parent
+// chain: the state root of the last block and the values of chosen storage
+// slots, such as the match results and the items won.
+type Settlement struct {
+	ChainID   uint64        `json:"chainId"`
+	Number    uint64        `json:"number"`
+	StateRoot common.Hash   `json:"stateRoot"`
+	Storage   []SettledSlot `json:"storage"`
+}
+
+// SettledSlot is the final value of a storage slot of a match chain.
+type SettledSlot struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Value   common.Hash    `json:"value"`
+}
+
+// Settle ends the match played on the node: it seals the block being built if
+// it changed the state and returns the settlement of the head, with the values
+// of the given storage slots ordered by contract address. The node should not
+// execute transactions afterwards.
+func (n *NodeCtx) Settle(storage map[common.Address][]common.Hash) (*Settlement, error) {
+	head, err := n.sealChanges()
+	if err != nil {
+		return nil, err
+	}
+	contracts := make([]common.Address, 0, len(storage))
+	for addr := range storage {
+		contracts = append(contracts, addr)
+	}
+	sort.Slice(contracts, func(i, j int) bool { return bytes.Compare(contracts[i][:], contracts[j][:]) < 0 })
+
+	settlement := &Settlement{
+		ChainID:   n.Evm.ChainConfig().ChainID.Uint64(),
+		Number:    head.NumberU64(),
+		StateRoot: head.Header.Root,
+	}
+	for _, addr := range contracts {
+		for _, slot := range storage[addr] {
+			settlement.Storage = append(settlement.Storage, SettledSlot{
+				Address: addr,
+				Slot:    slot,
+				Value:   n.StateDB.GetState(addr, slot),
+			})
+		}
+	}
+	return settlement, nil
+}
+
+// Calldata returns the call of the settle method of the settlement contract.
+func (s *Settlement) Calldata() ([]byte, error) {
+	var (
+		contracts = make([]common.Address, len(s.Storage))
+		slots     = make([][32]byte, len(s.Storage))
+		values    = make([][32]byte, len(s.Storage))
+	)
+	for i, slot := range s.Storage {
+		contracts[i], slots[i], values[i] = slot.Address, slot.Slot, slot.Value
+	}
+	return settlementABI.Pack("settle",
+		new(big.Int).SetUint64(s.ChainID),
+		new(big.Int).SetUint64(s.Number),
+		[32]byte(s.StateRoot),
+		contracts, slots, values)
+}
+
+// Transaction returns the transaction from the given account submitting the
+// settlement to the settlement contract of the parent chain.
+func (s *Settlement) Transaction(from, contract common.Address, gas uint64) (gevmtypes.Transaction, error) {
+	data, err := s.Calldata()
+	if err != nil {
+		return gevmtypes.Transaction{}, err
+	}
+	return gevmtypes.Transaction{
+		From: from.Hex(),
+		To:   contract.Hex(),
+		Gas:  gas,
+		Data: string(data),
+	}, nil
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rootStoreCode stores the state root of a settlement at the slot of its
+// chain id: sstore(calldataload(4), calldataload(68)).
+var rootStoreCode = hexutil.MustDecode("0x6044356004355500")
+
+func TestSettle(t *testing.T) {
+	match, parent := newTestNode(), newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	match.SetCode(counter, counterCode)
+	for i := 0; i < 3; i++ {
+		_, _, err := match.ApplyTransaction(gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000})
+		require.NoError(t, err)
+	}
+
+	settlement, err := match.Settle(map[common.Address][]common.Hash{counter: {{}, common.HexToHash("0x1")}})
+	require.NoError(t, err)
+	head := match.Head()
+	assert.Len(t, head.Transactions, 3, "the pending transactions are sealed")
+	assert.Equal(t, head.NumberU64(), settlement.Number)
+	assert.Equal(t, head.Header.Root, settlement.StateRoot)
+	assert.Equal(t, []SettledSlot{
+		{Address: counter, Slot: common.Hash{}, Value: common.HexToHash("0x3")},
+		{Address: counter, Slot: common.HexToHash("0x1"), Value: common.Hash{}},
+	}, settlement.Storage)
+
+	data, err := settlement.Calldata()
+	require.NoError(t, err)
+	args, err := settlementABI.Methods["settle"].Inputs.Unpack(data[4:])
+	require.NoError(t, err)
+	assert.Equal(t, []common.Address{counter, counter}, args[3])
+	assert.Equal(t, [][32]byte{common.HexToHash("0x3"), {}}, args[5])
+
+	settlementContract := common.HexToAddress("0x5e771e")
+	parent.SetCode(settlementContract, rootStoreCode)
+	tx, err := settlement.Transaction(admin, settlementContract, 1000000)
+	require.NoError(t, err)
+	_, _, err = parent.ApplyTransaction(tx)
+	require.NoError(t, err)
+	assert.Equal(t, settlement.StateRoot, parent.StateDB.GetState(settlementContract, common.BigToHash(match.Evm.ChainConfig().ChainID)))
+}
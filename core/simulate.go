@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrSessionClosed is returned for steps run after a session was closed.
+var ErrSessionClosed = errors.New("simulation session closed")
+
+// Session is a what-if simulation on a fork of the state of the block being
+// built. Its steps change the fork only, never the node, and the session keeps
+// the fork alive for follow-up steps until it is closed. Sessions are safe for
+// concurrent use, their steps run one at a time.
+type Session struct {
+	mu     sync.Mutex
+	view   *view  // the fork
+	chain  *chain // for the hashes of past blocks
+	txs    int    // transactions run so far
+	closed bool
+}
+
+// SimStep is a step of a simulation: a transaction, whose changes the
+// following steps see, or a call, whose changes are discarded.
+type SimStep struct {
+	Tx   gevmtypes.Transaction
+	Call bool
+}
+
+// SimResult is the outcome of a step.
+type SimResult struct {
+	Output  hexutil.Bytes `json:"output"`
+	GasUsed uint64        `json:"gasUsed"`
+	Error   string        `json:"error,omitempty"`
+	Logs    []*gtypes.Log `json:"logs"`
+	Diff    StateDiff     `json:"diff"`
+}
+
+// StateDiff holds the accounts changed by a step.
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff is the change of an account. Only what changed is set.
+type AccountDiff struct {
+	Balance *Change[*hexutil.Big]               `json:"balance,omitempty"`
+	Nonce   *Change[hexutil.Uint64]             `json:"nonce,omitempty"`
+	Code    *Change[hexutil.Bytes]              `json:"code,omitempty"`
+	Storage map[common.Hash]Change[common.Hash] `json:"storage,omitempty"`
+}
+
+// Change is a value before and after a step.
+type Change[T any] struct {
+	From T `json:"from"`
+	To   T `json:"to"`
+}
+
+// Simulate starts a simulation session on a copy of the state of the block
+// being built. The copy is taken now, later changes to the node are not seen.
+func (e *Executor) Simulate() (*Session, error) {
+	v, err := e.currentView()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{view: v, chain: e.node.chain}, nil
+}
+
+// Run runs steps in order and returns the outcome of each. A step that fails
+// or reverts doesn't stop the following ones, its error is in its result.
+func (s *Session) Run(steps ...SimStep) ([]SimResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	results := make([]SimResult, len(steps))
+	for i, step := range steps {
+		results[i] = s.run(step)
+	}
+	return results, nil
+}
+
+func (s *Session) run(step SimStep) SimResult {
+	if isSystemSender(step.Tx) {
+		return SimResult{Logs: []*gtypes.Log{}, Diff: StateDiff{}, Error: ErrSystemSender.Error()}
+	}
+	var (
+		v       = s.view
+		pre     = v.statedb.Copy()
+		statedb = v.statedb
+		touched = newTouches()
+		number  = v.header.Number.Uint64()
+		index   = s.txs
+		hash    = TransactionHash(step.Tx, number, index)
+	)
+	if step.Call {
+		statedb = pre.Copy()
+	} else {
+		s.txs++
+	}
+	statedb.SetTxContext(hash, index)
+
+	config := v.config
+	config.Tracer = touched
+	evm := vm.NewEVM(NewEVMBlockContext(v.header, s.chain, nil), v.txContext, statedb, v.chainConfig, config)
+	output, gasLeft, err := execute(evm, statedb, step.Tx)
+	statedb.Finalise(false)
+
+	result := SimResult{
+		Output:  output,
+		GasUsed: step.Tx.Gas - gasLeft,
+		Logs:    statedb.GetLogs(hash, number, common.Hash{}),
+		Diff:    touched.diff(pre, statedb),
+	}
+	if result.Logs == nil {
+		result.Logs = []*gtypes.Log{}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// CodeHash returns the hash of the code of addr in the fork, the zero hash
+// once the session is closed.
+func (s *Session) CodeHash(addr common.Address) common.Hash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return common.Hash{}
+	}
+	return s.view.statedb.GetCodeHash(addr)
+}
+
+// Close discards the fork.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.view = nil
+}
+
+// touches records the accounts and storage slots a step may have changed.
+type touches struct {
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+func newTouches() *touches {
+	return &touches{accounts: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+func (t *touches) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := t.accounts[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.accounts[addr] = slots
+	}
+	return slots
+}
+
+// diff returns the changes between pre and post of the touched accounts.
+func (t *touches) diff(pre, post *gstate.StateDB) StateDiff {
+	diff := make(StateDiff)
+	for addr, slots := range t.accounts {
+		d := new(AccountDiff)
+		if from, to := pre.GetBalance(addr), post.GetBalance(addr); from.Cmp(to) != 0 {
+			d.Balance = &Change[*hexutil.Big]{From: (*hexutil.Big)(from), To: (*hexutil.Big)(to)}
+		}
+		if from, to := pre.GetNonce(addr), post.GetNonce(addr); from != to {
+			d.Nonce = &Change[hexutil.Uint64]{From: hexutil.Uint64(from), To: hexutil.Uint64(to)}
+		}
+		if from, to := pre.GetCode(addr), post.GetCode(addr); !bytes.Equal(from, to) {
+			d.Code = &Change[hexutil.Bytes]{From: from, To: to}
+		}
+		for slot := range slots {
+			if from, to := pre.GetState(addr, slot), post.GetState(addr, slot); from != to {
+				if d.Storage == nil {
+					d.Storage = make(map[common.Hash]Change[common.Hash])
+				}
+				d.Storage[slot] = Change[common.Hash]{From: from, To: to}
+			}
+		}
+		if d.Balance != nil || d.Nonce != nil || d.Code != nil || d.Storage != nil {
+			diff[addr] = d
+		}
+	}
+	return diff
+}
+
+func (t *touches) CaptureTxStart(gasLimit uint64) {}
+func (t *touches) CaptureTxEnd(restGas uint64)    {}
+
+func (t *touches) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+	t.touch(env.Context.Coinbase)
+}
+
+func (t *touches) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (t *touches) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+}
+
+func (t *touches) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *touches) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	switch op {
+	case vm.SSTORE:
+		slot := common.Hash(scope.Stack.Back(0).Bytes32())
+		t.touch(scope.Contract.Address())[slot] = struct{}{}
+	case vm.SELFDESTRUCT:
+		t.touch(common.Address(scope.Stack.Back(0).Bytes20()))
+	}
+}
+
+func (t *touches) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
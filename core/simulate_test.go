@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logCode emits an empty log.
+var logCode = hexutil.MustDecode("0x60006000a000")
+
+func TestSimulate(t *testing.T) {
+	node := newTestNode()
+	counter, logger := common.HexToAddress("0xc0ffee"), common.HexToAddress("0x109")
+	node.SetCode(counter, counterCode)
+	node.SetCode(logger, logCode)
+	exec := NewExecutor(node)
+	defer exec.Close()
+
+	session, err := exec.Simulate()
+	require.NoError(t, err)
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	results, err := session.Run(
+		SimStep{Tx: increment},
+		SimStep{Tx: increment},
+		SimStep{Tx: increment, Call: true},
+		SimStep{Tx: gevmtypes.Transaction{From: account1.Hex(), To: logger.Hex(), Gas: 100000}},
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	slot := func(r SimResult) Change[common.Hash] { return r.Diff[counter].Storage[common.Hash{}] }
+	assert.Equal(t, Change[common.Hash]{From: common.Hash{}, To: common.HexToHash("0x1")}, slot(results[0]))
+	assert.Equal(t, Change[common.Hash]{From: common.HexToHash("0x1"), To: common.HexToHash("0x2")}, slot(results[1]))
+	assert.Equal(t, Change[common.Hash]{From: common.HexToHash("0x2"), To: common.HexToHash("0x3")}, slot(results[2]))
+	assert.NotZero(t, results[0].GasUsed)
+	assert.Empty(t, results[0].Logs)
+	assert.Len(t, results[3].Logs, 1)
+	assert.Empty(t, results[3].Diff, "logs change no state")
+
+	// the session stays alive, and the call was discarded
+	results, err = session.Run(SimStep{Tx: increment})
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToHash("0x3"), slot(results[0]).To)
+
+	// the node is untouched
+	state, err := exec.State()
+	require.NoError(t, err)
+	assert.Equal(t, common.Hash{}, state.GetState(counter, common.Hash{}))
+	assert.Equal(t, crypto.Keccak256Hash(logCode), session.CodeHash(logger))
+
+	session.Close()
+	_, err = session.Run(SimStep{Tx: increment})
+	assert.ErrorIs(t, err, ErrSessionClosed)
+}
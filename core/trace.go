@@ -0,0 +1,126 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+)
+
+// ErrTxNotFound is returned when tracing a transaction the node doesn't have.
+var ErrTxNotFound = errors.New("transaction not found")
+
+// ApplyTransactionWithTracer executes txn like ApplyTransaction, with tracer
+// attached to the EVM for this transaction only. The node runs untraced
+// otherwise, so that transactions nobody traces pay nothing for tracing.
+func (n *NodeCtx) ApplyTransactionWithTracer(txn gevmtypes.Transaction, tracer vm.EVMLogger) ([]byte, uint64, error) {
+	n.Evm.Config.Tracer = tracer
+	defer func() { n.Evm.Config.Tracer = nil }()
+	return n.ApplyTransaction(txn)
+}
+
+// TraceTransaction re-executes the transaction with the given hash, sealed or
+// in the block being built, with tracer attached. The transactions before it
+// in its block are re-executed untraced first.
+func (n *NodeCtx) TraceTransaction(hash common.Hash, tracer vm.EVMLogger) error {
+	block, index := n.transactionByHash(hash)
+	if block == nil {
+		return fmt.Errorf("%w: %v", ErrTxNotFound, hash)
+	}
+	return n.replay(block, index, func(i int) vm.EVMLogger {
+		if i == index {
+			return tracer
+		}
+		return nil
+	})
+}
+
+// TraceBlock re-executes a sealed block on the state of its parent, with the
+// tracer returned by tracer(i) attached to its i-th transaction.
+func (n *NodeCtx) TraceBlock(block *Block, tracer func(i int) vm.EVMLogger) error {
+	return n.replay(block, len(block.Transactions)-1, tracer)
+}
+
+// TraceCall executes txn with tracer attached on the state of a sealed block,
+// and discards the changes it made. The outcome of the call, failed or not, is
+// recorded by the tracer.
+func (n *NodeCtx) TraceCall(block *Block, txn gevmtypes.Transaction, tracer vm.EVMLogger) error {
+	statedb, err := gstate.New(block.Header.Root, n.db, nil)
+	if err != nil {
+		return err
+	}
+	config := n.Evm.Config
+	config.Tracer, config.Witness = tracer, nil
+	evm := vm.NewEVM(NewEVMBlockContext(block.Header, n.chain, nil), n.Evm.TxContext, statedb, n.Evm.ChainConfig(), config)
+	execute(evm, statedb, txn)
+	return nil
+}
+
+// replay re-executes the transactions of block up to the one at index last on
+// the state of its parent, replaying the recorded outputs of the external
+// precompiles. Changes made to the state outside of transactions, such as
+// with the dev mode setters, are not replayed.
+func (n *NodeCtx) replay(block *Block, last int, tracer func(i int) vm.EVMLogger) error {
+	parent := n.BlockByHash(block.Header.ParentHash)
+	if parent == nil {
+		return fmt.Errorf("trace: the parent of block %d is not known", block.NumberU64())
+	}
+	statedb, err := gstate.New(parent.Header.Root, n.db, nil)
+	if err != nil {
+		return err
+	}
+	witness := vm.NewReplayWitness(block.Witness)
+	config := n.Evm.Config
+	config.Witness = witness
+	evm := vm.NewEVM(NewEVMBlockContext(block.Header, n.chain, nil), n.Evm.TxContext, statedb, n.Evm.ChainConfig(), config)
+
+	for i := 0; i <= last && i < len(block.Transactions); i++ {
+		txn := block.Transactions[i]
+		statedb.SetTxContext(block.TxHashes[i], i)
+		witness.SetTx(i)
+		evm.Config.Tracer = tracer(i)
+		if isSeedTransaction(txn) {
+			handleSeedTransaction(statedb, txn)
+		} else {
+			// failed transactions are part of the block like any other
+			execute(evm, statedb, txn)
+		}
+		statedb.Finalise(false)
+	}
+	return nil
+}
+
+// transactionByHash returns the block holding the transaction with the given
+// hash, the block being built included, and its index in the block. The
+// location in the index is checked against the block, since the block being
+// built may have been discarded since, such as by a rejected import.
+func (n *NodeCtx) transactionByHash(hash common.Hash) (*Block, int) {
+	loc, ok := n.chain.txIndex[hash]
+	if !ok {
+		return nil, 0
+	}
+	var block *Block
+	if loc.number == n.chain.pending.Number.Uint64() {
+		block = n.pendingBlock()
+	} else {
+		block = n.BlockByNumber(loc.number)
+	}
+	if block == nil || loc.index >= len(block.TxHashes) || block.TxHashes[loc.index] != hash {
+		return nil, 0
+	}
+	return block, loc.index
+}
+
+// pendingBlock returns the block being built as it is now.
+func (n *NodeCtx) pendingBlock() *Block {
+	return &Block{
+		Header:       types.CopyHeader(n.chain.pending),
+		Transactions: n.chain.txs,
+		TxHashes:     n.chain.hashes,
+		Witness:      n.chain.witness.Entries,
+	}
+}
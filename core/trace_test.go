@@ -0,0 +1,130 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/logger"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingIsOffByDefault(t *testing.T) {
+	node := newTestNode()
+	assert.Nil(t, node.Evm.Config.Tracer)
+}
+
+func TestApplyTransactionWithTracer(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	exec := NewExecutor(node)
+	defer exec.Close()
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+
+	tracer := logger.NewStructLogger(nil)
+	_, gasLeft, err := exec.ApplyTransactionWithTracer(increment, tracer)
+	require.NoError(t, err)
+	assert.Len(t, tracer.StructLogs(), 7, "one log per opcode of counterCode")
+	result, err := tracer.GetResult()
+	require.NoError(t, err)
+	assert.Contains(t, string(result), `"gas":22112`)
+	assert.Equal(t, uint64(100000-22112), gasLeft)
+
+	// the tracer is detached after the transaction
+	_, _, err = exec.ApplyTransaction(increment)
+	require.NoError(t, err)
+	assert.Len(t, tracer.StructLogs(), 7)
+
+	call := logger.NewStructLogger(&logger.Config{Limit: 2})
+	_, _, err = exec.CallWithTracer(increment, call)
+	require.NoError(t, err)
+	assert.Len(t, call.StructLogs(), 2)
+}
+
+func TestExecutorTraceTransaction(t *testing.T) {
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	_, err := node.SealBlock()
+	require.NoError(t, err)
+	exec := NewExecutor(node)
+	defer exec.Close()
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	for i := 0; i < 2; i++ {
+		_, _, err = exec.ApplyTransaction(increment)
+		require.NoError(t, err)
+	}
+
+	var hashes []common.Hash
+	require.NoError(t, exec.Do(func(n *NodeCtx) error {
+		hashes = n.PendingTxHashes()
+		return nil
+	}))
+	require.Len(t, hashes, 2)
+	tracer := logger.NewStructLogger(nil)
+	require.NoError(t, exec.TraceTransaction(hashes[1], tracer))
+	assert.Equal(t, common.HexToHash("0x2"), tracer.StructLogs()[5].Storage[common.Hash{}])
+	assert.Equal(t, common.HexToHash("0x2"), node.StateDB.GetState(counter, common.Hash{}), "the node is left as it is")
+}
+
+func TestTraceTransactionAndBlock(t *testing.T) {
+	setWeather(data.Sunny)
+	node := newTestNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.SetCode(counter, counterCode)
+	_, err := node.SealBlock()
+	require.NoError(t, err)
+
+	// the weather read is replayed from the witness when tracing
+	created, _, err := node.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), Gas: 1000000, Data: string(weatherStoreCode)})
+	require.NoError(t, err)
+	weather := common.BytesToAddress(created)
+	increment := gevmtypes.Transaction{From: account1.Hex(), To: counter.Hex(), Gas: 100000}
+	for i := 0; i < 2; i++ {
+		_, _, err = node.ApplyTransaction(increment)
+		require.NoError(t, err)
+	}
+	_, _, err = node.ApplyTransaction(gevmtypes.Transaction{From: admin.Hex(), To: weather.Hex(), Gas: 1000000})
+	require.NoError(t, err)
+	block, err := node.SealBlock()
+	require.NoError(t, err)
+	setWeather(data.Windy)
+
+	// the second increment sees the first one
+	tracer := logger.NewStructLogger(nil)
+	require.NoError(t, node.TraceTransaction(block.TxHashes[2], tracer))
+	sstore := tracer.StructLogs()[5]
+	require.Equal(t, "SSTORE", sstore.Op.String())
+	assert.Equal(t, common.HexToHash("0x2"), sstore.Storage[common.Hash{}])
+
+	tracers := make([]*logger.StructLogger, len(block.Transactions))
+	require.NoError(t, node.TraceBlock(block, func(i int) vm.EVMLogger {
+		tracers[i] = logger.NewStructLogger(nil)
+		return tracers[i]
+	}))
+	for i, tracer := range tracers {
+		assert.NoError(t, tracer.Error(), "transaction %d", i)
+	}
+	logs := tracers[3].StructLogs()
+	last := logs[len(logs)-2]
+	require.Equal(t, "SSTORE", last.Op.String())
+	assert.Equal(t, weatherHash(data.Sunny), last.Storage[common.Hash{}], "the recorded weather")
+
+	// transactions of the block being built can be traced too
+	_, _, err = node.ApplyTransaction(increment)
+	require.NoError(t, err)
+	tracer = logger.NewStructLogger(nil)
+	require.NoError(t, node.TraceTransaction(node.chain.hashes[0], tracer))
+	assert.Equal(t, common.HexToHash("0x3"), tracer.StructLogs()[5].Storage[common.Hash{}])
+
+	assert.ErrorIs(t, node.TraceTransaction(common.HexToHash("0x1234"), tracer), ErrTxNotFound)
+
+	// calls on a past block see its state
+	call := logger.NewStructLogger(nil)
+	require.NoError(t, node.TraceCall(node.BlockByNumber(block.NumberU64()-1), increment, call))
+	assert.Equal(t, common.HexToHash("0x1"), call.StructLogs()[5].Storage[common.Hash{}])
+}
@@ -0,0 +1,208 @@
+// Package coverage records the instructions and branches of contracts run by
+// executions, and maps them to line and branch coverage of their Solidity
+// sources in the LCOV format.
+package coverage
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Branch is the outcomes of a JUMPI.
+type Branch struct {
+	Taken    uint64 // times it jumped
+	NotTaken uint64 // times it went on
+}
+
+// Code is the coverage of a contract code, deployed or initialization code.
+type Code struct {
+	Hash     common.Hash
+	Code     []byte
+	Contract *sourcemap.Contract // nil if not registered
+
+	Hits     []uint64           // executions of the instruction at each pc
+	Branches map[uint64]*Branch // by pc of the JUMPI, for the ones run
+}
+
+func newCode(hash common.Hash, code []byte, contract *sourcemap.Contract) *Code {
+	return &Code{
+		Hash:     hash,
+		Code:     code,
+		Contract: contract,
+		Hits:     make([]uint64, len(code)),
+		Branches: make(map[uint64]*Branch),
+	}
+}
+
+// instructions returns the pcs of the instructions of code.
+func instructions(code []byte) []uint64 {
+	var pcs []uint64
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		pcs = append(pcs, pc)
+		if op := vm.OpCode(code[pc]); op.IsPush() {
+			pc += uint64(op - vm.PUSH1 + 1)
+		}
+	}
+	return pcs
+}
+
+// Instructions returns the number of instructions of the code and of the ones
+// run.
+func (c *Code) Instructions() (total, covered int) {
+	for _, pc := range instructions(c.Code) {
+		total++
+		if c.Hits[pc] > 0 {
+			covered++
+		}
+	}
+	return total, covered
+}
+
+// BranchOutcomes returns the number of outcomes of the JUMPIs of the code, two
+// per JUMPI, and of the ones seen.
+func (c *Code) BranchOutcomes() (total, covered int) {
+	for _, pc := range instructions(c.Code) {
+		if vm.OpCode(c.Code[pc]) != vm.JUMPI {
+			continue
+		}
+		total += 2
+		if b := c.Branches[pc]; b != nil {
+			if b.Taken > 0 {
+				covered++
+			}
+			if b.NotTaken > 0 {
+				covered++
+			}
+		}
+	}
+	return total, covered
+}
+
+// Coverage is an EVM logger recording the coverage of the code run by the
+// executions it is attached to, by code hash, so that the deployments of a
+// code add up. The coverage of the contracts of its registry is mapped to
+// their sources.
+//
+// A Coverage may be attached to several executions one after the other, such
+// as the transactions of a test suite, but not concurrently.
+type Coverage struct {
+	registry *sourcemap.Registry
+	env      *vm.EVM
+	calls    []*Code // code run by the calls being executed
+
+	codes map[common.Hash]*Code
+}
+
+// New returns a coverage recorder mapping the contracts of registry, which
+// may be nil, to their sources.
+func New(registry *sourcemap.Registry) *Coverage {
+	return &Coverage{registry: registry, codes: make(map[common.Hash]*Code)}
+}
+
+// Codes returns the coverage of the codes run, registered contracts first,
+// sorted by name, then by hash.
+func (c *Coverage) Codes() []*Code {
+	codes := make([]*Code, 0, len(c.codes))
+	for _, code := range c.codes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, b := codes[i], codes[j]
+		if (a.Contract == nil) != (b.Contract == nil) {
+			return a.Contract != nil
+		}
+		if a.Contract != nil && a.Contract.Name != b.Contract.Name {
+			return a.Contract.Name < b.Contract.Name
+		}
+		return bytes.Compare(a.Hash[:], b.Hash[:]) < 0
+	})
+	return codes
+}
+
+// Reset discards the coverage recorded.
+func (c *Coverage) Reset() {
+	c.codes = make(map[common.Hash]*Code)
+}
+
+func (c *Coverage) CaptureTxStart(gasLimit uint64) {}
+
+func (c *Coverage) CaptureTxEnd(restGas uint64) {}
+
+func (c *Coverage) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	c.env = env
+	c.calls = c.calls[:0]
+	c.enter(to, create, input)
+}
+
+func (c *Coverage) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	c.exit()
+}
+
+func (c *Coverage) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	c.enter(to, typ == vm.CREATE || typ == vm.CREATE2, input)
+}
+
+func (c *Coverage) CaptureExit(output []byte, gasUsed uint64, err error) {
+	c.exit()
+}
+
+func (c *Coverage) enter(to common.Address, create bool, input []byte) {
+	code := input // the initialization code of created contracts
+	if !create {
+		code = c.env.StateDB.GetCode(to)
+	}
+	if len(code) == 0 {
+		// precompiles and accounts without code
+		c.calls = append(c.calls, nil)
+		return
+	}
+	hash := crypto.Keccak256Hash(code)
+	cov, ok := c.codes[hash]
+	if !ok {
+		var contract *sourcemap.Contract
+		if c.registry != nil && !create {
+			contract = c.registry.Lookup(code)
+		}
+		cov = newCode(hash, common.CopyBytes(code), contract)
+		c.codes[hash] = cov
+	}
+	c.calls = append(c.calls, cov)
+}
+
+func (c *Coverage) exit() {
+	if len(c.calls) > 0 {
+		c.calls = c.calls[:len(c.calls)-1]
+	}
+}
+
+func (c *Coverage) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if len(c.calls) == 0 {
+		return
+	}
+	code := c.calls[len(c.calls)-1]
+	if code == nil || pc >= uint64(len(code.Hits)) {
+		return
+	}
+	code.Hits[pc]++
+	if op == vm.JUMPI && len(scope.Stack.Data()) >= 2 {
+		b := code.Branches[pc]
+		if b == nil {
+			b = new(Branch)
+			code.Branches[pc] = b
+		}
+		if cond := scope.Stack.Back(1); cond.IsZero() {
+			b.NotTaken++
+		} else {
+			b.Taken++
+		}
+	}
+}
+
+func (c *Coverage) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,135 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/daweth/gevm/internal/evmtest"
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var gate = common.HexToAddress("0x6a7e")
+
+const gateSource = `contract Gate {
+    function open(uint256 key) external returns (uint256) {
+        if (key == 42) {
+            return 1;
+        }
+        return 0;
+    }
+}
+`
+
+// gateCode is Gate compiled by hand, without a dispatcher.
+var gateCode = common.FromHex("0x" +
+	"6004" + "35" + "602a" + "14" + "600e" + "57" + // if (calldata[4] == 42)
+	"6000" + "6000" + "f3" + // return 0 at pc 9
+	"5b" + "6020" + "6000" + "f3") // return 1 at pc 14
+
+func gateArtifact() *sourcemap.Artifact {
+	span := func(text string) string {
+		return fmt.Sprintf("%d:%d:0:-", strings.Index(gateSource, text), len(text))
+	}
+	var (
+		open = span(gateSource[strings.Index(gateSource, "function") : strings.LastIndex(gateSource, "}\n}")+1])
+		cond = span("key == 42")
+		stmt = span("if (key == 42) {\n            return 1;\n        }")
+		ret0 = span("return 0")
+		ret1 = span("return 1")
+	)
+	return &sourcemap.Artifact{
+		Name:      "Gate",
+		Code:      gateCode,
+		SourceMap: strings.Join([]string{open, cond, cond, cond, stmt, stmt, ret0, ret0, ret0, ret1, ret1, ret1, ret1}, ";"),
+		Sources:   []sourcemap.Source{{Name: "Gate.sol", Content: gateSource}},
+	}
+}
+
+// run calls the gate with key, with tracer attached.
+func run(t *testing.T, tracer vm.EVMLogger, key int64) {
+	input := append(make([]byte, 4), common.BigToHash(big.NewInt(key)).Bytes()...)
+	_, _, err := evmtest.Run(t, map[common.Address][]byte{gate: gateCode}, tracer, gate, input)
+	require.NoError(t, err)
+}
+
+func TestBytecodeCoverage(t *testing.T) {
+	cov := New(nil)
+	run(t, cov, 42)
+	codes := cov.Codes()
+	require.Len(t, codes, 1)
+	assert.Nil(t, codes[0].Contract)
+
+	total, covered := codes[0].Instructions()
+	assert.Equal(t, 13, total)
+	assert.Equal(t, 10, covered)
+	total, covered = codes[0].BranchOutcomes()
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, covered)
+	assert.Equal(t, &Branch{Taken: 1}, codes[0].Branches[8])
+
+	run(t, cov, 1)
+	_, covered = codes[0].Instructions()
+	assert.Equal(t, 13, covered)
+	_, covered = codes[0].BranchOutcomes()
+	assert.Equal(t, 2, covered)
+
+	cov.Reset()
+	assert.Empty(t, cov.Codes())
+}
+
+func TestLCOV(t *testing.T) {
+	r := sourcemap.NewRegistry()
+	require.NoError(t, r.Add(gateArtifact()))
+	require.NoError(t, r.Add(&sourcemap.Artifact{
+		Name:      "Unused",
+		Code:      common.FromHex("0x600000"),
+		SourceMap: "0:18:0:-;:",
+		Sources:   []sourcemap.Source{{Name: "Unused.sol", Content: "contract Unused {}\n"}},
+	}))
+	cov := New(r)
+	run(t, cov, 42)
+
+	var out bytes.Buffer
+	require.NoError(t, cov.WriteLCOV(&out))
+	assert.Equal(t, `TN:
+SF:Gate.sol
+FN:2,open
+FNDA:1,open
+FNF:1
+FNH:1
+BRDA:3,0,0,1
+BRDA:3,0,1,0
+BRF:2
+BRH:1
+DA:2,1
+DA:3,1
+DA:4,1
+DA:6,0
+LF:4
+LH:3
+end_of_record
+TN:
+SF:Unused.sol
+FNF:0
+FNH:0
+BRF:0
+BRH:0
+DA:1,0
+LF:1
+LH:0
+end_of_record
+`, out.String())
+
+	run(t, cov, 1)
+	out.Reset()
+	require.NoError(t, cov.WriteLCOV(&out))
+	assert.Contains(t, out.String(), "BRDA:3,0,1,1\n")
+	assert.Contains(t, out.String(), "DA:3,2\nDA:4,1\nDA:6,1\nLF:4\nLH:4\n")
+}
@@ -0,0 +1,180 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fileCoverage is the coverage of a source file.
+type fileCoverage struct {
+	lines     map[int]uint64       // hits by instrumented line
+	functions map[function]uint64  // hits by function
+	branches  map[int][]lcovBranch // JUMPIs by line
+}
+
+type function struct {
+	line int
+	name string
+}
+
+type lcovBranch struct {
+	reached bool
+	Branch
+}
+
+// sources maps the coverage of the registered contracts, run or not, to the
+// lines of their sources. A line is hit as often as the instruction of the line
+// run the most; the hits of the contracts sharing a source add up.
+func (c *Coverage) sources() map[string]*fileCoverage {
+	codes := c.Codes()
+	if c.registry != nil {
+		for _, contract := range c.registry.Contracts() {
+			if hash := crypto.Keccak256Hash(contract.Code); c.codes[hash] == nil {
+				codes = append(codes, newCode(hash, contract.Code, contract))
+			}
+		}
+	}
+	files := make(map[string]*fileCoverage)
+	file := func(name string) *fileCoverage {
+		f, ok := files[name]
+		if !ok {
+			f = &fileCoverage{
+				lines:     make(map[int]uint64),
+				functions: make(map[function]uint64),
+				branches:  make(map[int][]lcovBranch),
+			}
+			files[name] = f
+		}
+		return f
+	}
+	for _, code := range codes {
+		if code.Contract == nil {
+			continue
+		}
+		lines := make(map[string]map[int]uint64)
+		functions := make(map[string]map[function]uint64)
+		for _, pc := range instructions(code.Code) {
+			loc, ok := code.Contract.Location(pc)
+			if !ok {
+				continue
+			}
+			hits := code.Hits[pc]
+			if lines[loc.File] == nil {
+				lines[loc.File] = make(map[int]uint64)
+				functions[loc.File] = make(map[function]uint64)
+			}
+			if hits >= lines[loc.File][loc.Line] {
+				lines[loc.File][loc.Line] = hits
+			}
+			if name := code.Contract.Function(pc); name != "" {
+				fn := function{line: loc.Line, name: name}
+				if hits >= functions[loc.File][fn] {
+					functions[loc.File][fn] = hits
+				}
+			}
+			if vm.OpCode(code.Code[pc]) == vm.JUMPI {
+				b := lcovBranch{reached: hits > 0}
+				if run := code.Branches[pc]; run != nil {
+					b.Branch = *run
+				}
+				f := file(loc.File)
+				f.branches[loc.Line] = append(f.branches[loc.Line], b)
+			}
+		}
+		for name, hits := range lines {
+			f := file(name)
+			for line, n := range hits {
+				f.lines[line] += n
+			}
+			for fn, n := range functions[name] {
+				f.functions[fn] += n
+			}
+		}
+	}
+	return files
+}
+
+// WriteLCOV writes the line, function and branch coverage of the sources of
+// the registered contracts in the LCOV tracefile format, for genhtml or the
+// coverage views of editors and CI. Each JUMPI is a branch block with two
+// branches, jumping and going on.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	files := c.sources()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := files[name]
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", name)
+
+		functions := make([]function, 0, len(f.functions))
+		for fn := range f.functions {
+			functions = append(functions, fn)
+		}
+		sort.Slice(functions, func(i, j int) bool {
+			if functions[i].line != functions[j].line {
+				return functions[i].line < functions[j].line
+			}
+			return functions[i].name < functions[j].name
+		})
+		for _, fn := range functions {
+			fmt.Fprintf(bw, "FN:%d,%s\n", fn.line, fn.name)
+		}
+		hit := 0
+		for _, fn := range functions {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", f.functions[fn], fn.name)
+			if f.functions[fn] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(functions), hit)
+
+		lines := sortedLines(f.branches)
+		found, hit, block := 0, 0, 0
+		for _, line := range lines {
+			for _, b := range f.branches[line] {
+				for branch, taken := range []uint64{b.Taken, b.NotTaken} {
+					count := "-"
+					if b.reached {
+						count = fmt.Sprint(taken)
+					}
+					fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", line, block, branch, count)
+					found++
+					if taken > 0 {
+						hit++
+					}
+				}
+				block++
+			}
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", found, hit)
+
+		lines = sortedLines(f.lines)
+		hit = 0
+		for _, line := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, f.lines[line])
+			if f.lines[line] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	return bw.Flush()
+}
+
+func sortedLines[V any](m map[int]V) []int {
+	lines := make([]int, 0, len(m))
+	for line := range m {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
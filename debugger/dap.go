@@ -0,0 +1,783 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Target runs the executions debugged by the sessions of a Server.
+type Target interface {
+	// Run executes what the arguments of a launch request describe, with
+	// tracer attached to the EVM, and returns the output.
+	Run(args json.RawMessage, tracer vm.EVMLogger) ([]byte, error)
+	// Code returns the code deployed at addr.
+	Code(addr common.Address) ([]byte, error)
+}
+
+// Server serves the Debug Adapter Protocol, so that editors can debug the EVM
+// like they debug programs. A session debugs the execution described by its
+// launch request, with one thread, the EVM, whose stack frames are the calls
+// being executed. The code of a contract is shown disassembled, one
+// instruction per line, in a source named after its address; breakpoints are:
+//
+//   - source breakpoints on the lines of a disassembled contract
+//   - function breakpoints named after an opcode, e.g. SSTORE
+//   - instruction breakpoints with the reference "<address>:<pc>"
+//   - the "fault" exception breakpoint, on failed and reverted opcodes
+//
+// The stack, memory, return data and touched storage of a paused call are its
+// variables, and evaluating a slot number returns its value in storage.
+type Server struct {
+	target Target
+
+	mu        sync.Mutex // protects listeners, sessions and closed
+	listeners map[net.Listener]struct{}
+	sessions  map[*session]io.Closer // the connection of each session
+	closed    bool
+}
+
+// NewServer returns a server debugging the executions run by target.
+func NewServer(target Target) *Server {
+	return &Server{
+		target:    target,
+		listeners: make(map[net.Listener]struct{}),
+		sessions:  make(map[*session]io.Closer),
+	}
+}
+
+// Close stops the server: it closes its listeners, detaches the debugger of
+// every session, so that paused executions run to their end, and closes their
+// connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var errs []error
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+		delete(s.listeners, l)
+	}
+	for sess, conn := range s.sessions {
+		sess.dbg.Detach()
+		conn.Close()
+		delete(s.sessions, sess)
+	}
+	return errors.Join(errs...)
+}
+
+// Serve runs a session for each connection accepted on l, until l or the
+// server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn runs a session on conn until the client disconnects or the server
+// is closed.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	sess := &session{
+		target:  s.target,
+		dbg:     New(),
+		w:       conn,
+		sources: make(map[int]*source),
+		refs:    make(map[sourceKey]int),
+		lines:   make(map[common.Address][]Location),
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.sessions[sess] = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		var msg message
+		data, err := readMessage(r)
+		if err == nil {
+			err = json.Unmarshal(data, &msg)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Debug("DAP session ended", "err", err)
+			}
+			sess.dbg.Detach()
+			return
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		if !sess.handle(&msg) {
+			return
+		}
+	}
+}
+
+const threadID = 1
+
+// message is a request of the client.
+type message struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// readMessage reads a message framed by a Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("dap: invalid Content-Length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// sourceKey identifies the code of a contract, since the address of a created
+// contract runs its initialization code first.
+type sourceKey struct {
+	address common.Address
+	code    common.Hash
+}
+
+// source is a disassembled contract.
+type source struct {
+	ref     int
+	address common.Address
+	ins     []Instruction
+}
+
+func (src *source) dap() map[string]interface{} {
+	return map[string]interface{}{"name": src.address.Hex(), "sourceReference": src.ref}
+}
+
+// line returns the line of the instruction at pc, 0 if there is none.
+func (src *source) line(pc uint64) int {
+	for i, in := range src.ins {
+		if in.PC == pc {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+type session struct {
+	target Target
+	dbg    *Debugger
+
+	wmu sync.Mutex
+	w   io.Writer
+	seq int
+
+	launch     json.RawMessage
+	configured bool
+	started    bool
+
+	smu     sync.Mutex
+	sources map[int]*source
+	refs    map[sourceKey]int
+
+	lines        map[common.Address][]Location // source breakpoints by contract
+	instructions []Location                    // instruction breakpoints
+
+	done chan struct{} // closed when the execution ends
+}
+
+func (s *session) send(msg interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Error("DAP message not encoded", "err", err)
+		return
+	}
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *session) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+// handle answers a request, and returns false if the session ends.
+func (s *session) handle(req *message) bool {
+	body, err := s.dispatch(req)
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(resp)
+
+	switch req.Command {
+	case "initialize":
+		s.event("initialized", nil)
+	case "launch", "configurationDone":
+		if err == nil && s.launch != nil && s.configured && !s.started {
+			s.start()
+		}
+	case "disconnect":
+		return false
+	}
+	return true
+}
+
+func (s *session) dispatch(req *message) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsInstructionBreakpoints":   true,
+			"supportsTerminateRequest":         true,
+			"exceptionBreakpointFilters": []map[string]interface{}{
+				{"filter": "fault", "label": "Failed and reverted opcodes"},
+			},
+		}, nil
+	case "launch":
+		var args struct {
+			StopOnEntry bool `json:"stopOnEntry"`
+		}
+		if err := unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if args.StopOnEntry {
+			s.dbg.StopOnEntry()
+		}
+		s.launch = req.Arguments
+		if s.launch == nil {
+			s.launch = json.RawMessage("{}")
+		}
+		return nil, nil
+	case "configurationDone":
+		s.configured = true
+		return nil, nil
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "setFunctionBreakpoints":
+		return s.setFunctionBreakpoints(req.Arguments)
+	case "setInstructionBreakpoints":
+		return s.setInstructionBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		var args struct {
+			Filters []string `json:"filters"`
+		}
+		if err := unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		fault := false
+		for _, f := range args.Filters {
+			fault = fault || f == "fault"
+		}
+		s.dbg.StopOnFault(fault)
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{"threads": []map[string]interface{}{{"id": threadID, "name": "EVM"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "source":
+		return s.source(req.Arguments)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.dbg.Continue()
+	case "next":
+		return nil, s.dbg.StepOver()
+	case "stepIn":
+		return nil, s.dbg.StepIn()
+	case "stepOut":
+		return nil, s.dbg.StepOut()
+	case "pause":
+		s.dbg.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.dbg.Detach()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request %q", req.Command)
+	}
+}
+
+func unmarshal(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// start runs the execution, reporting its stops to the client.
+func (s *session) start() {
+	s.started = true
+	go func() {
+		for {
+			select {
+			case stop := <-s.dbg.Stops():
+				body := map[string]interface{}{"reason": stop.Reason, "threadId": threadID, "allThreadsStopped": true}
+				if stop.Err != nil {
+					body["text"] = stop.Err.Error()
+				}
+				s.event("stopped", body)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(s.done)
+		output, err := s.target.Run(s.launch, s.dbg)
+		text := fmt.Sprintf("output: %s\n", hexutil.Encode(output))
+		exitCode := 0
+		if err != nil {
+			text += fmt.Sprintf("error: %v\n", err)
+			exitCode = 1
+		}
+		s.event("output", map[string]interface{}{"category": "console", "output": text})
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// sourceOf returns the disassembly of the contract at addr, code if it is not
+// nil, otherwise the code deployed at addr.
+func (s *session) sourceOf(addr common.Address, code []byte) (*source, error) {
+	if code == nil {
+		var err error
+		if code, err = s.code(addr); err != nil {
+			return nil, err
+		}
+	}
+	key := sourceKey{address: addr, code: crypto.Keccak256Hash(code)}
+
+	s.smu.Lock()
+	defer s.smu.Unlock()
+	if ref, ok := s.refs[key]; ok {
+		return s.sources[ref], nil
+	}
+	src := &source{ref: len(s.sources) + 1, address: addr, ins: Disassemble(code)}
+	s.sources[src.ref] = src
+	s.refs[key] = src.ref
+	return src, nil
+}
+
+// code returns the code deployed at addr. Once the execution started, it is
+// read from its state while it is paused: the target may not be able to read
+// its state before the execution ends.
+func (s *session) code(addr common.Address) ([]byte, error) {
+	if s.started {
+		return s.dbg.Code(addr)
+	}
+	return s.target.Code(addr)
+}
+
+type dapSource struct {
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	SourceReference int    `json:"sourceReference"`
+}
+
+// lookup returns the source of a request, known by its reference or named
+// after the address of the contract.
+func (s *session) lookup(ds dapSource) (*source, error) {
+	s.smu.Lock()
+	src, ok := s.sources[ds.SourceReference]
+	s.smu.Unlock()
+	if ok {
+		return src, nil
+	}
+	name := ds.Name
+	if name == "" {
+		name = ds.Path[strings.LastIndexAny(ds.Path, `/\`)+1:]
+	}
+	name = strings.TrimSuffix(name, ".evm")
+	if !common.IsHexAddress(name) {
+		return nil, fmt.Errorf("unknown source %q", name)
+	}
+	return s.sourceOf(common.HexToAddress(name), nil)
+}
+
+func (s *session) setBreakpoints(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	src, err := s.lookup(args.Source)
+	if err != nil {
+		return nil, err
+	}
+	// the breakpoints of a source replace its previous ones
+	for _, loc := range s.lines[src.address] {
+		s.dbg.ClearBreakpoint(loc)
+	}
+	s.lines[src.address] = nil
+	breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		verified := bp.Line >= 1 && bp.Line <= len(src.ins)
+		if verified {
+			loc := Location{Address: src.address, PC: src.ins[bp.Line-1].PC}
+			s.dbg.SetBreakpoint(loc)
+			s.lines[src.address] = append(s.lines[src.address], loc)
+		}
+		breakpoints[i] = map[string]interface{}{"verified": verified, "line": bp.Line, "source": src.dap()}
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *session) setFunctionBreakpoints(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	var ops []vm.OpCode
+	breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		name := strings.ToUpper(strings.TrimSpace(bp.Name))
+		op := vm.StringToOp(name)
+		verified := op.String() == name
+		if verified {
+			ops = append(ops, op)
+		}
+		breakpoints[i] = map[string]interface{}{"verified": verified}
+		if !verified {
+			breakpoints[i]["message"] = fmt.Sprintf("unknown opcode %q", bp.Name)
+		}
+	}
+	s.dbg.SetOpcodeBreakpoints(ops...)
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// instructionReference identifies the instruction at loc.
+func instructionReference(loc Location) string {
+	return fmt.Sprintf("%s:%d", loc.Address.Hex(), loc.PC)
+}
+
+func parseInstructionReference(ref string, offset int) (Location, error) {
+	addr, pc, ok := strings.Cut(ref, ":")
+	if !ok || !common.IsHexAddress(addr) {
+		return Location{}, fmt.Errorf("invalid instruction reference %q", ref)
+	}
+	n, err := strconv.ParseUint(pc, 10, 64)
+	if err != nil || int64(n)+int64(offset) < 0 {
+		return Location{}, fmt.Errorf("invalid instruction reference %q", ref)
+	}
+	return Location{Address: common.HexToAddress(addr), PC: uint64(int64(n) + int64(offset))}, nil
+}
+
+func (s *session) setInstructionBreakpoints(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+		} `json:"breakpoints"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	for _, loc := range s.instructions {
+		s.dbg.ClearBreakpoint(loc)
+	}
+	s.instructions = nil
+	breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		loc, err := parseInstructionReference(bp.InstructionReference, bp.Offset)
+		if err != nil {
+			breakpoints[i] = map[string]interface{}{"verified": false, "message": err.Error()}
+			continue
+		}
+		s.dbg.SetBreakpoint(loc)
+		s.instructions = append(s.instructions, loc)
+		breakpoints[i] = map[string]interface{}{"verified": true, "instructionReference": instructionReference(loc)}
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *session) stopped() (*Stop, error) {
+	stop := s.dbg.Stopped()
+	if stop == nil {
+		return nil, ErrNotPaused
+	}
+	return stop, nil
+}
+
+func (s *session) stackTrace() (interface{}, error) {
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]map[string]interface{}, 0, len(stop.Frames))
+	for i := len(stop.Frames) - 1; i >= 0; i-- {
+		f := stop.Frames[i]
+		name := fmt.Sprintf("%v %s", f.Type, f.Address.Hex())
+		if len(f.Input) >= 4 && f.Type != vm.CREATE && f.Type != vm.CREATE2 {
+			name += fmt.Sprintf(" %#x", f.Input[:4])
+		}
+		frame := map[string]interface{}{
+			"id":                          i + 1,
+			"name":                        name,
+			"line":                        0,
+			"column":                      0,
+			"instructionPointerReference": instructionReference(f.Location()),
+		}
+		code := f.Code
+		if code == nil {
+			code = []byte{} // a precompile
+		}
+		if src, err := s.sourceOf(f.CodeAddress, code); err == nil {
+			frame["source"] = src.dap()
+			if line := src.line(f.PC); line > 0 {
+				frame["line"], frame["column"] = line, 1
+			}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// The variables of a frame are referenced by frame id * scopes + scope.
+const (
+	scopeCall = iota + 1
+	scopeStack
+	scopeMemory
+	scopeReturnData
+	scopeStorage
+	scopes
+)
+
+var scopeNames = map[int]string{
+	scopeCall:       "Call",
+	scopeStack:      "Stack",
+	scopeMemory:     "Memory",
+	scopeReturnData: "Return data",
+	scopeStorage:    "Storage",
+}
+
+func (s *session) scopes(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	kinds := []int{scopeCall, scopeStorage}
+	if args.FrameID == len(stop.Frames) {
+		// the stack and memory of the callers are not kept
+		kinds = []int{scopeCall, scopeStack, scopeMemory, scopeReturnData, scopeStorage}
+	}
+	list := make([]map[string]interface{}, len(kinds))
+	for i, kind := range kinds {
+		list[i] = map[string]interface{}{
+			"name":               scopeNames[kind],
+			"variablesReference": args.FrameID*scopes + kind,
+			"expensive":          false,
+		}
+	}
+	return map[string]interface{}{"scopes": list}, nil
+}
+
+func variable(name, value string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "value": value, "variablesReference": 0}
+}
+
+// words lists data in 32-byte words named after their offset.
+func words(data []byte) []map[string]interface{} {
+	var vars []map[string]interface{}
+	for off := 0; off < len(data); off += 32 {
+		end := off + 32
+		if end > len(data) {
+			end = len(data)
+		}
+		vars = append(vars, variable(fmt.Sprintf("%#04x", off), hexutil.Encode(data[off:end])))
+	}
+	return vars
+}
+
+func (s *session) variables(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	id, kind := args.VariablesReference/scopes, args.VariablesReference%scopes
+	if id < 1 || id > len(stop.Frames) {
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	f := stop.Frames[id-1]
+	vars := []map[string]interface{}{}
+	switch kind {
+	case scopeCall:
+		value := new(big.Int)
+		if f.Value != nil {
+			value = f.Value
+		}
+		vars = append(vars,
+			variable("type", f.Type.String()),
+			variable("caller", f.Caller.Hex()),
+			variable("address", f.Address.Hex()),
+			variable("codeAddress", f.CodeAddress.Hex()),
+			variable("value", value.String()),
+			variable("input", hexutil.Encode(f.Input)),
+			variable("pc", strconv.FormatUint(f.PC, 10)),
+			variable("op", f.Op.String()),
+			variable("gas", strconv.FormatUint(f.Gas, 10)),
+		)
+	case scopeStack:
+		for i := len(stop.Stack) - 1; i >= 0; i-- {
+			vars = append(vars, variable(strconv.Itoa(len(stop.Stack)-1-i), stop.Stack[i].Hex()))
+		}
+	case scopeMemory:
+		vars = append(vars, words(stop.Memory)...)
+	case scopeReturnData:
+		vars = append(vars, words(stop.ReturnData)...)
+	case scopeStorage:
+		for _, slot := range s.dbg.TouchedSlots(f.Address) {
+			value, err := s.dbg.Storage(f.Address, slot)
+			if err != nil {
+				return nil, err
+			}
+			vars = append(vars, variable(slot.Hex(), value.Hex()))
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+// evaluate returns the value of a storage slot, given as a number, of the
+// contract of a frame.
+func (s *session) evaluate(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	stop, err := s.stopped()
+	if err != nil {
+		return nil, err
+	}
+	id := args.FrameID
+	if id < 1 || id > len(stop.Frames) {
+		id = len(stop.Frames)
+	}
+	slot, ok := new(big.Int).SetString(strings.TrimSpace(args.Expression), 0)
+	if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+		return nil, fmt.Errorf("not a storage slot: %q", args.Expression)
+	}
+	value, err := s.dbg.Storage(stop.Frames[id-1].Address, common.BigToHash(slot))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"result": value.Hex(), "variablesReference": 0}, nil
+}
+
+func (s *session) source(data json.RawMessage) (interface{}, error) {
+	var args struct {
+		Source          dapSource `json:"source"`
+		SourceReference int       `json:"sourceReference"`
+	}
+	if err := unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	if args.Source.SourceReference == 0 {
+		args.Source.SourceReference = args.SourceReference
+	}
+	src, err := s.lookup(args.Source)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"content": Listing(src.ins), "mimeType": "text/x-evm-asm"}, nil
+}
@@ -0,0 +1,406 @@
+// Package debugger steps through the execution of the EVM like a debugger
+// steps through a Go program: it pauses the interpreter at breakpoints, steps
+// over, into and out of calls, and shows the stack, memory, storage and return
+// data of the paused execution. Editors attach to it through the Debug Adapter
+// Protocol, see Server.
+package debugger
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// ErrNotPaused is returned when stepping or inspecting an execution that is
+// not paused.
+var ErrNotPaused = errors.New("debugger: execution not paused")
+
+// Stop reasons.
+const (
+	ReasonEntry      = "entry"      // paused before the first opcode
+	ReasonPause      = "pause"      // paused on request
+	ReasonStep       = "step"       // a step ended
+	ReasonBreakpoint = "breakpoint" // a breakpoint was hit
+	ReasonOpcode     = "opcode"     // an opcode breakpoint was hit
+	ReasonFault      = "exception"  // an opcode failed or reverted
+)
+
+// Location is a position in the code of a contract.
+type Location struct {
+	Address common.Address // address of the code, the delegate for DELEGATECALL
+	PC      uint64
+}
+
+// Frame is a call being executed.
+type Frame struct {
+	Type        vm.OpCode // CALL, CREATE, DELEGATECALL, ...
+	Caller      common.Address
+	Address     common.Address // whose storage and balance the call uses
+	CodeAddress common.Address // whose code the call runs
+	Code        []byte
+	Input       []byte
+	Value       *big.Int
+	PC          uint64
+	Op          vm.OpCode
+	Gas         uint64
+}
+
+// Location returns the position of the frame.
+func (f *Frame) Location() Location {
+	return Location{Address: f.CodeAddress, PC: f.PC}
+}
+
+// Stop is the state of a paused execution. The frames are ordered from the
+// top-level call to the paused one, whose stack, memory and return data are
+// given.
+type Stop struct {
+	Reason     string
+	Err        error // the failure of the opcode, for ReasonFault
+	Frames     []Frame
+	Stack      []uint256.Int // bottom first
+	Memory     []byte
+	ReturnData []byte
+}
+
+// Frame returns the paused call.
+func (s *Stop) Frame() *Frame {
+	return &s.Frames[len(s.Frames)-1]
+}
+
+type mode int
+
+const (
+	modeRun      mode = iota // until a breakpoint
+	modePause                // at the next opcode
+	modeStepIn               // at the next opcode, ending a step
+	modeStepOver             // at the next opcode of the same call or a caller
+	modeStepOut              // at the next opcode of a caller
+)
+
+// Debugger is an EVM logger pausing the execution it is attached to. While
+// paused, the execution blocks in the interpreter until it is resumed from
+// another goroutine with Continue or one of the steps. A debugger debugs one
+// execution.
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints map[Location]bool
+	opcodes     map[vm.OpCode]bool
+	faults      bool // stop when an opcode fails
+	mode        mode
+	depth       int // of the paused call, for stepping over and out
+	entry       bool
+	detached    bool
+	stopped     *Stop
+
+	env     *vm.EVM
+	frames  []Frame
+	storage map[common.Address]map[common.Hash]bool // slots read or written
+
+	stops  chan *Stop
+	resume chan struct{}
+	detach chan struct{} // closed by Detach, releasing a pending pause
+}
+
+// New returns a debugger with no breakpoints, running the execution it is
+// attached to until it is paused.
+func New() *Debugger {
+	return &Debugger{
+		breakpoints: make(map[Location]bool),
+		opcodes:     make(map[vm.OpCode]bool),
+		storage:     make(map[common.Address]map[common.Hash]bool),
+		stops:       make(chan *Stop, 1),
+		resume:      make(chan struct{}),
+		detach:      make(chan struct{}),
+	}
+}
+
+// Stops returns the channel the state of the execution is sent on each time it
+// pauses. It must be received from for the execution to be resumed.
+func (d *Debugger) Stops() <-chan *Stop {
+	return d.stops
+}
+
+// SetBreakpoint pauses the execution before the opcode at loc is executed.
+func (d *Debugger) SetBreakpoint(loc Location) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[loc] = true
+}
+
+// ClearBreakpoint removes the breakpoint at loc.
+func (d *Debugger) ClearBreakpoint(loc Location) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, loc)
+}
+
+// SetOpcodeBreakpoints pauses the execution before every opcode in ops,
+// replacing the previous opcode breakpoints.
+func (d *Debugger) SetOpcodeBreakpoints(ops ...vm.OpCode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opcodes = make(map[vm.OpCode]bool, len(ops))
+	for _, op := range ops {
+		d.opcodes[op] = true
+	}
+}
+
+// StopOnFault sets whether the execution pauses when an opcode fails or
+// reverts, with the state it failed in.
+func (d *Debugger) StopOnFault(stop bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults = stop
+}
+
+// StopOnEntry pauses the execution before its first opcode.
+func (d *Debugger) StopOnEntry() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entry = true
+}
+
+// Pause pauses the execution at the next opcode.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = modePause
+}
+
+// Continue resumes the execution until a breakpoint.
+func (d *Debugger) Continue() error {
+	return d.resumeWith(modeRun)
+}
+
+// StepIn executes one opcode, entering the call it makes if any.
+func (d *Debugger) StepIn() error {
+	return d.resumeWith(modeStepIn)
+}
+
+// StepOver executes one opcode, running the call it makes if any to its end.
+func (d *Debugger) StepOver() error {
+	return d.resumeWith(modeStepOver)
+}
+
+// StepOut runs the paused call to its end and pauses in its caller.
+func (d *Debugger) StepOut() error {
+	return d.resumeWith(modeStepOut)
+}
+
+// Detach removes the breakpoints and resumes the execution, which runs to its
+// end without pausing again.
+func (d *Debugger) Detach() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.detached {
+		return
+	}
+	d.detached, d.mode, d.stopped = true, modeRun, nil
+	close(d.detach)
+}
+
+func (d *Debugger) resumeWith(m mode) error {
+	d.mu.Lock()
+	if d.stopped == nil {
+		d.mu.Unlock()
+		return ErrNotPaused
+	}
+	d.mode, d.depth, d.stopped = m, len(d.stopped.Frames), nil
+	d.mu.Unlock()
+	select {
+	case d.resume <- struct{}{}:
+	case <-d.detach:
+	}
+	return nil
+}
+
+// Stopped returns the state of the paused execution, nil if it is running.
+func (d *Debugger) Stopped() *Stop {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stopped
+}
+
+// Storage returns the value of a storage slot of addr in the paused execution.
+func (d *Debugger) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped == nil {
+		return common.Hash{}, ErrNotPaused
+	}
+	return d.env.StateDB.GetState(addr, slot), nil
+}
+
+// Code returns the code of addr in the paused execution.
+func (d *Debugger) Code(addr common.Address) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped == nil {
+		return nil, ErrNotPaused
+	}
+	return d.env.StateDB.GetCode(addr), nil
+}
+
+// TouchedSlots returns the storage slots of addr read or written so far, in
+// ascending order.
+func (d *Debugger) TouchedSlots(addr common.Address) []common.Hash {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	slots := make([]common.Hash, 0, len(d.storage[addr]))
+	for slot := range d.storage[addr] {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i][:], slots[j][:]) < 0 })
+	return slots
+}
+
+func (d *Debugger) CaptureTxStart(gasLimit uint64) {}
+
+func (d *Debugger) CaptureTxEnd(restGas uint64) {}
+
+func (d *Debugger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	d.mu.Lock()
+	d.env = env
+	if d.entry {
+		d.mode = modePause
+	}
+	d.mu.Unlock()
+	d.enter(typ, from, to, input, gas, value)
+}
+
+func (d *Debugger) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	d.exit()
+}
+
+func (d *Debugger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	d.enter(typ, from, to, input, gas, value)
+}
+
+func (d *Debugger) CaptureExit(output []byte, gasUsed uint64, err error) {
+	d.exit()
+}
+
+func (d *Debugger) enter(typ vm.OpCode, from, to common.Address, input []byte, gas uint64, value *big.Int) {
+	frame := Frame{
+		Type:        typ,
+		Caller:      from,
+		Address:     to,
+		CodeAddress: to,
+		Input:       common.CopyBytes(input),
+		Gas:         gas,
+	}
+	if value != nil {
+		frame.Value = new(big.Int).Set(value)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.frames = append(d.frames, frame)
+}
+
+func (d *Debugger) exit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.frames) > 0 {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+}
+
+// CaptureState pauses the execution before op if it should.
+func (d *Debugger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	d.mu.Lock()
+	if len(d.frames) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	frame := &d.frames[len(d.frames)-1]
+	frame.PC, frame.Op, frame.Gas = pc, op, gas
+	frame.Code = scope.Contract.Code
+	if scope.Contract.CodeAddr != nil {
+		frame.CodeAddress = *scope.Contract.CodeAddr
+	}
+	if (op == vm.SLOAD || op == vm.SSTORE) && len(scope.Stack.Data()) > 0 {
+		d.touch(scope.Contract.Address(), common.Hash(scope.Stack.Back(0).Bytes32()))
+	}
+
+	var reason string
+	switch {
+	case d.detached:
+	case d.entry:
+		reason, d.entry = ReasonEntry, false
+	case d.breakpoints[frame.Location()]:
+		reason = ReasonBreakpoint
+	case d.opcodes[op]:
+		reason = ReasonOpcode
+	case d.mode == modePause:
+		reason = ReasonPause
+	case d.mode == modeStepIn,
+		d.mode == modeStepOver && len(d.frames) <= d.depth,
+		d.mode == modeStepOut && len(d.frames) < d.depth:
+		reason = ReasonStep
+	}
+	d.mu.Unlock()
+	if reason != "" {
+		d.pause(reason, nil, scope, rData)
+	}
+}
+
+// CaptureFault pauses the execution on the failed opcode if faults are
+// breakpoints.
+func (d *Debugger) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	d.mu.Lock()
+	stop := d.faults && !d.detached
+	d.mu.Unlock()
+	if stop {
+		d.pause(ReasonFault, err, scope, nil)
+	}
+}
+
+func (d *Debugger) touch(addr common.Address, slot common.Hash) {
+	slots, ok := d.storage[addr]
+	if !ok {
+		slots = make(map[common.Hash]bool)
+		d.storage[addr] = slots
+	}
+	slots[slot] = true
+}
+
+// pause blocks the execution until it is resumed or the debugger is detached.
+func (d *Debugger) pause(reason string, err error, scope *vm.ScopeContext, rData []byte) {
+	d.mu.Lock()
+	// detached since the caller decided to pause
+	if d.detached {
+		d.mu.Unlock()
+		return
+	}
+	stop := &Stop{
+		Reason:     reason,
+		Err:        err,
+		Frames:     make([]Frame, len(d.frames)),
+		Stack:      append([]uint256.Int(nil), scope.Stack.Data()...),
+		Memory:     common.CopyBytes(scope.Memory.Data()),
+		ReturnData: common.CopyBytes(rData),
+	}
+	copy(stop.Frames, d.frames)
+	d.stopped = stop
+	d.mu.Unlock()
+
+	select {
+	case d.stops <- stop:
+	case <-d.detach:
+		return
+	}
+	select {
+	case <-d.resume:
+	case <-d.detach:
+	}
+}
@@ -0,0 +1,334 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	sender  = common.HexToAddress("0x0b")
+	counter = common.HexToAddress("0xc0ffee")
+	caller  = common.HexToAddress("0xca11")
+
+	// SLOAD(0) + 1 -> SSTORE(0): PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE STOP
+	counterCode = common.FromHex("0x60005460010160005500")
+	// CALL(gas, counter, 0, 0, 0, 0, 0) then STOP, the CALL being at pc 15
+	callerCode = common.FromHex("0x6000600060006000600062c0ffee5af100")
+)
+
+// testTarget executes calls from sender on a fresh state.
+type testTarget struct{}
+
+func (testTarget) state() *gstate.StateDB {
+	statedb, _ := gstate.New(common.Hash{}, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(counter, counterCode)
+	statedb.SetCode(caller, callerCode)
+	statedb.SetState(counter, common.Hash{}, common.BytesToHash([]byte{41}))
+	return statedb
+}
+
+func (t testTarget) Run(args json.RawMessage, tracer vm.EVMLogger) ([]byte, error) {
+	var to struct{ To common.Address }
+	if err := json.Unmarshal(args, &to); err != nil {
+		return nil, err
+	}
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+		Difficulty:  big.NewInt(0),
+	}
+	evm := vm.NewEVM(blockCtx, vm.TxContext{GasPrice: big.NewInt(0)}, t.state(), params.TestChainConfig, vm.Config{Tracer: tracer})
+	output, _, err := evm.Call(vm.AccountRef(sender), to.To, nil, 100000, big.NewInt(0))
+	return output, err
+}
+
+func (t testTarget) Code(addr common.Address) ([]byte, error) {
+	return t.state().GetCode(addr), nil
+}
+
+// debug runs a call to addr with d attached in the background, and returns a
+// channel closed when it ends.
+func debug(d *Debugger, addr common.Address) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		testTarget{}.Run(json.RawMessage(fmt.Sprintf(`{"to":%q}`, addr.Hex())), d)
+	}()
+	return done
+}
+
+func next(t *testing.T, d *Debugger) *Stop {
+	select {
+	case stop := <-d.Stops():
+		return stop
+	case <-time.After(5 * time.Second):
+		t.Fatal("the execution didn't pause")
+		return nil
+	}
+}
+
+func TestBreakpointsAndSteps(t *testing.T) {
+	d := New()
+	d.SetBreakpoint(Location{Address: caller, PC: 15})
+	done := debug(d, caller)
+
+	stop := next(t, d)
+	assert.Equal(t, ReasonBreakpoint, stop.Reason)
+	assert.Equal(t, vm.CALL, stop.Frame().Op)
+	assert.Len(t, stop.Frames, 1)
+	assert.Len(t, stop.Stack, 7, "the arguments of CALL")
+
+	// into the counter
+	require.NoError(t, d.StepIn())
+	stop = next(t, d)
+	assert.Equal(t, ReasonStep, stop.Reason)
+	require.Len(t, stop.Frames, 2)
+	assert.Equal(t, counter, stop.Frame().Address)
+	assert.Equal(t, uint64(0), stop.Frame().PC)
+
+	require.NoError(t, d.StepOver())
+	stop = next(t, d)
+	assert.Equal(t, vm.SLOAD, stop.Frame().Op)
+	value, err := d.Storage(counter, common.Hash{})
+	require.NoError(t, err)
+	assert.Equal(t, common.BytesToHash([]byte{41}), value)
+
+	// back in the caller, after the CALL
+	require.NoError(t, d.StepOut())
+	stop = next(t, d)
+	assert.Len(t, stop.Frames, 1)
+	assert.Equal(t, vm.STOP, stop.Frame().Op)
+	assert.Equal(t, []common.Hash{{}}, d.TouchedSlots(counter))
+
+	require.NoError(t, d.Continue())
+	<-done
+	assert.ErrorIs(t, d.Continue(), ErrNotPaused)
+}
+
+func TestStepOverCall(t *testing.T) {
+	d := New()
+	d.StopOnEntry()
+	d.SetOpcodeBreakpoints(vm.SSTORE)
+	done := debug(d, caller)
+
+	stop := next(t, d)
+	assert.Equal(t, ReasonEntry, stop.Reason)
+	assert.Equal(t, uint64(0), stop.Frame().PC)
+
+	// the opcode breakpoint in the called contract is hit when stepping over
+	// the CALL
+	d.SetBreakpoint(Location{Address: caller, PC: 15})
+	require.NoError(t, d.Continue())
+	assert.Equal(t, vm.CALL, next(t, d).Frame().Op)
+	require.NoError(t, d.StepOver())
+	stop = next(t, d)
+	assert.Equal(t, ReasonOpcode, stop.Reason)
+	assert.Equal(t, vm.SSTORE, stop.Frame().Op)
+
+	d.SetOpcodeBreakpoints()
+	require.NoError(t, d.StepOver())
+	stop = next(t, d)
+	assert.Equal(t, vm.STOP, stop.Frame().Op)
+	assert.Len(t, stop.Frames, 2)
+
+	d.Detach()
+	<-done
+}
+
+func TestDetachReleasesPause(t *testing.T) {
+	d := New()
+	d.StopOnEntry()
+	d.SetOpcodeBreakpoints(vm.SSTORE)
+	done := debug(d, caller)
+
+	// the stop on entry is never received, so the stop on SSTORE can't be sent
+	require.Eventually(t, func() bool { return d.Stopped() != nil }, 5*time.Second, time.Millisecond)
+	require.NoError(t, d.Continue())
+	require.Eventually(t, func() bool { return d.Stopped() != nil }, 5*time.Second, time.Millisecond)
+
+	d.Detach()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the execution didn't resume")
+	}
+	assert.ErrorIs(t, d.Continue(), ErrNotPaused)
+}
+
+func TestDisassemble(t *testing.T) {
+	ins := Disassemble(callerCode)
+	require.Len(t, ins, 9)
+	assert.Equal(t, Instruction{PC: 10, Op: vm.PUSH3, Data: counter[17:]}, ins[5])
+	assert.Equal(t, uint64(15), ins[7].PC)
+	assert.Equal(t, "00015 CALL", ins[7].String())
+
+	// truncated push data
+	assert.Equal(t, []Instruction{{PC: 0, Op: vm.PUSH2, Data: []byte{1}}}, Disassemble([]byte{0x61, 1}))
+}
+
+// client speaks the Debug Adapter Protocol to a server.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	seq  int
+}
+
+func (c *client) request(command string, args interface{}) {
+	c.seq++
+	data, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	require.NoError(c.t, err)
+	fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// expect reads messages until the response to command or the event named
+// command, and returns its body. It fails if the request failed.
+func (c *client) expect(command string) map[string]interface{} {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, err := readMessage(c.r)
+		require.NoError(c.t, err)
+		var msg struct {
+			Type, Command, Event, Message string
+			Success                       bool
+			Body                          map[string]interface{}
+		}
+		require.NoError(c.t, json.Unmarshal(data, &msg))
+		if msg.Type == "response" && msg.Command == command {
+			require.True(c.t, msg.Success, msg.Message)
+			return msg.Body
+		}
+		if msg.Type == "event" && msg.Event == command {
+			return msg.Body
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	server, conn := net.Pipe()
+	go NewServer(testTarget{}).ServeConn(server)
+	defer conn.Close()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	c.request("initialize", map[string]interface{}{"adapterID": "gevm"})
+	caps := c.expect("initialize")
+	assert.Equal(t, true, caps["supportsInstructionBreakpoints"])
+	c.expect("initialized")
+
+	c.request("launch", map[string]interface{}{"to": caller.Hex()})
+	c.expect("launch")
+	// the 8th line of the disassembly of the caller is its CALL
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"name": caller.Hex()},
+		"breakpoints": []map[string]interface{}{{"line": 8}, {"line": 100}},
+	})
+	bps := c.expect("setBreakpoints")["breakpoints"].([]interface{})
+	assert.Equal(t, true, bps[0].(map[string]interface{})["verified"])
+	assert.Equal(t, false, bps[1].(map[string]interface{})["verified"])
+	c.request("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]interface{}{{"name": "sstore"}, {"name": "NOPE"}},
+	})
+	bps = c.expect("setFunctionBreakpoints")["breakpoints"].([]interface{})
+	assert.Equal(t, true, bps[0].(map[string]interface{})["verified"])
+	assert.Equal(t, false, bps[1].(map[string]interface{})["verified"])
+	c.request("configurationDone", nil)
+	c.expect("configurationDone")
+
+	stopped := c.expect("stopped")
+	assert.Equal(t, ReasonBreakpoint, stopped["reason"])
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	frames := c.expect("stackTrace")["stackFrames"].([]interface{})
+	require.Len(t, frames, 1)
+	top := frames[0].(map[string]interface{})
+	assert.Equal(t, float64(8), top["line"])
+	assert.Equal(t, caller.Hex()+":15", top["instructionPointerReference"])
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.expect("continue")
+	stopped = c.expect("stopped")
+	assert.Equal(t, ReasonOpcode, stopped["reason"])
+
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	frames = c.expect("stackTrace")["stackFrames"].([]interface{})
+	require.Len(t, frames, 2)
+	inner := frames[0].(map[string]interface{})
+	id := inner["id"].(float64)
+
+	c.request("scopes", map[string]interface{}{"frameId": id})
+	scopeList := c.expect("scopes")["scopes"].([]interface{})
+	require.Len(t, scopeList, 5)
+	stackScope := scopeList[1].(map[string]interface{})
+	assert.Equal(t, "Stack", stackScope["name"])
+	c.request("variables", map[string]interface{}{"variablesReference": stackScope["variablesReference"]})
+	vars := c.expect("variables")["variables"].([]interface{})
+	require.Len(t, vars, 2)
+	assert.Equal(t, "0x0", vars[0].(map[string]interface{})["value"], "the slot on top")
+	assert.Equal(t, "0x2a", vars[1].(map[string]interface{})["value"])
+
+	c.request("evaluate", map[string]interface{}{"expression": "0", "frameId": id})
+	assert.Equal(t, common.BytesToHash([]byte{41}).Hex(), c.expect("evaluate")["result"])
+
+	c.request("source", map[string]interface{}{"source": inner["source"]})
+	assert.Contains(t, c.expect("source")["content"], "00008 SSTORE")
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.expect("continue")
+	assert.Equal(t, float64(0), c.expect("exited")["exitCode"])
+	c.expect("terminated")
+
+	c.request("disconnect", nil)
+	c.expect("disconnect")
+}
+
+// endTarget is a testTarget telling when its execution ends.
+type endTarget struct {
+	testTarget
+	ended chan struct{}
+}
+
+func (t endTarget) Run(args json.RawMessage, tracer vm.EVMLogger) ([]byte, error) {
+	defer close(t.ended)
+	return t.testTarget.Run(args, tracer)
+}
+
+func TestServerClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	target := endTarget{ended: make(chan struct{})}
+	s := NewServer(target)
+	served := make(chan error)
+	go func() { served <- s.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.request("launch", map[string]interface{}{"to": counter.Hex(), "stopOnEntry": true})
+	c.expect("launch")
+	c.request("configurationDone", nil)
+	c.expect("configurationDone")
+	assert.Equal(t, ReasonEntry, c.expect("stopped")["reason"])
+
+	// the paused execution runs to its end
+	require.NoError(t, s.Close())
+	select {
+	case <-target.ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the execution didn't resume")
+	}
+	assert.NoError(t, <-served)
+}
@@ -0,0 +1,54 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/daweth/gevm/vm"
+)
+
+// Instruction is an opcode of disassembled code, with its immediate data for
+// the PUSH opcodes.
+type Instruction struct {
+	PC   uint64
+	Op   vm.OpCode
+	Data []byte
+}
+
+func (in Instruction) String() string {
+	if len(in.Data) > 0 {
+		return fmt.Sprintf("%05d %v 0x%x", in.PC, in.Op, in.Data)
+	}
+	return fmt.Sprintf("%05d %v", in.PC, in.Op)
+}
+
+// Disassemble splits code into instructions. The immediate data of a PUSH at
+// the end of the code is truncated like the EVM reads it.
+func Disassemble(code []byte) []Instruction {
+	var ins []Instruction
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		in := Instruction{PC: pc, Op: vm.OpCode(code[pc])}
+		if in.Op.IsPush() {
+			size := uint64(in.Op - vm.PUSH1 + 1)
+			end := pc + 1 + size
+			if end > uint64(len(code)) {
+				end = uint64(len(code))
+			}
+			in.Data = code[pc+1 : end]
+			pc += size
+		}
+		ins = append(ins, in)
+	}
+	return ins
+}
+
+// Listing returns the disassembly of code, one instruction per line, so that
+// line n, counted from 1, is the instruction at index n-1.
+func Listing(ins []Instruction) string {
+	var b strings.Builder
+	for _, in := range ins {
+		b.WriteString(in.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
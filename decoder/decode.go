@@ -0,0 +1,269 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrUnknownSelector is returned when no ABI has the method, error or event
+// of the data to decode.
+var ErrUnknownSelector = errors.New("decoder: unknown selector")
+
+// Arg is a decoded argument. Integers are in decimal strings and bytes in
+// hex, so that no client loses precision reading them.
+type Arg struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Decoded is a method call, an event or an error decoded with an ABI.
+type Decoded struct {
+	Contract  string `json:"contract,omitempty"` // the contract of the ABI, empty for the builtin errors
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	Args      []Arg  `json:"args"`
+}
+
+// String formats d like a call of Solidity, e.g. Transfer(0x..., 10).
+func (d *Decoded) String() string {
+	args := make([]string, len(d.Args))
+	for i, arg := range d.Args {
+		args[i] = fmt.Sprint(arg.Value)
+	}
+	return d.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Revert is decoded revert data.
+type Revert struct {
+	Decoded
+	Reason string `json:"reason"` // the message of Error(string), else the error formatted
+}
+
+// The errors the compiler reverts with, besides the custom errors of ABIs.
+var (
+	errorError = abi.NewError("Error", abi.Arguments{{Name: "message", Type: newType("string")}})
+	panicError = abi.NewError("Panic", abi.Arguments{{Name: "code", Type: newType("uint256")}})
+)
+
+// panicReasons are the causes of the panics of Solidity by code.
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "conversion into a non-existent enum value",
+	0x22: "incorrectly encoded storage byte array",
+	0x31: "pop() on an empty array",
+	0x32: "array index out of bounds",
+	0x41: "too much memory allocated",
+	0x51: "call to a zero-initialized internal function",
+}
+
+func newType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+// DecodeRevert decodes the data a call reverted with: the Error(string) of
+// require and revert, the Panic(uint256) of failed assertions and arithmetic,
+// or a custom error of the first of contracts to have its selector.
+func DecodeRevert(data []byte, contracts ...*Contract) (*Revert, error) {
+	if len(data) < 4 {
+		return nil, ErrUnknownSelector
+	}
+	var selector [4]byte
+	copy(selector[:], data)
+	switch {
+	case bytes.Equal(selector[:], errorError.ID[:4]):
+		d, err := decodeError(&errorError, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &Revert{Decoded: *d, Reason: d.Args[0].Value.(string)}, nil
+	case bytes.Equal(selector[:], panicError.ID[:4]):
+		d, err := decodeError(&panicError, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		code, _ := new(big.Int).SetString(d.Args[0].Value.(string), 10)
+		reason, ok := panicReasons[code.Uint64()]
+		if !ok || !code.IsUint64() {
+			reason = "unknown panic"
+		}
+		return &Revert{Decoded: *d, Reason: fmt.Sprintf("panic: %s (%#x)", reason, code)}, nil
+	}
+	for _, c := range contracts {
+		e, err := c.ABI.ErrorByID(selector)
+		if err != nil {
+			continue
+		}
+		d, err := decodeError(e, data, c)
+		if err != nil {
+			return nil, err
+		}
+		return &Revert{Decoded: *d, Reason: d.String()}, nil
+	}
+	return nil, ErrUnknownSelector
+}
+
+func decodeError(e *abi.Error, data []byte, c *Contract) (*Decoded, error) {
+	values, err := e.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("decoder: %s: %w", e.Name, err)
+	}
+	return newDecoded(c, e.Name, e.Sig, e.Inputs, values), nil
+}
+
+// DecodeCall decodes calldata with the method of the first of contracts to
+// have its selector.
+func DecodeCall(input []byte, contracts ...*Contract) (*Decoded, error) {
+	c, method, err := findMethod(input, contracts)
+	if err != nil {
+		return nil, err
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("decoder: %s: %w", method.RawName, err)
+	}
+	return newDecoded(c, method.RawName, method.Sig, method.Inputs, values), nil
+}
+
+// DecodeOutput decodes the data returned by a call of input with the outputs
+// of the method of the first of contracts to have its selector.
+func DecodeOutput(input, output []byte, contracts ...*Contract) (*Decoded, error) {
+	c, method, err := findMethod(input, contracts)
+	if err != nil {
+		return nil, err
+	}
+	values, err := method.Outputs.Unpack(output)
+	if err != nil {
+		return nil, fmt.Errorf("decoder: %s: %w", method.RawName, err)
+	}
+	return newDecoded(c, method.RawName, method.Sig, method.Outputs, values), nil
+}
+
+func findMethod(input []byte, contracts []*Contract) (*Contract, *abi.Method, error) {
+	if len(input) < 4 {
+		return nil, nil, ErrUnknownSelector
+	}
+	for _, c := range contracts {
+		if method, err := c.ABI.MethodById(input[:4]); err == nil {
+			return c, method, nil
+		}
+	}
+	return nil, nil, ErrUnknownSelector
+}
+
+// DecodeLog decodes a log with the event of the first of contracts to have the
+// hash of its signature as first topic. Anonymous events can't be told apart
+// and aren't decoded. The indexed arguments of dynamic types are only hashes
+// in the topics, they are decoded as such.
+func DecodeLog(topics []common.Hash, data []byte, contracts ...*Contract) (*Decoded, error) {
+	if len(topics) == 0 {
+		return nil, ErrUnknownSelector
+	}
+	for _, c := range contracts {
+		event, err := c.ABI.EventByID(topics[0])
+		if err != nil {
+			continue
+		}
+		indexed := 0
+		for _, arg := range event.Inputs {
+			if arg.Indexed {
+				indexed++
+			}
+		}
+		if indexed != len(topics)-1 {
+			continue // another event with the same signature and other indexed arguments
+		}
+		unindexed, err := event.Inputs.NonIndexed().Unpack(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoder: %s: %w", event.RawName, err)
+		}
+		values := make([]interface{}, len(event.Inputs))
+		topic := 1
+		for i, arg := range event.Inputs {
+			if !arg.Indexed {
+				values[i], unindexed = unindexed[0], unindexed[1:]
+				continue
+			}
+			if values[i], err = decodeTopic(arg, topics[topic]); err != nil {
+				return nil, fmt.Errorf("decoder: %s: %w", event.RawName, err)
+			}
+			topic++
+		}
+		return newDecoded(c, event.RawName, event.Sig, event.Inputs, values), nil
+	}
+	return nil, ErrUnknownSelector
+}
+
+// decodeTopic decodes an indexed argument. Values of static types are encoded
+// in their topic like in calldata, the others are hashed.
+func decodeTopic(arg abi.Argument, topic common.Hash) (interface{}, error) {
+	switch arg.Type.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return topic, nil
+	}
+	values, err := abi.Arguments{{Type: arg.Type}}.Unpack(topic.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func newDecoded(c *Contract, name, sig string, inputs abi.Arguments, values []interface{}) *Decoded {
+	d := &Decoded{Name: name, Signature: sig, Args: make([]Arg, len(inputs))}
+	if c != nil {
+		d.Contract = c.Name
+	}
+	for i, arg := range inputs {
+		d.Args[i] = Arg{Name: arg.Name, Type: arg.Type.String()}
+		if hash, ok := values[i].(common.Hash); ok {
+			d.Args[i].Value = hash.Hex() // of an indexed argument
+		} else {
+			d.Args[i].Value = jsonValue(arg.Type, reflect.ValueOf(values[i]))
+		}
+	}
+	return d
+}
+
+// jsonValue converts a value of type t unpacked by the abi package into one
+// that marshals as clients expect it: integers as decimal strings, addresses
+// as checksummed hex, bytes as hex, arrays as arrays and tuples as objects.
+func jsonValue(t abi.Type, v reflect.Value) interface{} {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		return fmt.Sprint(v.Interface())
+	case abi.AddressTy:
+		return v.Interface().(common.Address).Hex()
+	case abi.BytesTy, abi.FixedBytesTy, abi.HashTy, abi.FunctionTy:
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Encode(b)
+	case abi.SliceTy, abi.ArrayTy:
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = jsonValue(*t.Elem, v.Index(i))
+		}
+		return values
+	case abi.TupleTy:
+		fields := make(map[string]interface{}, len(t.TupleElems))
+		for i, elem := range t.TupleElems {
+			fields[t.TupleRawNames[i]] = jsonValue(*elem, v.Field(i))
+		}
+		return fields
+	}
+	return v.Interface()
+}
@@ -0,0 +1,181 @@
+package decoder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tokenABI = `[
+	{"type": "function", "name": "transfer", "stateMutability": "nonpayable",
+	 "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}],
+	 "outputs": [{"name": "", "type": "bool"}]},
+	{"type": "function", "name": "batch", "stateMutability": "nonpayable",
+	 "inputs": [{"name": "orders", "type": "tuple[]", "components": [
+		{"name": "id", "type": "bytes4"}, {"name": "qty", "type": "uint8"}]}],
+	 "outputs": []},
+	{"type": "error", "name": "InsufficientBalance",
+	 "inputs": [{"name": "needed", "type": "uint256"}, {"name": "available", "type": "uint256"}]},
+	{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+		{"name": "from", "type": "address", "indexed": true},
+		{"name": "to", "type": "address", "indexed": true},
+		{"name": "value", "type": "uint256", "indexed": false}]},
+	{"type": "event", "name": "Memo", "anonymous": false, "inputs": [
+		{"name": "text", "type": "string", "indexed": true},
+		{"name": "size", "type": "int32", "indexed": false}]}
+]`
+
+var (
+	alice = common.HexToAddress("0xa11ce")
+	bob   = common.HexToAddress("0xb0b")
+)
+
+func token(t *testing.T) *Contract {
+	c, err := NewContract("Token", []byte(tokenABI))
+	require.NoError(t, err)
+	return c
+}
+
+func TestDecodeRevert(t *testing.T) {
+	c := token(t)
+
+	data, err := errorError.Inputs.Pack("not enough")
+	require.NoError(t, err)
+	r, err := DecodeRevert(append(errorError.ID[:4:4], data...))
+	require.NoError(t, err)
+	assert.Equal(t, "Error", r.Name)
+	assert.Equal(t, "not enough", r.Reason)
+
+	data, err = panicError.Inputs.Pack(big.NewInt(0x11))
+	require.NoError(t, err)
+	r, err = DecodeRevert(append(panicError.ID[:4:4], data...))
+	require.NoError(t, err)
+	assert.Equal(t, "Panic", r.Name)
+	assert.Equal(t, "panic: arithmetic underflow or overflow (0x11)", r.Reason)
+	assert.Equal(t, []Arg{{Name: "code", Type: "uint256", Value: "17"}}, r.Args)
+
+	custom := c.ABI.Errors["InsufficientBalance"]
+	data, err = custom.Inputs.Pack(big.NewInt(10), big.NewInt(3))
+	require.NoError(t, err)
+	data = append(custom.ID[:4:4], data...)
+	_, err = DecodeRevert(data)
+	assert.ErrorIs(t, err, ErrUnknownSelector)
+	r, err = DecodeRevert(data, c)
+	require.NoError(t, err)
+	assert.Equal(t, "Token", r.Contract)
+	assert.Equal(t, "InsufficientBalance(uint256,uint256)", r.Signature)
+	assert.Equal(t, "InsufficientBalance(10, 3)", r.Reason)
+
+	_, err = DecodeRevert(nil, c)
+	assert.ErrorIs(t, err, ErrUnknownSelector)
+}
+
+func TestDecodeCall(t *testing.T) {
+	c := token(t)
+	input, err := c.ABI.Pack("transfer", bob, big.NewInt(5))
+	require.NoError(t, err)
+	d, err := DecodeCall(input, c)
+	require.NoError(t, err)
+	assert.Equal(t, &Decoded{
+		Contract:  "Token",
+		Name:      "transfer",
+		Signature: "transfer(address,uint256)",
+		Args: []Arg{
+			{Name: "to", Type: "address", Value: bob.Hex()},
+			{Name: "amount", Type: "uint256", Value: "5"},
+		},
+	}, d)
+
+	type order struct {
+		Id  [4]byte
+		Qty uint8
+	}
+	input, err = c.ABI.Pack("batch", []order{{Id: [4]byte{0xca, 0xfe}, Qty: 2}})
+	require.NoError(t, err)
+	d, err = DecodeCall(input, c)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "0xcafe0000", "qty": "2"}}, d.Args[0].Value)
+
+	_, err = DecodeCall([]byte{1, 2, 3, 4}, c)
+	assert.ErrorIs(t, err, ErrUnknownSelector)
+
+	input, err = c.ABI.Pack("transfer", bob, big.NewInt(5))
+	require.NoError(t, err)
+	d, err = DecodeOutput(input, common.LeftPadBytes([]byte{1}, 32), c)
+	require.NoError(t, err)
+	assert.Equal(t, []Arg{{Type: "bool", Value: true}}, d.Args)
+}
+
+func TestDecodeLog(t *testing.T) {
+	c := token(t)
+	transfer := c.ABI.Events["Transfer"]
+	data, err := transfer.Inputs.NonIndexed().Pack(big.NewInt(7))
+	require.NoError(t, err)
+	d, err := DecodeLog([]common.Hash{transfer.ID, common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes())}, data, c)
+	require.NoError(t, err)
+	assert.Equal(t, "Transfer("+alice.Hex()+", "+bob.Hex()+", 7)", d.String())
+
+	memo := c.ABI.Events["Memo"]
+	data, err = memo.Inputs.NonIndexed().Pack(int32(-2))
+	require.NoError(t, err)
+	hash := crypto.Keccak256Hash([]byte("hi"))
+	d, err = DecodeLog([]common.Hash{memo.ID, hash}, data, c)
+	require.NoError(t, err)
+	assert.Equal(t, []Arg{
+		{Name: "text", Type: "string", Value: hash.Hex()},
+		{Name: "size", Type: "int32", Value: "-2"},
+	}, d.Args)
+
+	// not as many indexed arguments
+	_, err = DecodeLog([]common.Hash{transfer.ID}, data, c)
+	assert.ErrorIs(t, err, ErrUnknownSelector)
+}
+
+func TestRegistry(t *testing.T) {
+	c := token(t)
+	other, err := NewContract("Other", []byte(`[{"type": "error", "name": "Unauthorized", "inputs": []}]`))
+	require.NoError(t, err)
+
+	code := []byte{0x60, 0x00}
+	artifacts := sourcemap.NewRegistry()
+	require.NoError(t, artifacts.Add(&sourcemap.Artifact{Name: "Token", ABI: []byte(tokenABI), Code: code, SourceMap: "0:1:0:-"}))
+	require.NoError(t, artifacts.Add(&sourcemap.Artifact{Name: "NoABI", Code: []byte{0x00}, SourceMap: "0:1:0:-"}))
+
+	r := NewRegistry()
+	require.NoError(t, r.AddArtifacts(artifacts))
+	r.AddAddress(alice, other)
+	assert.Equal(t, 2, r.Len())
+
+	codeHash := crypto.Keccak256Hash(code)
+	assert.Equal(t, "Token", r.Lookup(bob, codeHash).Name)
+	assert.Equal(t, "Other", r.Lookup(alice, codeHash).Name)
+	assert.Nil(t, r.Lookup(bob, common.Hash{}))
+
+	// an error of Token bubbling up through alice is decoded with the ABI of
+	// Token
+	custom := c.ABI.Errors["InsufficientBalance"]
+	data, err := custom.Inputs.Pack(big.NewInt(1), big.NewInt(0))
+	require.NoError(t, err)
+	data = append(custom.ID[:4:4], data...)
+	rev, err := r.DecodeRevert(r.Lookup(alice, common.Hash{}), data)
+	require.NoError(t, err)
+	assert.Equal(t, "Token", rev.Contract)
+
+	input, err := c.ABI.Pack("transfer", bob, big.NewInt(1))
+	require.NoError(t, err)
+	d, err := r.DecodeCall(nil, input)
+	require.NoError(t, err)
+	assert.Equal(t, "transfer", d.Name)
+
+	// replacing a registration doesn't keep the former one
+	r.AddAddress(alice, c)
+	assert.Equal(t, 2, r.Len())
+	unauthorized := other.ABI.Errors["Unauthorized"]
+	_, err = r.DecodeRevert(nil, unauthorized.ID[:4])
+	assert.ErrorIs(t, err, ErrUnknownSelector)
+}
@@ -0,0 +1,161 @@
+// Package decoder decodes revert data, calldata and logs with the ABIs of the
+// contracts they belong to, so that responses and traces show the errors,
+// methods and events of a contract instead of raw bytes.
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Contract is a registered ABI.
+type Contract struct {
+	Name string // may be empty
+	ABI  abi.ABI
+}
+
+// NewContract parses the ABI of the contract name from its JSON.
+func NewContract(name string, abiJSON []byte) (*Contract, error) {
+	parsed, err := abi.JSON(bytes.NewReader(abiJSON))
+	if err != nil {
+		if name != "" {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return nil, err
+	}
+	return &Contract{Name: name, ABI: parsed}, nil
+}
+
+// Registry holds the ABIs of the contracts of a chain, by the address of a
+// contract or by the hash of its deployed code, so that one registration covers
+// every deployment of the code. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	byAddress map[common.Address]*Contract
+	byCode    map[common.Hash]*Contract
+	all       []*Contract // in the order of registration
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byAddress: make(map[common.Address]*Contract),
+		byCode:    make(map[common.Hash]*Contract),
+	}
+}
+
+// AddAddress registers c for the contract at addr, replacing the ABI
+// registered for addr before.
+func (r *Registry) AddAddress(addr common.Address, c *Contract) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replace(r.byAddress[addr], c)
+	r.byAddress[addr] = c
+}
+
+// AddCode registers c for the contracts whose deployed code hashes to
+// codeHash, replacing the ABI registered for codeHash before.
+func (r *Registry) AddCode(codeHash common.Hash, c *Contract) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replace(r.byCode[codeHash], c)
+	r.byCode[codeHash] = c
+}
+
+// replace replaces a registration of old, nil for a new key, with c.
+func (r *Registry) replace(old, c *Contract) {
+	if old != nil {
+		for i := range r.all {
+			if r.all[i] == old {
+				r.all = append(r.all[:i], r.all[i+1:]...)
+				break
+			}
+		}
+	}
+	r.all = append(r.all, c)
+}
+
+// AddArtifacts registers the ABIs of the artifacts of the contracts of
+// artifacts by the hash of their deployed code. Artifacts without an ABI are
+// left out.
+func (r *Registry) AddArtifacts(artifacts *sourcemap.Registry) error {
+	for _, a := range artifacts.Contracts() {
+		if len(a.ABI) == 0 {
+			continue
+		}
+		c, err := NewContract(a.Name, a.ABI)
+		if err != nil {
+			return err
+		}
+		r.AddCode(crypto.Keccak256Hash(a.Code), c)
+	}
+	return nil
+}
+
+// Lookup returns the ABI of the contract at addr, whose code hashes to
+// codeHash, nil if neither is registered. The address wins over the code.
+func (r *Registry) Lookup(addr common.Address, codeHash common.Hash) *Contract {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.byAddress[addr]; ok {
+		return c
+	}
+	return r.byCode[codeHash]
+}
+
+// Len returns the number of registrations.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.all)
+}
+
+// candidates returns c followed by the other registered ABIs, latest first.
+// Revert data bubbles up from the contracts called, and a contract emits the
+// events of the libraries it uses, so their selectors are looked up in every
+// ABI when c doesn't have them.
+func (r *Registry) candidates(c *Contract) []*Contract {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	contracts := make([]*Contract, 0, len(r.all)+1)
+	if c != nil {
+		contracts = append(contracts, c)
+	}
+	for i := len(r.all) - 1; i >= 0; i-- {
+		if r.all[i] != c {
+			contracts = append(contracts, r.all[i])
+		}
+	}
+	return contracts
+}
+
+// DecodeRevert decodes revert data with the ABI of c, which may be nil, or
+// else with the other registered ABIs, see DecodeRevert.
+func (r *Registry) DecodeRevert(c *Contract, data []byte) (*Revert, error) {
+	return DecodeRevert(data, r.candidates(c)...)
+}
+
+// DecodeCall decodes calldata with the ABI of c, which may be nil, or else
+// with the other registered ABIs, see DecodeCall.
+func (r *Registry) DecodeCall(c *Contract, input []byte) (*Decoded, error) {
+	return DecodeCall(input, r.candidates(c)...)
+}
+
+// DecodeOutput decodes the data returned by a call of input with the ABI of
+// c, which may be nil, or else with the other registered ABIs, see
+// DecodeOutput.
+func (r *Registry) DecodeOutput(c *Contract, input, output []byte) (*Decoded, error) {
+	return DecodeOutput(input, output, r.candidates(c)...)
+}
+
+// DecodeLog decodes a log with the ABI of c, which may be nil, or else with
+// the other registered ABIs, see DecodeLog.
+func (r *Registry) DecodeLog(c *Contract, topics []common.Hash, data []byte) (*Decoded, error) {
+	return DecodeLog(topics, data, r.candidates(c)...)
+}
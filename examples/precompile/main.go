@@ -61,7 +61,7 @@ func main() {
 	bob, err := toAddress.MarshalText()
 	must(err)
 
-	node := ec.NewNodeContext(gasLimit, gasUsed, testAddress, toAddress)
+	node := ec.NewMemoryNodeContext(gasLimit, gasUsed, testAddress, toAddress)
 	fmt.Println("Alice Addr=", alice)
 	fmt.Println("Bob Addr=", bob)
 
@@ -0,0 +1,25 @@
+pragma solidity ^0.6.0;
+
+// GameOracle reads game values published to the oracle precompile at 0x0c.
+// Values are read as of the current block, or as of an earlier block with
+// getAt, and come with the block they were written in.
+library GameOracle {
+
+    address constant ORACLE = address(0x0c);
+
+    function get(bytes32 key) internal view returns (uint256 value, uint256 writtenAt) {
+        (bool success, bytes memory out) = ORACLE.staticcall(abi.encodeWithSignature("get(bytes32)", key));
+        require(success, "oracle: get failed");
+        return abi.decode(out, (uint256, uint256));
+    }
+
+    function getAt(bytes32 key, uint256 blockNumber) internal view returns (uint256 value, uint256 writtenAt) {
+        (bool success, bytes memory out) = ORACLE.staticcall(abi.encodeWithSignature("getAt(bytes32,uint256)", key, blockNumber));
+        require(success, "oracle: getAt failed");
+        return abi.decode(out, (uint256, uint256));
+    }
+
+    function weather() internal view returns (uint256 value) {
+        (value, ) = get(keccak256("weather"));
+    }
+}
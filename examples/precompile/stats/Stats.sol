@@ -0,0 +1,23 @@
+// Code generated by precompilegen. DO NOT EDIT.
+
+pragma solidity ^0.6.0;
+
+// Stats calls the Stats precompile at 0x0d.
+library Stats {
+
+    address constant PRECOMPILE = address(0x0d);
+
+    // weather returns the current weather.
+    function weather() internal view returns (uint8) {
+        (bool success, bytes memory out) = PRECOMPILE.staticcall(abi.encodeWithSignature("weather()"));
+        require(success, "Stats: weather failed");
+        return abi.decode(out, (uint8));
+    }
+
+    // player returns the position and resources of a player.
+    function player(uint64 id) internal view returns (int64 x, int64 y, uint64 resources) {
+        (bool success, bytes memory out) = PRECOMPILE.staticcall(abi.encodeWithSignature("player(uint64)", id));
+        require(success, "Stats: player failed");
+        return abi.decode(out, (int64, int64, uint64));
+    }
+}
@@ -0,0 +1,44 @@
+// Package stats lets contracts read the game world through a precompile
+// generated from the Stats interface.
+package stats
+
+import (
+	"errors"
+
+	"github.com/curio-research/keystone-starter-kit/server/constants"
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/curio-research/keystone/server"
+)
+
+//go:generate go run ../../../cmd/precompilegen -type Stats -addr 0x0d -external
+
+// Stats is what contracts can read about the game world.
+type Stats interface {
+	// Weather returns the current weather.
+	//gevm:gas 15
+	Weather() uint8
+
+	// Player returns the position and resources of a player.
+	//gevm:gas 200
+	Player(id uint64) (x int64, y int64, resources uint64, err error)
+}
+
+var errUnknownPlayer = errors.New("stats: unknown player")
+
+// Engine reads the stats from a Keystone game engine.
+type Engine struct {
+	Ctx *server.EngineCtx
+}
+
+func (e Engine) Weather() uint8 {
+	return uint8(data.Game.Get(e.Ctx.World, constants.GameEntity).Weather)
+}
+
+func (e Engine) Player(id uint64) (int64, int64, uint64, error) {
+	v, _ := e.Ctx.World.Get(int(id), data.Player.Name())
+	player, ok := v.(data.PlayerSchema)
+	if !ok {
+		return 0, 0, 0, errUnknownPlayer
+	}
+	return int64(player.Position.X), int64(player.Position.Y), uint64(player.Resources), nil
+}
@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/curio-research/keystone-starter-kit/server/constants"
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	"github.com/curio-research/keystone/server"
+	"github.com/curio-research/keystone/state"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsPrecompile(t *testing.T) {
+	world := state.NewWorld()
+	world.AddTable(data.Game)
+	world.AddTable(data.Player)
+	data.Game.AddSpecific(world, constants.GameEntity, data.GameSchema{Weather: data.Windy})
+	id := data.Player.Add(world, data.PlayerSchema{Position: state.Pos{X: 3, Y: -4}, Resources: 12})
+
+	precompile := &StatsPrecompile{Impl: Engine{Ctx: &server.EngineCtx{World: world}}}
+	vm.RegisterPrecompile(StatsAddress, precompile)
+
+	statedb, _ := gstate.New(types.EmptyRootHash, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	ctx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+	}
+	evm := vm.NewEVM(ctx, vm.TxContext{}, statedb, params.TestChainConfig, vm.Config{})
+	parsed, err := abi.JSON(strings.NewReader(StatsABI))
+	require.NoError(t, err)
+
+	call := func(method string, args ...interface{}) ([]interface{}, uint64, error) {
+		input, err := parsed.Pack(method, args...)
+		require.NoError(t, err)
+		ret, gasLeft, err := evm.StaticCall(vm.AccountRef(common.Address{}), StatsAddress, input, 10000)
+		if err != nil {
+			return nil, 10000 - gasLeft, err
+		}
+		out, err := parsed.Unpack(method, ret)
+		require.NoError(t, err)
+		return out, 10000 - gasLeft, nil
+	}
+
+	out, gas, err := call("weather")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{uint8(data.Windy)}, out)
+	assert.Equal(t, uint64(15), gas)
+
+	out, gas, err = call("player", uint64(id))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(3), int64(-4), uint64(12)}, out)
+	assert.Equal(t, uint64(200), gas)
+
+	_, _, err = call("player", uint64(id+1))
+	assert.ErrorIs(t, err, errUnknownPlayer)
+
+	// gas hooks override the declared gas
+	precompile.Gas.Player = func(id uint64) uint64 { return 1000 + id }
+	_, gas, err = call("player", uint64(id))
+	require.NoError(t, err)
+	assert.Equal(t, 1000+uint64(id), gas)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	ec "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/profiler"
 	"github.com/daweth/gevm/vm"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -43,6 +44,20 @@ func loadAbi(filename string) abi.ABI {
 	must(err)
 	return abiObj
 }
+
+// writeProfile writes the gas profile of prof to name.pprof and name.folded.
+func writeProfile(prof *profiler.Profiler, name string) {
+	pprofFile, err := os.Create(name + ".pprof")
+	must(err)
+	defer pprofFile.Close()
+	must(prof.WritePprof(pprofFile))
+
+	foldedFile, err := os.Create(name + ".folded")
+	must(err)
+	defer foldedFile.Close()
+	must(prof.WriteFolded(foldedFile, profiler.Gas))
+}
+
 func getTPS(start time.Time, end time.Time) int64 {
 	dur := end.Sub(start)
 	sec, _ := time.ParseDuration("1s")
@@ -89,6 +104,15 @@ func RunPrecompile(node *ec.NodeCtx) {
 	must(vmerr)
 	endTime := time.Now()
 
+	// profile the same call: go tool pprof weather.pprof, or
+	// flamegraph.pl weather.folded > weather.svg
+	prof := profiler.New(nil)
+	node.Evm.Config.Tracer = prof
+	_, _, vmerr = node.Evm.Call(contractRef, testAddress, input, gasLeft, big.NewInt(0))
+	node.Evm.Config.Tracer = nil
+	must(vmerr)
+	writeProfile(prof, "weather")
+
 	executionTime := endTime.Sub(startTime)
 	fmt.Printf("function executed in %v nanoseconds\n", executionTime.Nanoseconds())
 
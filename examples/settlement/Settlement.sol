@@ -0,0 +1,46 @@
+pragma solidity ^0.8.0;
+
+// Settlement records the final state of match chains on their parent chain.
+// gevm submits a call to settle when a match chain is settled, see
+// core.SettlementABI. Extend _apply to act on the match results, e.g. to pay
+// out rewards or transfer the items won.
+contract Settlement {
+
+    struct Match {
+        uint256 number;    // last block of the match chain
+        bytes32 stateRoot; // state root of that block
+    }
+
+    address public settler;
+    mapping(uint256 => Match) public matches;
+    // chain id => contract => slot => final value
+    mapping(uint256 => mapping(address => mapping(bytes32 => bytes32))) public results;
+
+    event Settled(uint256 indexed chainId, uint256 number, bytes32 stateRoot);
+
+    constructor(address _settler) {
+        settler = _settler;
+    }
+
+    function settle(
+        uint256 chainId,
+        uint256 number,
+        bytes32 stateRoot,
+        address[] calldata contracts,
+        bytes32[] calldata slots,
+        bytes32[] calldata values
+    ) external {
+        require(msg.sender == settler, "not the settler");
+        require(matches[chainId].stateRoot == bytes32(0), "already settled");
+        require(contracts.length == slots.length && slots.length == values.length, "length mismatch");
+
+        matches[chainId] = Match(number, stateRoot);
+        for (uint256 i = 0; i < contracts.length; i++) {
+            results[chainId][contracts[i]][slots[i]] = values[i];
+            _apply(chainId, contracts[i], slots[i], values[i]);
+        }
+        emit Settled(chainId, number, stateRoot);
+    }
+
+    function _apply(uint256 chainId, address target, bytes32 slot, bytes32 value) internal virtual {}
+}
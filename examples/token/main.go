@@ -8,6 +8,7 @@ import (
 	"time"
 
 	ec "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/decoder"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -45,6 +46,16 @@ func loadAbi(filename string) abi.ABI {
 	must(err)
 	return abiObj
 }
+
+// printOutput prints the values returned by the call of token with input.
+func printOutput(token *decoder.Contract, input, outputs []byte) {
+	d, err := decoder.DecodeOutput(input, outputs, token)
+	must(err)
+	for _, arg := range d.Args {
+		fmt.Printf("Output name=%s, value=%v\n", arg.Name, arg.Value)
+	}
+}
+
 func getTPS(start time.Time, end time.Time) int64 {
 	dur := end.Sub(start)
 	sec, _ := time.ParseDuration("1s")
@@ -57,13 +68,14 @@ func main() {
 	abiFilePath := "./Token.abi"
 	data := loadBin(binFilePath)
 	abiObj := loadAbi(abiFilePath)
+	token := &decoder.Contract{Name: "Token", ABI: abiObj}
 
 	alice, err := testAddress.MarshalText()
 	must(err)
 	bob, err := toAddress.MarshalText()
 	must(err)
 
-	node := ec.NewNodeContext(gasLimit, gasUsed, testAddress, toAddress)
+	node := ec.NewMemoryNodeContext(gasLimit, gasUsed, testAddress, toAddress)
 	fmt.Println("Alice Addr=", alice)
 	fmt.Println("Bob Addr=", bob)
 
@@ -97,14 +109,7 @@ func main() {
 	testBalance = node.StateDB.GetBalance(testAddress)
 	fmt.Println("after call contract, testBalance =", testBalance)
 
-	for _, op := range method.Outputs {
-		switch op.Type.String() {
-		case "uint256":
-			fmt.Printf("Output name=%s, value=%d\n", op.Name, big.NewInt(0).SetBytes(outputs))
-		default:
-			fmt.Println(op.Name, op.Type.String())
-		}
-	}
+	printOutput(token, input, outputs)
 
 	// get the balance of the user
 	method = abiObj.Methods["balanceOf"]
@@ -115,14 +120,7 @@ func main() {
 	testBalance = node.StateDB.GetBalance(testAddress)
 	fmt.Println("after contract creation, testBalance=", testBalance)
 
-	for _, op := range method.Outputs {
-		switch op.Type.String() {
-		case "uint256":
-			fmt.Printf("Output name=%s, value=%d\n", op.Name, big.NewInt(0).SetBytes(outputs))
-		default:
-			fmt.Println(op.Name, op.Type.String(), hexutil.Encode((outputs)))
-		}
-	}
+	printOutput(token, input1, outputs)
 
 	// TRANSFER TRANSACTION
 
@@ -152,19 +150,7 @@ func main() {
 	tps := getTPS(startTime, endTime)
 	fmt.Printf("Theoretical TPS is %v\n", tps)
 
-	for _, op := range method.Outputs {
-		res, err := abiObj.Unpack("transfer", outputs)
-		must(err)
-
-		fmt.Println("DECODED RESULT", res)
-
-		switch op.Type.String() {
-		case "uint256":
-			fmt.Printf("Output name=%s, value=%d\n", op.Name, big.NewInt(0).SetBytes(outputs))
-		default:
-			fmt.Println(op.Name, op.Type.String(), hexutil.Encode(outputs))
-		}
-	}
+	printOutput(token, input2, outputs)
 
 	// get the balance of the user
 	method = abiObj.Methods["balanceOf"]
@@ -176,14 +162,7 @@ func main() {
 	fmt.Println("after contract creation, testBalance=", testBalance)
 
 	// should be 9
-	for _, op := range method.Outputs {
-		switch op.Type.String() {
-		case "uint256":
-			fmt.Printf("Output name=%s, value=%d\n", op.Name, big.NewInt(0).SetBytes(outputs))
-		default:
-			fmt.Println(op.Name, op.Type.String(), hexutil.Encode((outputs)))
-		}
-	}
+	printOutput(token, input3, outputs)
 
 }
 
@@ -63,7 +63,7 @@ func main() {
 	vitalik, err := uncreatedAddress.MarshalText()
 	must(err)
 
-	node := ec.NewNodeContext(gasLimit, gasUsed, testAddress, toAddress)
+	node := ec.NewMemoryNodeContext(gasLimit, gasUsed, testAddress, toAddress)
 	fmt.Println("Alice Addr=", alice)
 	fmt.Println("Vitalik Addr=", vitalik)
 
@@ -0,0 +1,103 @@
+package fork
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// requestTimeout bounds every request to the upstream node.
+const requestTimeout = 30 * time.Second
+
+// Client reads the state of an upstream node at a pinned block over the
+// standard Ethereum JSON-RPC API.
+type Client struct {
+	rpc   *rpc.Client
+	url   string
+	block uint64
+	time  uint64 // timestamp of the pinned block
+}
+
+// Dial connects to the upstream node at url and pins the given block, or the
+// latest block if block is zero.
+func Dial(url string, block uint64) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("fork: dial %s: %w", url, err)
+	}
+	tag := "latest"
+	if block != 0 {
+		tag = hexutil.EncodeUint64(block)
+	}
+	var header struct {
+		Number    hexutil.Uint64 `json:"number"`
+		Timestamp hexutil.Uint64 `json:"timestamp"`
+	}
+	if err := client.CallContext(ctx, &header, "eth_getBlockByNumber", tag, false); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("fork: block %s: %w", tag, err)
+	}
+	return &Client{rpc: client, url: url, block: uint64(header.Number), time: uint64(header.Timestamp)}, nil
+}
+
+// Block returns the number of the pinned block.
+func (c *Client) Block() uint64 {
+	return c.block
+}
+
+// Time returns the timestamp of the pinned block.
+func (c *Client) Time() uint64 {
+	return c.time
+}
+
+// Close closes the connection to the upstream node.
+func (c *Client) Close() {
+	c.rpc.Close()
+}
+
+// Account returns the balance, nonce and code of addr at the pinned block.
+func (c *Client) Account(addr common.Address) (balance *big.Int, nonce uint64, code []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var (
+		bal   hexutil.Big
+		n     hexutil.Uint64
+		b     hexutil.Bytes
+		block = hexutil.EncodeUint64(c.block)
+	)
+	batch := []rpc.BatchElem{
+		{Method: "eth_getBalance", Args: []interface{}{addr, block}, Result: &bal},
+		{Method: "eth_getTransactionCount", Args: []interface{}{addr, block}, Result: &n},
+		{Method: "eth_getCode", Args: []interface{}{addr, block}, Result: &b},
+	}
+	if err := c.rpc.BatchCallContext(ctx, batch); err != nil {
+		return nil, 0, nil, fmt.Errorf("fork: account %v: %w", addr, err)
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, 0, nil, fmt.Errorf("fork: account %v: %s: %w", addr, elem.Method, elem.Error)
+		}
+	}
+	return bal.ToInt(), uint64(n), b, nil
+}
+
+// Storage returns the value of a storage slot of addr at the pinned block.
+func (c *Client) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var value hexutil.Bytes
+	if err := c.rpc.CallContext(ctx, &value, "eth_getStorageAt", addr, slot, hexutil.EncodeUint64(c.block)); err != nil {
+		return common.Hash{}, fmt.Errorf("fork: storage %v %v: %w", addr, slot, err)
+	}
+	return common.BytesToHash(value), nil
+}
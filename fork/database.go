@@ -0,0 +1,248 @@
+// Package fork runs a node on top of a snapshot of an existing chain.
+//
+// The state of the upstream chain at a pinned block is loaded on demand: when
+// an account or a storage slot is not found in the local state, it is fetched
+// from the upstream node and cached in the local database. Changes are only
+// written locally, so the upstream node is never modified, and the state root
+// of the local chain depends on the local changes alone.
+//
+// Upstream accounts are given a storage trie holding a marker slot, which the
+// local writes to their storage keep. Only the storage tries with the marker
+// fall back to the upstream storage: an account created, or recreated after a
+// self-destruct, locally starts from an empty trie and never reads the storage
+// of the upstream account it replaced.
+package fork
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// deletedAccount marks an upstream account deleted locally. The code
+	// hash is not the hash of any code.
+	deletedAccount = &types.StateAccount{
+		Balance:  new(big.Int),
+		Root:     types.EmptyRootHash,
+		CodeHash: crypto.Keccak256([]byte("gevm fork: deleted account")),
+	}
+	// deletedSlot marks an upstream storage slot cleared locally. Stored
+	// values have their leading zeros trimmed, so no value looks like it.
+	deletedSlot = []byte{0}
+	// upstreamSlot is set in the storage tries backed by the upstream
+	// storage. It is hidden from reads.
+	upstreamSlot = crypto.Keccak256([]byte("gevm fork: upstream storage"))
+	// upstreamRoot is the root of the storage of upstream accounts not
+	// written locally, a trie holding upstreamSlot only.
+	upstreamRoot = func() common.Hash {
+		tr, err := gstate.NewDatabase(rawdb.NewMemoryDatabase()).OpenStorageTrie(types.EmptyRootHash, common.Address{}, types.EmptyRootHash)
+		if err != nil {
+			panic(err)
+		}
+		if err := tr.UpdateStorage(common.Address{}, upstreamSlot, []byte{1}); err != nil {
+			panic(err)
+		}
+		return tr.Hash()
+	}()
+)
+
+// Database is a state database that falls back to the upstream node for the
+// accounts and storage slots not found locally.
+type Database struct {
+	gstate.Database
+
+	client *Client
+	kv     ethdb.KeyValueStore // cache of the upstream state
+	prefix []byte              // of the cache keys, per upstream node and block
+}
+
+// NewDatabase wraps db, the local state database, to load the state of the
+// upstream node client is connected to.
+func NewDatabase(db gstate.Database, client *Client) *Database {
+	block := make([]byte, 8)
+	binary.BigEndian.PutUint64(block, client.block)
+	return &Database{
+		Database: db,
+		client:   client,
+		kv:       db.DiskDB(),
+		prefix:   append([]byte("gevm-fork-"), crypto.Keccak256([]byte(client.url), block)[:8]...),
+	}
+}
+
+// OpenTrie opens the main account trie.
+func (db *Database) OpenTrie(root common.Hash) (gstate.Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return &trie{Trie: tr, db: db}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account. It falls back to the
+// upstream storage if it has the marker of upstream accounts.
+func (db *Database) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash) (gstate.Trie, error) {
+	if root == upstreamRoot {
+		// the trie isn't stored, it is built here and stored once written to
+		tr, err := db.Database.OpenStorageTrie(stateRoot, address, types.EmptyRootHash)
+		if err != nil {
+			return nil, err
+		}
+		if err := tr.UpdateStorage(address, upstreamSlot, []byte{1}); err != nil {
+			return nil, err
+		}
+		return &trie{Trie: tr, db: db, upstream: true}, nil
+	}
+	tr, err := db.Database.OpenStorageTrie(stateRoot, address, root)
+	if err != nil {
+		return nil, err
+	}
+	var marker []byte
+	if root != types.EmptyRootHash {
+		if marker, err = tr.GetStorage(address, upstreamSlot); err != nil {
+			return nil, err
+		}
+	}
+	return &trie{Trie: tr, db: db, upstream: len(marker) != 0}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *Database) CopyTrie(t gstate.Trie) gstate.Trie {
+	if t, ok := t.(*trie); ok {
+		return &trie{Trie: db.Database.CopyTrie(t.Trie), db: db, upstream: t.upstream}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// cachedAccount is an upstream account as stored in the cache.
+type cachedAccount struct {
+	Exists   bool
+	Nonce    uint64
+	Balance  *big.Int
+	CodeHash common.Hash
+}
+
+// account returns the upstream account at addr, or nil if it doesn't exist.
+func (db *Database) account(addr common.Address) (*types.StateAccount, error) {
+	key := append(append([]byte{}, db.prefix...), append([]byte("a"), addr[:]...)...)
+
+	var cached cachedAccount
+	if enc, err := db.kv.Get(key); err == nil {
+		if err := rlp.DecodeBytes(enc, &cached); err != nil {
+			return nil, err
+		}
+	} else {
+		balance, nonce, code, err := db.client.Account(addr)
+		if err != nil {
+			return nil, err
+		}
+		// like after EIP-161, empty accounts don't exist
+		cached = cachedAccount{
+			Exists:   nonce != 0 || balance.Sign() != 0 || len(code) != 0,
+			Nonce:    nonce,
+			Balance:  balance,
+			CodeHash: crypto.Keccak256Hash(code),
+		}
+		// the code is read from the local database like any other
+		rawdb.WriteCode(db.kv, cached.CodeHash, code)
+		enc, err := rlp.EncodeToBytes(&cached)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.kv.Put(key, enc); err != nil {
+			return nil, err
+		}
+	}
+	if !cached.Exists {
+		return nil, nil
+	}
+	return &types.StateAccount{
+		Nonce:    cached.Nonce,
+		Balance:  cached.Balance,
+		Root:     upstreamRoot,
+		CodeHash: cached.CodeHash.Bytes(),
+	}, nil
+}
+
+// storage returns the upstream value of a storage slot, with its leading
+// zeros trimmed, as stored in tries.
+func (db *Database) storage(addr common.Address, slot []byte) ([]byte, error) {
+	key := append(append([]byte{}, db.prefix...), append(append([]byte("s"), addr[:]...), slot...)...)
+
+	if value, err := db.kv.Get(key); err == nil {
+		return value, nil
+	}
+	value, err := db.client.Storage(addr, common.BytesToHash(slot))
+	if err != nil {
+		return nil, err
+	}
+	trimmed := common.TrimLeftZeroes(value[:])
+	if err := db.kv.Put(key, trimmed); err != nil {
+		return nil, err
+	}
+	return trimmed, nil
+}
+
+// trie is a local trie falling back to the upstream state. Deletions are
+// written as markers, so that deleted entries are not loaded again.
+type trie struct {
+	gstate.Trie
+	db       *Database
+	upstream bool // of a storage trie, whether it falls back to the upstream storage
+}
+
+// GetAccount returns the local account at addr, or the upstream one if the
+// account was never written locally.
+func (t *trie) GetAccount(addr common.Address) (*types.StateAccount, error) {
+	acc, err := t.Trie.GetAccount(addr)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return t.db.account(addr)
+	}
+	if common.BytesToHash(acc.CodeHash) == common.BytesToHash(deletedAccount.CodeHash) {
+		return nil, nil
+	}
+	return acc, nil
+}
+
+// DeleteAccount marks the account at addr deleted.
+func (t *trie) DeleteAccount(addr common.Address) error {
+	return t.Trie.UpdateAccount(addr, deletedAccount)
+}
+
+// GetStorage returns the local value of a storage slot of addr, or the
+// upstream one if the slot was never written locally and the account is the
+// upstream one.
+func (t *trie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	if bytes.Equal(key, upstreamSlot) {
+		return nil, nil
+	}
+	value, err := t.Trie.GetStorage(addr, key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(value) == 0 && t.upstream:
+		return t.db.storage(addr, key)
+	case len(value) == 0:
+		return nil, nil
+	case len(value) == 1 && value[0] == deletedSlot[0]:
+		return nil, nil
+	}
+	return value, nil
+}
+
+// DeleteStorage marks a storage slot of addr cleared.
+func (t *trie) DeleteStorage(addr common.Address, key []byte) error {
+	return t.Trie.UpdateStorage(addr, key, deletedSlot)
+}
@@ -0,0 +1,180 @@
+package fork
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upstream is a stand-in for the eth API of an upstream node, at one block.
+type upstream struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	code     map[common.Address][]byte
+	storage  map[common.Address]map[common.Hash]common.Hash
+}
+
+func (u *upstream) count(method string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.calls[method]++
+}
+
+func (u *upstream) GetBlockByNumber(number string, full bool) map[string]interface{} {
+	return map[string]interface{}{"number": hexutil.Uint64(100), "timestamp": hexutil.Uint64(1700000000)}
+}
+
+func (u *upstream) GetBalance(addr common.Address, block hexutil.Uint64) *hexutil.Big {
+	u.count("balance")
+	if b, ok := u.balances[addr]; ok {
+		return (*hexutil.Big)(b)
+	}
+	return new(hexutil.Big)
+}
+
+func (u *upstream) GetTransactionCount(addr common.Address, block hexutil.Uint64) hexutil.Uint64 {
+	u.count("nonce")
+	return hexutil.Uint64(u.nonces[addr])
+}
+
+func (u *upstream) GetCode(addr common.Address, block hexutil.Uint64) hexutil.Bytes {
+	u.count("code")
+	return u.code[addr]
+}
+
+func (u *upstream) GetStorageAt(addr common.Address, slot common.Hash, block hexutil.Uint64) hexutil.Bytes {
+	u.count("storage")
+	v := u.storage[addr][slot]
+	return v[:]
+}
+
+var (
+	contract = common.HexToAddress("0xc0ffee")
+	holder   = common.HexToAddress("0xabc")
+)
+
+func newUpstream(t *testing.T) (*upstream, *Client) {
+	u := &upstream{
+		calls:    make(map[string]int),
+		balances: map[common.Address]*big.Int{holder: big.NewInt(1000)},
+		nonces:   map[common.Address]uint64{holder: 3},
+		code:     map[common.Address][]byte{contract: {0x60, 0x00}},
+		storage: map[common.Address]map[common.Hash]common.Hash{
+			contract: {{1}: {2}, {3}: common.BigToHash(big.NewInt(4))},
+		},
+	}
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", u))
+	http := httptest.NewServer(server)
+	t.Cleanup(http.Close)
+
+	client, err := Dial(http.URL, 0)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return u, client
+}
+
+func TestDatabaseLoadsUpstreamState(t *testing.T) {
+	u, client := newUpstream(t)
+	assert.Equal(t, uint64(100), client.Block())
+	assert.Equal(t, uint64(1700000000), client.Time())
+
+	db := NewDatabase(gstate.NewDatabase(rawdb.NewMemoryDatabase()), client)
+	statedb, err := gstate.New(types.EmptyRootHash, db, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, big.NewInt(1000), statedb.GetBalance(holder))
+	assert.Equal(t, uint64(3), statedb.GetNonce(holder))
+	assert.Equal(t, []byte{0x60, 0x00}, statedb.GetCode(contract))
+	assert.Equal(t, common.Hash{2}, statedb.GetState(contract, common.Hash{1}))
+	assert.Equal(t, common.BigToHash(big.NewInt(4)), statedb.GetState(contract, common.Hash{3}))
+	assert.False(t, statedb.Exist(common.HexToAddress("0xdead")))
+	require.NoError(t, statedb.Error())
+
+	// only reads, so the local state is still empty
+	assert.Equal(t, types.EmptyRootHash, statedb.IntermediateRoot(false))
+
+	// writes are local, reads of what wasn't written still come from upstream
+	statedb.AddBalance(holder, big.NewInt(1))
+	statedb.SetState(contract, common.Hash{1}, common.Hash{})
+	statedb.SetState(contract, common.Hash{5}, common.Hash{6})
+	statedb.SelfDestruct(holder)
+	root, err := statedb.Commit(1, false)
+	require.NoError(t, err)
+
+	statedb, err = gstate.New(root, db, nil)
+	require.NoError(t, err)
+	assert.False(t, statedb.Exist(holder))
+	assert.Equal(t, common.Hash{}, statedb.GetState(contract, common.Hash{1}))
+	assert.Equal(t, common.BigToHash(big.NewInt(4)), statedb.GetState(contract, common.Hash{3}))
+	assert.Equal(t, common.Hash{6}, statedb.GetState(contract, common.Hash{5}))
+	assert.Equal(t, []byte{0x60, 0x00}, statedb.GetCode(contract))
+	require.NoError(t, statedb.Error())
+
+	// everything upstream was fetched once
+	assert.Equal(t, map[string]int{"balance": 3, "nonce": 3, "code": 3, "storage": 3}, u.calls)
+}
+
+func TestDatabaseCacheSurvivesRestart(t *testing.T) {
+	u, client := newUpstream(t)
+	disk := rawdb.NewMemoryDatabase()
+
+	for i := 0; i < 2; i++ {
+		db := NewDatabase(gstate.NewDatabase(disk), client)
+		statedb, err := gstate.New(types.EmptyRootHash, db, nil)
+		require.NoError(t, err)
+		assert.Equal(t, common.Hash{2}, statedb.GetState(contract, common.Hash{1}))
+		assert.Equal(t, []byte{0x60, 0x00}, statedb.GetCode(contract))
+	}
+	assert.Equal(t, map[string]int{"balance": 1, "nonce": 1, "code": 1, "storage": 1}, u.calls)
+}
+
+func TestRecreatedAccountIgnoresUpstreamStorage(t *testing.T) {
+	u, client := newUpstream(t)
+	u.code[holder] = []byte{0x60, 0x01}
+	u.storage[holder] = map[common.Hash]common.Hash{{1}: {7}}
+	db := NewDatabase(gstate.NewDatabase(rawdb.NewMemoryDatabase()), client)
+	statedb, err := gstate.New(types.EmptyRootHash, db, nil)
+	require.NoError(t, err)
+
+	// contract is destructed in a block and created again in the next one
+	assert.Equal(t, common.Hash{2}, statedb.GetState(contract, common.Hash{1}))
+	statedb.SelfDestruct(contract)
+	root, err := statedb.Commit(1, false)
+	require.NoError(t, err)
+	statedb, err = gstate.New(root, db, nil)
+	require.NoError(t, err)
+	statedb.CreateAccount(contract)
+	statedb.SetCode(contract, []byte{0x60, 0x02})
+	statedb.SetState(contract, common.Hash{5}, common.Hash{6})
+
+	// holder is destructed and created again in the same block
+	assert.Equal(t, common.Hash{7}, statedb.GetState(holder, common.Hash{1}))
+	statedb.SelfDestruct(holder)
+	statedb.Finalise(false)
+	statedb.CreateAccount(holder)
+	statedb.SetCode(holder, []byte{0x60, 0x02})
+	root, err = statedb.Commit(2, false)
+	require.NoError(t, err)
+
+	statedb, err = gstate.New(root, db, nil)
+	require.NoError(t, err)
+	assert.Equal(t, common.Hash{}, statedb.GetState(contract, common.Hash{1}))
+	assert.Equal(t, common.Hash{}, statedb.GetState(contract, common.Hash{3}))
+	assert.Equal(t, common.Hash{6}, statedb.GetState(contract, common.Hash{5}))
+	assert.Equal(t, common.Hash{}, statedb.GetState(holder, common.Hash{1}))
+	assert.Equal(t, []byte{0x60, 0x02}, statedb.GetCode(holder))
+	require.NoError(t, statedb.Error())
+}
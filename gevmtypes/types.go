@@ -1,5 +1,7 @@
 package gevmtypes
 
+import "encoding/json"
+
 // request is a JSON RPC request package assembled internally from the client
 // method calls.
 type Request struct {
@@ -11,11 +13,13 @@ type Request struct {
 
 // response is a JSON RPC response package sent back from the API server.
 type Response struct {
-	JsonRpc string `json:"jsonrpc"` // Version of the JSON RPC protocol, always set to 2.0
-	Id      int    `json:"id"`      // Auto incrementing ID number for this request
-	Error   []byte `json:"error"`   // Any error returned by the remote side
-	Result  []byte `json:"result"`  // Whatever the remote side sends us in reply
-	GasLeft uint64 `json:"gasLeft"` // Gas left over from the transaction
+	JsonRpc string          `json:"jsonrpc"`         // Version of the JSON RPC protocol, always set to 2.0
+	Id      int             `json:"id"`              // Auto incrementing ID number for this request
+	Error   []byte          `json:"error"`           // Any error returned by the remote side
+	Result  []byte          `json:"result"`          // Whatever the remote side sends us in reply
+	GasLeft uint64          `json:"gasLeft"`         // Gas left over from the transaction
+	Trace   json.RawMessage `json:"trace,omitempty"` // Result of the tracer of a traced request
+	Data    json.RawMessage `json:"data,omitempty"`  // Debug output, such as console.log messages and the stack trace of an error
 }
 
 // transaction is the data payload from the caller
@@ -33,6 +37,12 @@ type Weather struct {
 	Weather int `json:"weather"`
 }
 
+// oracleUpdate is a type sent when publishing a game value to the oracle
+type OracleUpdate struct {
+	Key   string `json:"key"`   // name of the game value, hashed into the oracle key
+	Value uint64 `json:"value"` // new value
+}
+
 // keep track of request ids
 type Ids struct {
 	EthCall               int
@@ -0,0 +1,62 @@
+// Package evmtest runs calls on an in-memory EVM for the tests of the tracers
+// and the tools built on them.
+package evmtest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+// Gas is the gas given to a call.
+const Gas = 100000
+
+// Sender is the account calls are sent from, funded with 1 ether.
+var Sender = common.HexToAddress("0x0b")
+
+// NewState returns an empty in-memory state with the given code deployed.
+func NewState(t testing.TB, code map[common.Address][]byte) *gstate.StateDB {
+	statedb, err := gstate.New(common.Hash{}, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	require.NoError(t, err)
+	statedb.SetBalance(Sender, big.NewInt(1e18))
+	for addr, c := range code {
+		statedb.SetCode(addr, c)
+	}
+	return statedb
+}
+
+// Call calls to with input from Sender on statedb, with tracer attached the
+// way the node attaches it to a transaction, and returns the gas used.
+func Call(statedb *gstate.StateDB, tracer vm.EVMLogger, to common.Address, input []byte) (ret []byte, gasUsed uint64, err error) {
+	statedb.Finalise(false)
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		BlockNumber: big.NewInt(1),
+		Difficulty:  big.NewInt(0),
+		GasLimit:    1e9,
+	}
+	txCtx := vm.TxContext{Origin: Sender, GasPrice: big.NewInt(0)}
+	evm := vm.NewEVM(blockCtx, txCtx, statedb, params.TestChainConfig, vm.Config{NoBaseFee: true, Tracer: tracer})
+	if tracer != nil {
+		tracer.CaptureTxStart(Gas)
+	}
+	ret, gasLeft, err := evm.Call(vm.AccountRef(Sender), to, input, Gas, big.NewInt(0))
+	if tracer != nil {
+		tracer.CaptureTxEnd(gasLeft)
+	}
+	return ret, Gas - gasLeft, err
+}
+
+// Run calls to with input on a state with the given code deployed, with
+// tracer attached.
+func Run(t testing.TB, code map[common.Address][]byte, tracer vm.EVMLogger, to common.Address, input []byte) (ret []byte, gasUsed uint64, err error) {
+	return Call(NewState(t, code), tracer, to, input)
+}
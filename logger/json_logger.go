@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// jsonStep is an opcode in the EIP-3155 format.
+type jsonStep struct {
+	Pc         uint64              `json:"pc"`
+	Op         vm.OpCode           `json:"op"`
+	Gas        math.HexOrDecimal64 `json:"gas"`
+	GasCost    math.HexOrDecimal64 `json:"gasCost"`
+	Memory     hexutil.Bytes       `json:"memory,omitempty"`
+	MemorySize int                 `json:"memSize"`
+	Stack      []string            `json:"stack"`
+	ReturnData hexutil.Bytes       `json:"returnData,omitempty"`
+	Depth      int                 `json:"depth"`
+	Refund     uint64              `json:"refund"`
+	OpName     string              `json:"opName"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// jsonSummary ends the trace of a call in the EIP-3155 format.
+type jsonSummary struct {
+	Output  hexutil.Bytes       `json:"output"`
+	GasUsed math.HexOrDecimal64 `json:"gasUsed"`
+	Pass    bool                `json:"pass"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// JSONLogger streams the trace of the EVM to a writer as it runs, one JSON
+// object per line in the EIP-3155 format: one per opcode, then a summary of
+// the top-level call. Nothing is kept in memory, so it can trace executions of
+// any length, and its output can be diffed with the traces of geth or evmone.
+type JSONLogger struct {
+	encoder *json.Encoder
+	cfg     *Config
+	env     *vm.EVM
+}
+
+// NewJSONLogger creates a logger writing the EIP-3155 trace to writer. The
+// storage options of cfg are ignored, EIP-3155 traces have no storage.
+func NewJSONLogger(cfg *Config, writer io.Writer) *JSONLogger {
+	l := &JSONLogger{encoder: json.NewEncoder(writer), cfg: cfg}
+	if l.cfg == nil {
+		l.cfg = &Config{}
+	}
+	return l
+}
+
+func (l *JSONLogger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	l.env = env
+}
+
+func (l *JSONLogger) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	// the failing opcode was already written by CaptureState
+}
+
+// CaptureState writes the opcode about to be executed.
+func (l *JSONLogger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	memory := scope.Memory
+	step := jsonStep{
+		Pc:         pc,
+		Op:         op,
+		Gas:        math.HexOrDecimal64(gas),
+		GasCost:    math.HexOrDecimal64(cost),
+		MemorySize: memory.Len(),
+		Depth:      depth,
+		Refund:     l.env.StateDB.GetRefund(),
+		OpName:     op.String(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	if l.cfg.EnableMemory {
+		step.Memory = memory.Data()
+	}
+	if !l.cfg.DisableStack {
+		data := scope.Stack.Data()
+		step.Stack = make([]string, len(data))
+		for i, elem := range data {
+			step.Stack[i] = elem.Hex()
+		}
+	}
+	if l.cfg.EnableReturnData {
+		step.ReturnData = rData
+	}
+	l.encoder.Encode(step)
+}
+
+// CaptureEnd writes the summary of the top-level call.
+func (l *JSONLogger) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	summary := jsonSummary{Output: output, GasUsed: math.HexOrDecimal64(gasUsed), Pass: err == nil}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	l.encoder.Encode(summary)
+}
+
+func (l *JSONLogger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (l *JSONLogger) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (l *JSONLogger) CaptureTxStart(gasLimit uint64) {}
+
+func (l *JSONLogger) CaptureTxEnd(restGas uint64) {}
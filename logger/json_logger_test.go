@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogger(t *testing.T) {
+	contract := common.HexToAddress("0xc0de")
+	statedb, err := gstate.New(common.Hash{}, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	require.NoError(t, err)
+	// PUSH1 1 PUSH1 2 ADD POP INVALID
+	statedb.SetCode(contract, common.FromHex("0x600160020150fe"))
+
+	var out bytes.Buffer
+	blockCtx := vm.BlockContext{
+		CanTransfer: func(vm.StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(vm.StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(1),
+		Difficulty:  big.NewInt(0),
+	}
+	tracer := NewJSONLogger(&Config{EnableMemory: true}, &out)
+	evm := vm.NewEVM(blockCtx, vm.TxContext{GasPrice: big.NewInt(0)}, statedb, params.TestChainConfig, vm.Config{Tracer: tracer})
+	_, _, err = evm.Call(vm.AccountRef(common.Address{}), contract, nil, 100000, big.NewInt(0))
+	require.Error(t, err)
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 6, "one line per opcode and the summary")
+
+	add := lines[2]
+	assert.Equal(t, "ADD", add["opName"])
+	assert.Equal(t, float64(4), add["pc"])
+	assert.Equal(t, []any{"0x1", "0x2"}, add["stack"])
+	assert.Equal(t, "0x3", add["gasCost"])
+	assert.Equal(t, float64(1), add["depth"])
+
+	assert.Equal(t, "INVALID", lines[4]["opName"])
+
+	summary := lines[5]
+	assert.Equal(t, false, summary["pass"])
+	assert.Equal(t, "0x186a0", summary["gasUsed"], "an invalid opcode consumes all the gas")
+	assert.NotEmpty(t, summary["error"])
+}
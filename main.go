@@ -1,13 +1,218 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/curio-research/keystone-starter-kit/server/data"
+	kserver "github.com/curio-research/keystone/server"
+	"github.com/curio-research/keystone/server/startup"
+	"github.com/daweth/gevm/config"
+	cvm "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/debugger"
+	"github.com/daweth/gevm/fork"
 	server "github.com/daweth/gevm/node"
+	"github.com/daweth/gevm/scheduler"
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	glog "github.com/ethereum/go-ethereum/log"
+	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds the wait for the requests in flight on shutdown.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
-	s := server.NewServer()
-	fmt.Println("Start the server on port 8080")
-	s.Server.Run() // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
+	cfg, err := config.Parse(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := setupLogging(cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := newApp(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Keystone.Enabled {
+		// the game precompiles of EVMs not bound to a chain read the world
+		// of the default chain
+		vm.InitializeEngine(s.Node.Evm.Config.Engine)
+	}
+	chains, err := server.NewChains(s, chainFactory(cfg))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.HTTP.Admin {
+		chains.EnableAdmin()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: cfg.HTTP.Listen, Handler: chains.Server}
+	go func() {
+		fmt.Println("Start the server on", cfg.HTTP.Listen)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	var dap *debugger.Server
+	if cfg.DAP.Listen != "" {
+		l, err := net.Listen("tcp", cfg.DAP.Listen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dap = debugger.NewServer(s.DebugTarget())
+		go func() {
+			fmt.Println("Start the debug adapter on", cfg.DAP.Listen)
+			if err := dap.Serve(l); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+	<-ctx.Done()
+	stop()
+
+	if err := shutdown(srv, dap, chains); err != nil {
+		log.Fatal("shutdown failed: ", err)
+	}
+}
+
+// shutdown stops accepting requests, waits for the ones in flight, and closes
+// every chain, flushing its state to the database. The chains are closed even
+// if requests are still in flight when the timeout expires. The debug adapter,
+// if any, is closed first, so that the executions paused by its sessions don't
+// hold the chains.
+func shutdown(srv *http.Server, dap *debugger.Server, chains *server.Chains) error {
+	fmt.Println("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if dap != nil {
+		err = errors.Join(err, dap.Close())
+	}
+	if err := errors.Join(err, chains.Close()); err != nil {
+		return err
+	}
+	fmt.Println("Shutdown complete")
+	return nil
+}
+
+// newApp creates the server of the chain described by cfg, with its own
+// game engine if Keystone is enabled.
+func newApp(cfg *config.Config) (*server.App, error) {
+	node, err := newNode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var engine *kserver.EngineCtx
+	if cfg.Keystone.Enabled {
+		engine = startup.NewGameEngine()
+		engine.SetTickRate(cfg.Keystone.TickRateMs)
+		engine.SetPort(cfg.Keystone.Port)
+		engine.SetWebsocketPort(cfg.Keystone.WebsocketPort)
+		engine.AddTables(data.TableSchemasToAccessors)
+		node.BindEngine(engine)
+	}
+	s := server.NewServerForNode(node)
+	s.EnableNamespaces(cfg.RPC.Namespaces...)
+	if len(cfg.Artifacts) > 0 {
+		artifacts, err := sourcemap.Load(cfg.Artifacts...)
+		if err != nil {
+			return nil, err
+		}
+		s.UseArtifacts(artifacts)
+		if err := s.ABIs().AddArtifacts(artifacts); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Console {
+		s.EnableConsole()
+	}
+	if cfg.HTTP.OracleToken != "" {
+		s.EnableOracleWrites(cfg.HTTP.OracleToken)
+	}
+
+	switch cfg.Mining.Mode {
+	case cvm.MineInstant:
+		s.Exec.SealInstantly()
+	case cvm.MineInterval:
+		s.Exec.SealEvery(cfg.Mining.Interval)
+	}
+	if engine != nil {
+		if cfg.Mining.Mode == cvm.MineTick {
+			scheduler.New(s.Exec).Attach(engine)
+		}
+		if err := s.RunEngine(engine); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// chainFactory creates the chains of the admin API. They are configured like
+// the default chain, with their own chain id, database directory and Keystone
+// ports, and are never forked.
+func chainFactory(cfg *config.Config) server.ChainFactory {
+	return func(spec server.ChainSpec) (*server.App, error) {
+		c := *cfg
+		c.Chain.ChainID = spec.ChainID
+		c.DataDir = filepath.Join(cfg.DataDir, "chains", strconv.FormatUint(spec.ChainID, 10))
+		c.Fork = config.Fork{}
+		if c.Keystone.Enabled {
+			if spec.KeystonePort == 0 || spec.KeystoneWebsocketPort == 0 {
+				return nil, errors.New("the chain needs its own Keystone ports")
+			}
+			c.Keystone.Port = spec.KeystonePort
+			c.Keystone.WebsocketPort = spec.KeystoneWebsocketPort
+		}
+		return newApp(&c)
+	}
+}
+
+// newNode creates the node described by cfg, forked from another chain if
+// fork mode is on.
+func newNode(cfg *config.Config) (cvm.NodeCtx, error) {
+	genesis, err := cfg.GenesisBlock()
+	if err != nil {
+		return cvm.NodeCtx{}, err
+	}
+	db, err := cfg.OpenDatabase()
+	if err != nil {
+		return cvm.NodeCtx{}, err
+	}
+	if cfg.Fork.URL == "" {
+		return cvm.NewNode(db, genesis)
+	}
+	client, err := fork.Dial(cfg.Fork.URL, cfg.Fork.Block)
+	if err != nil {
+		return cvm.NodeCtx{}, err
+	}
+	return cvm.NewForkNode(db, client, genesis)
+}
+
+// setupLogging sets the level of the go-ethereum logs and of the HTTP server.
+func setupLogging(level string) error {
+	lvl, err := glog.LvlFromString(level)
+	if err != nil {
+		return err
+	}
+	glog.Root().SetHandler(glog.LvlFilterHandler(lvl, glog.StreamHandler(os.Stderr, glog.TerminalFormat(true))))
+	if lvl < glog.LvlDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	return nil
 }
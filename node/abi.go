@@ -0,0 +1,205 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cvm "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/decoder"
+	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/tracers"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	gtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// ABIs returns the registry of the ABIs the app decodes revert data, calldata
+// and logs with, in the debug output of requests, in simulation results and in
+// callTracer traces.
+func (app *App) ABIs() *decoder.Registry {
+	return app.abis
+}
+
+// handleABI serves the methods of the abi namespace:
+//
+//	abi_register  [addressOrCodeHash, abi, name]
+//
+// registers the ABI of the contract at an address, or of the contracts whose
+// deployed code has the given hash. The ABI is the JSON array of the compiler,
+// or a string holding it, and the name is optional. ok is false if the method
+// is not one of them.
+func (app *App) handleABI(r gt.Request) (resp rpcResponse, ok bool) {
+	var (
+		result json.RawMessage
+		err    error
+	)
+	switch r.Method {
+	case "abi_register":
+		err = app.registerABI(r.Params)
+		result = json.RawMessage("true")
+	default:
+		return rpcResponse{}, false
+	}
+
+	resp = rpcResponse{JsonRpc: "2.0", Id: r.Id, Result: result}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+	}
+	return resp, true
+}
+
+func (app *App) registerABI(params []interface{}) error {
+	target, err := paramBytes(params, 0)
+	if err != nil {
+		return err
+	}
+	p, err := param(params, 1)
+	if err != nil {
+		return err
+	}
+	abiJSON, ok := p.(string)
+	if !ok {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		abiJSON = string(data)
+	}
+	var name string
+	if len(params) > 2 && params[2] != nil {
+		if name, ok = params[2].(string); !ok {
+			return fmt.Errorf("parameter 2: invalid name %v", params[2])
+		}
+	}
+	c, err := decoder.NewContract(name, []byte(abiJSON))
+	if err != nil {
+		return fmt.Errorf("parameter 1: invalid ABI: %w", err)
+	}
+	switch len(target) {
+	case common.AddressLength:
+		app.abis.AddAddress(common.BytesToAddress(target), c)
+	case common.HashLength:
+		app.abis.AddCode(common.BytesToHash(target), c)
+	default:
+		return fmt.Errorf("parameter 0: %x is neither an address nor a code hash", target)
+	}
+	return nil
+}
+
+// contracts returns a func looking up the ABIs of contracts, by their address
+// or the hash of their code read with codeHash, which is only called for the
+// contracts not registered by address.
+func (app *App) contracts(codeHash func(common.Address) common.Hash) func(common.Address) *decoder.Contract {
+	return func(addr common.Address) *decoder.Contract {
+		if c := app.abis.Lookup(addr, common.Hash{}); c != nil {
+			return c
+		}
+		return app.abis.Lookup(addr, codeHash(addr))
+	}
+}
+
+// decodeRevert decodes the output of a call of the contract c, which may be
+// nil, if the call reverted. It is nil if the output can't be decoded.
+func (app *App) decodeRevert(c *decoder.Contract, output []byte, reverted bool) *decoder.Revert {
+	if !reverted || len(output) == 0 {
+		return nil
+	}
+	rev, _ := app.abis.DecodeRevert(c, output)
+	return rev
+}
+
+// decodeCall decodes the calldata of a call of the contract c, which may be
+// nil, nil if it can't be decoded.
+func (app *App) decodeCall(c *decoder.Contract, input []byte) *decoder.Decoded {
+	d, _ := app.abis.DecodeCall(c, input)
+	return d
+}
+
+// decodeLogs decodes logs with the ABIs of the contracts emitting them, nil
+// for the logs that can't be decoded.
+func (app *App) decodeLogs(contract func(common.Address) *decoder.Contract, logs []*gtypes.Log) []*decoder.Decoded {
+	events := make([]*decoder.Decoded, len(logs))
+	for i, l := range logs {
+		events[i], _ = app.abis.DecodeLog(contract(l.Address), l.Topics, l.Data)
+	}
+	return events
+}
+
+// decodedSimResult is the outcome of a simulation step, with its calldata,
+// revert data and logs decoded.
+type decodedSimResult struct {
+	cvm.SimResult
+	Call   *decoder.Decoded   `json:"call,omitempty"`
+	Revert *decoder.Revert    `json:"revert,omitempty"`
+	Events []*decoder.Decoded `json:"events,omitempty"`
+}
+
+// decodeSimResults decodes the results of steps run in the session s, if the
+// app has ABIs.
+func (app *App) decodeSimResults(s *cvm.Session, steps []cvm.SimStep, results []cvm.SimResult) interface{} {
+	if app.abis.Len() == 0 {
+		return results
+	}
+	contract := app.contracts(s.CodeHash)
+	decoded := make([]decodedSimResult, len(results))
+	for i, result := range results {
+		decoded[i].SimResult = result
+		var c *decoder.Contract
+		if to := steps[i].Tx.To; to != "" {
+			c = contract(common.HexToAddress(to))
+			decoded[i].Call = app.decodeCall(c, []byte(steps[i].Tx.Data))
+		}
+		decoded[i].Revert = app.decodeRevert(c, result.Output, result.Error == vm.ErrExecutionReverted.Error())
+		decoded[i].Events = app.decodeLogs(contract, result.Logs)
+	}
+	return decoded
+}
+
+// decodedFrame is a frame of a callTracer trace, with its calldata and revert
+// data decoded.
+type decodedFrame struct {
+	tracers.CallFrame
+	Method      *decoder.Decoded `json:"method,omitempty"`
+	RevertError *decoder.Revert  `json:"revertError,omitempty"`
+	Calls       []decodedFrame   `json:"calls,omitempty"`
+}
+
+// decodeTrace decodes the calldata and revert data of the frames of a trace
+// of the callTracer, if the app has ABIs. Other traces are left as they are.
+// Contracts are looked up with the code hash the tracer recorded in each
+// frame, so that old blocks are decoded with the code they ran.
+func (app *App) decodeTrace(cfg *tracers.Config, trace json.RawMessage) json.RawMessage {
+	if cfg == nil || cfg.Tracer != "callTracer" || app.abis.Len() == 0 || trace == nil {
+		return trace
+	}
+	var top decodedFrame
+	if err := json.Unmarshal(trace, &top); err != nil {
+		return trace
+	}
+	var decode func(f *decodedFrame)
+	decode = func(f *decodedFrame) {
+		var c *decoder.Contract
+		if f.To != nil {
+			c = app.contracts(func(common.Address) common.Hash {
+				if f.CodeHash == nil {
+					return common.Hash{}
+				}
+				return *f.CodeHash
+			})(*f.To)
+		}
+		if f.Type != vm.CREATE.String() && f.Type != vm.CREATE2.String() {
+			f.Method = app.decodeCall(c, f.Input)
+		}
+		f.RevertError = app.decodeRevert(c, f.Output, f.Error == vm.ErrExecutionReverted.Error())
+		for i := range f.Calls {
+			decode(&f.Calls[i])
+		}
+	}
+	decode(&top)
+	decoded, err := json.Marshal(top)
+	if err != nil {
+		return trace
+	}
+	return decoded
+}
@@ -1,47 +1,100 @@
 package node
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
+	"strings"
+	"sync"
 
+	kserver "github.com/curio-research/keystone/server"
 	cvm "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/decoder"
 	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-gonic/gin"
 )
 
 type App struct {
-	Server  *gin.Engine
-	Node    cvm.NodeCtx
-	Weather gt.Weather
-	Count   *gt.Ids
+	Server *gin.Engine
+	Node   cvm.NodeCtx   // only accessed through Exec once the server is created
+	Exec   *cvm.Executor // runs the transactions and calls of concurrent requests
+	Count  *gt.Ids
+
+	namespaces map[string]bool     // JSON-RPC namespaces served, see EnableNamespaces
+	artifacts  *sourcemap.Registry // contracts stack traces are mapped to, see UseArtifacts
+	abis       *decoder.Registry   // ABIs outputs are decoded with, see ABIs
+	console    bool                // collect console.log messages, see EnableConsole
+	oracleAuth string              // bearer token of the oracle routes, see EnableOracleWrites
+	sessions   *sessions           // simulation sessions, see simRoutes
+	engine     *Engine             // the game engine of the chain, see RunEngine
+
+	countMu sync.Mutex // protects Count
 }
 
 func NewServer() *App {
+	return NewServerForNode(cvm.Default())
+}
+
+// NewServerForNode creates a server for the given node, such as a node forked
+// from another chain.
+func NewServerForNode(node cvm.NodeCtx) *App {
 	app := &App{
-		Server:  gin.Default(),
-		Node:    cvm.Default(),
-		Weather: gt.Weather{},
-		Count:   &gt.Ids{},
+		Server: gin.Default(),
+		Node:   node,
+		Count:  &gt.Ids{},
+
+		namespaces: map[string]bool{"eth": true},
+		abis:       decoder.NewRegistry(),
+		sessions:   newSessions(),
 	}
+	app.Exec = cvm.NewExecutor(&app.Node)
+	app.simRoutes()
+	app.sessions.sweepEvery(sweepInterval)
 
 	// simple sanity check
 	app.Server.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"jsonrpc": "2.0", "id": 1, "result": "0x3503de5f0c766c68f78a03a3b05036a5"})
 	})
 
-	app.Server.POST("/setWeather", func(c *gin.Context) {
+	app.Server.POST("/setWeather", app.authorizeOracle, func(c *gin.Context) {
 		fmt.Println("printing the request body", c.Request.Body)
 		var w gt.Weather
 
 		if err := c.BindJSON(&w); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		app.handleSetWeather(w)
+		if err := app.handleSetWeather(w); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		fmt.Println("printing the new weather value", w.Weather)
 		c.JSON(http.StatusOK, gin.H{"status": "Success"})
 	})
 
+	app.Server.POST("/setOracle", app.authorizeOracle, func(c *gin.Context) {
+		var u gt.OracleUpdate
+
+		if err := c.BindJSON(&u); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := app.handleSetOracle(u); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "Success"})
+	})
+
 	app.Server.POST("/rpc", func(c *gin.Context) {
 		var req gt.Request
 		var resp gt.Response
@@ -51,6 +104,27 @@ func NewServer() *App {
 
 		fmt.Println("printing the request", req.Method)
 
+		if ns, _, _ := strings.Cut(req.Method, "_"); !app.namespaces[ns] {
+			c.PureJSON(http.StatusOK, gt.Response{
+				JsonRpc: "2.0",
+				Id:      req.Id,
+				Error:   []byte(fmt.Sprintf("the method %s is not available", req.Method)),
+			})
+			return
+		}
+		if resp, ok := app.handleDev(req); ok {
+			c.PureJSON(http.StatusOK, resp)
+			return
+		}
+		if resp, ok := app.handleDebug(req); ok {
+			c.PureJSON(http.StatusOK, resp)
+			return
+		}
+		if resp, ok := app.handleABI(req); ok {
+			c.PureJSON(http.StatusOK, resp)
+			return
+		}
+
 		switch m := req.Method; m {
 
 		case "eth_call":
@@ -74,6 +148,10 @@ func NewServer() *App {
 		case "eth_seed":
 			fmt.Println("eth seed txn")
 			resp = app.handleEthSeed(req)
+		case "eth_sendBatch":
+			fmt.Println("eth send batch")
+			c.PureJSON(http.StatusOK, app.handleEthSendBatch(req))
+			return
 		default:
 			resp = gt.Response{
 				JsonRpc: "2.0",
@@ -89,23 +167,34 @@ func NewServer() *App {
 	return app
 }
 
+// EnableNamespaces serves the methods of the given JSON-RPC namespaces, such
+// as evm, hardhat and anvil for the test methods, which change the chain at
+// will and are meant for development only. Only eth is served by default.
+// Namespaces must be enabled before the server starts.
+func (app *App) EnableNamespaces(namespaces ...string) {
+	for _, ns := range namespaces {
+		app.namespaces[ns] = true
+	}
+}
+
 func (app *App) handleEthCall(r gt.Request) gt.Response {
 	p := r.Params
 
 	var tx gt.Transaction
 	tx = RawTxToTxObject(p[0].(string))
 
-	o, g := app.Node.HandleTransaction(tx)
-
-	currId := app.Count.EthCall
-	app.Count.EthCall++
+	// calls run on a copy of the state and don't change the node
+	var data json.RawMessage
+	o, g, trace, err := app.traced(p, 1, app.withDebug(&data, app.callExecution(tx)))
 
 	return gt.Response{
 		JsonRpc: "2.0",
-		Id:      currId,
-		Error:   []byte(""),
+		Id:      app.nextId(&app.Count.EthCall),
+		Error:   errorBytes(err),
 		Result:  o,
 		GasLeft: g,
+		Trace:   trace,
+		Data:    data,
 	}
 }
 
@@ -116,17 +205,17 @@ func (app *App) handleEthSend(r gt.Request) gt.Response {
 	tx = RawTxToTxObject(p[0].(string))
 	// check that no transaction data exists
 
-	o, g := app.Node.HandleTransaction(tx)
-
-	currId := app.Count.EthSend
-	app.Count.EthSend++
+	var data json.RawMessage
+	o, g, trace, err := app.traced(p, 1, app.withDebug(&data, app.sendExecution(tx)))
 
 	return gt.Response{
 		JsonRpc: "2.0",
-		Id:      currId,
-		Error:   []byte(""),
+		Id:      app.nextId(&app.Count.EthSend),
+		Error:   errorBytes(err),
 		Result:  o,
 		GasLeft: g,
+		Trace:   trace,
+		Data:    data,
 	}
 }
 
@@ -136,15 +225,12 @@ func (app *App) handleEthSeed(r gt.Request) gt.Response {
 	var tx gt.Transaction
 	tx = RawTxToTxObject(p[0].(string))
 
-	o, g := app.Node.HandleTransaction(tx)
-
-	currId := app.Count.EthSeed
-	app.Count.EthSeed++
+	o, g, err := app.Exec.ApplyTransaction(tx)
 
 	return gt.Response{
 		JsonRpc: "2.0",
-		Id:      currId,
-		Error:   []byte(""),
+		Id:      app.nextId(&app.Count.EthSeed),
+		Error:   errorBytes(err),
 		Result:  o,
 		GasLeft: g,
 	}
@@ -156,18 +242,59 @@ func (app *App) handleEthSendRawTransaction(r gt.Request) gt.Response {
 	var tx gt.Transaction
 	tx = RawTxToTxObject(p[0].(string))
 
-	o, g := app.Node.HandleTransaction(tx)
-
-	currId := app.Count.EthSend
-	app.Count.EthSendRawTransaction++
+	var data json.RawMessage
+	o, g, trace, err := app.traced(p, 1, app.withDebug(&data, app.sendExecution(tx)))
 
 	return gt.Response{
 		JsonRpc: "2.0",
-		Id:      currId,
-		Error:   []byte(""),
+		Id:      app.nextId(&app.Count.EthSendRawTransaction),
+		Error:   errorBytes(err),
 		Result:  o,
 		GasLeft: g,
+		Trace:   trace,
+		Data:    data,
+	}
+}
+
+// batchResult is the outcome of a transaction of an eth_sendBatch request.
+type batchResult struct {
+	Output  hexutil.Bytes `json:"output"`
+	GasLeft uint64        `json:"gasLeft"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// handleEthSendBatch serves eth_sendBatch [rawTx, ...]: the transactions are
+// executed in parallel as part of the block being built, and the block ends up
+// as if they were sent one by one in order. The batch is not traced.
+func (app *App) handleEthSendBatch(r gt.Request) rpcResponse {
+	resp := rpcResponse{JsonRpc: "2.0", Id: r.Id}
+	txs := make([]gt.Transaction, len(r.Params))
+	for i, p := range r.Params {
+		raw, ok := p.(string)
+		if !ok {
+			resp.Error = &rpcError{Code: -32602, Message: fmt.Sprintf("parameter %d: invalid raw transaction %v", i, p)}
+			return resp
+		}
+		txs[i] = RawTxToTxObject(raw)
+	}
+
+	results, err := app.Exec.ApplyTransactions(txs, 0)
+	if err != nil {
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+		return resp
+	}
+	batch := make([]batchResult, len(results))
+	for i, res := range results {
+		batch[i] = batchResult{Output: res.Output, GasLeft: res.GasLeft}
+		if res.Err != nil {
+			batch[i].Error = res.Err.Error()
+		}
+	}
+	resp.Result, err = json.Marshal(batch)
+	if err != nil {
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
 	}
+	return resp
 }
 
 func (app *App) handleEthGetBalance(r gt.Request) gt.Response {
@@ -176,22 +303,97 @@ func (app *App) handleEthGetBalance(r gt.Request) gt.Response {
 	var tx gt.Transaction
 	tx = RawTxToTxObject(p[0].(string))
 
-	o := app.Node.HandleGetBalance(tx)
-
-	currId := app.Count.EthSend
-	app.Count.EthSendRawTransaction++
+	var result []byte
+	bal, err := app.Exec.GetBalance(common.HexToAddress(tx.From))
+	if err == nil {
+		result = Uint64ToBytes(bal.Uint64())
+	}
 
 	return gt.Response{
 		JsonRpc: "2.0",
-		Id:      currId,
-		Error:   []byte(""),
-		Result:  Uint64ToBytes(o),
+		Id:      app.nextId(&app.Count.EthSendRawTransaction),
+		Error:   errorBytes(err),
+		Result:  result,
 		GasLeft: 0,
 	}
 
 }
 
-func (app *App) handleSetWeather(r gt.Weather) {
-	w := &app.Weather
-	w.Weather = r.Weather
+// RunEngine starts the Keystone engine of the chain, see StartEngine. It is
+// stopped when the app is closed.
+func (app *App) RunEngine(ctx *kserver.EngineCtx) error {
+	engine, err := StartEngine(ctx)
+	if err != nil {
+		return err
+	}
+	app.engine = engine
+	return nil
+}
+
+// Close stops the game engine, discards the simulation sessions, stops the
+// executor and closes the node, flushing its state to the database. It returns
+// the head of the chain.
+func (app *App) Close() (*cvm.Block, error) {
+	var err error
+	if app.engine != nil {
+		err = app.engine.Stop()
+	}
+	app.sessions.close()
+	app.Exec.Close()
+	head, closeErr := app.Node.Close()
+	return head, errors.Join(closeErr, err)
+}
+
+// nextId returns the next id of a request counter.
+func (app *App) nextId(counter *int) int {
+	app.countMu.Lock()
+	defer app.countMu.Unlock()
+
+	id := *counter
+	*counter++
+	return id
+}
+
+// errorBytes returns the error of a response.
+func errorBytes(err error) []byte {
+	if err == nil {
+		return []byte("")
+	}
+	return []byte(err.Error())
+}
+
+// EnableOracleWrites serves /setWeather and /setOracle to the requests
+// bearing token in their Authorization header. They are refused otherwise.
+func (app *App) EnableOracleWrites(token string) {
+	app.oracleAuth = token
+}
+
+// authorizeOracle aborts the requests to the oracle routes without the bearer
+// token of EnableOracleWrites.
+func (app *App) authorizeOracle(c *gin.Context) {
+	if app.oracleAuth == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "oracle writes are disabled"})
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.oracleAuth)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid oracle token"})
+		return
+	}
+}
+
+// the weather is published through the game oracle by the node itself, so
+// that contracts read it from chain state as of the block they execute in
+func (app *App) handleSetWeather(r gt.Weather) error {
+	value := big.NewInt(int64(r.Weather))
+	return app.Exec.Do(func(n *cvm.NodeCtx) error {
+		return n.PublishOracleValue(vm.WeatherKey, value)
+	})
+}
+
+func (app *App) handleSetOracle(u gt.OracleUpdate) error {
+	value := new(big.Int).SetUint64(u.Value)
+	return app.Exec.Do(func(n *cvm.NodeCtx) error {
+		return n.PublishOracleValue(vm.OracleKey(u.Key), value)
+	})
 }
@@ -0,0 +1,402 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	cvm "github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrChainExists is returned when creating a chain whose id is taken.
+	ErrChainExists = errors.New("chain already exists")
+	// ErrUnknownChain is returned for a chain id that is not hosted.
+	ErrUnknownChain = errors.New("unknown chain")
+	// ErrDefaultChain is returned when deleting the default chain.
+	ErrDefaultChain = errors.New("the default chain cannot be deleted")
+	// ErrNoSettlement is returned when settling a chain created without a
+	// settlement spec.
+	ErrNoSettlement = errors.New("chain has no settlement")
+	// ErrSettling is returned when creating, deleting or settling a chain
+	// while it is being settled.
+	ErrSettling = errors.New("chain is being settled")
+)
+
+// ChainSpec describes a chain created through the admin API.
+type ChainSpec struct {
+	ChainID uint64 `json:"chainId"`
+
+	// ports of the Keystone game server of the chain, if Keystone is enabled
+	KeystonePort          int `json:"keystonePort,omitempty"`
+	KeystoneWebsocketPort int `json:"keystoneWebsocketPort,omitempty"`
+
+	// Settlement designates the parent chain the chain settles into when the
+	// match ends, see Chains.Settle.
+	Settlement *SettlementSpec `json:"settlement,omitempty"`
+}
+
+// SettlementSpec says where and what a match chain settles, see
+// cvm.Settlement.
+type SettlementSpec struct {
+	Parent   uint64         `json:"parent"`   // chain id of the parent chain, hosted in the same server
+	Contract common.Address `json:"contract"` // settlement contract on the parent chain
+	From     common.Address `json:"from"`     // account sending the settlement transaction
+	Gas      uint64         `json:"gas"`
+
+	// storage slots of the match chain whose final values are settled
+	Storage map[common.Address][]common.Hash `json:"storage"`
+}
+
+// ChainFactory creates the server of a new chain, with its own node, database
+// and game engine.
+type ChainFactory func(spec ChainSpec) (*App, error)
+
+// Chains hosts several isolated chains in one server, such as one per game
+// match. Each chain is an App of its own, served under /chains/<chain id>/,
+// e.g. /chains/42/rpc. The routes of the default chain are also served
+// without the prefix.
+type Chains struct {
+	Server *gin.Engine
+
+	factory ChainFactory
+	def     uint64 // chain id of the default chain
+
+	mu          sync.RWMutex // protects chains, settlements, settling and closed
+	chains      map[uint64]*App
+	settlements map[uint64]*SettlementSpec
+	settling    map[uint64]bool // chains not served while being settled, see Settle
+	closed      bool
+}
+
+// NewChains hosts the default chain def, created as usual, and the chains
+// created through the admin API with factory.
+func NewChains(def *App, factory ChainFactory) (*Chains, error) {
+	cs := &Chains{
+		Server:  gin.Default(),
+		factory: factory,
+		chains:  make(map[uint64]*App),
+
+		settlements: make(map[uint64]*SettlementSpec),
+		settling:    make(map[uint64]bool),
+	}
+	id, err := cs.Add(def)
+	if err != nil {
+		return nil, err
+	}
+	cs.def = id
+
+	cs.Server.Any("/chains/:id/*path", func(c *gin.Context) {
+		app, err := cs.chain(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		serve(app, c, c.Param("path"))
+	})
+	cs.Server.NoRoute(func(c *gin.Context) {
+		serve(def, c, c.Request.URL.Path)
+	})
+	return cs, nil
+}
+
+// serve hands the request to the server of app, with the given path.
+func serve(app *App, c *gin.Context, path string) {
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.Path = path
+	req.URL.RawPath = ""
+	app.Server.ServeHTTP(c.Writer, req)
+}
+
+// EnableAdmin serves the admin API, which lists, creates, settles and deletes
+// chains:
+//
+//	GET    /admin/chains             lists the chain ids
+//	POST   /admin/chains             creates the chain of the ChainSpec in the body
+//	POST   /admin/chains/:id/settle  settles a match chain into its parent, see Settle
+//	DELETE /admin/chains/:id         deletes a chain, closing its node
+//
+// It must be enabled before the server starts.
+func (cs *Chains) EnableAdmin() {
+	cs.Server.GET("/admin/chains", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"chains": cs.IDs()})
+	})
+
+	cs.Server.POST("/admin/chains", func(c *gin.Context) {
+		var spec ChainSpec
+		if err := c.BindJSON(&spec); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := cs.Create(spec); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrChainExists) || errors.Is(err, ErrSettling) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"chainId": spec.ChainID, "rpc": fmt.Sprintf("/chains/%d/rpc", spec.ChainID)})
+	})
+
+	cs.Server.POST("/admin/chains/:id/settle", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settlement, err := cs.Settle(id)
+		switch {
+		case errors.Is(err, ErrUnknownChain):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrNoSettlement), errors.Is(err, ErrSettling):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil && settlement == nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			// the settlement was submitted even if closing the chain failed
+			c.JSON(http.StatusOK, settlement)
+		}
+	})
+
+	cs.Server.DELETE("/admin/chains/:id", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		head, err := cs.Delete(id)
+		switch {
+		case errors.Is(err, ErrUnknownChain):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrDefaultChain):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrSettling):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusOK, gin.H{"chainId": id, "head": head.NumberU64()})
+		}
+	})
+}
+
+// Add hosts app under the chain id of its node.
+func (cs *Chains) Add(app *App) (uint64, error) {
+	id, err := chainID(app)
+	if err != nil {
+		return 0, err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if err := cs.canCreate(ChainSpec{ChainID: id}); err != nil {
+		return 0, err
+	}
+	cs.chains[id] = app
+	return id, nil
+}
+
+// Create creates a chain with the factory and hosts it. The chain is created
+// without holding the lock, so that the other chains are served meanwhile.
+func (cs *Chains) Create(spec ChainSpec) (*App, error) {
+	if spec.ChainID == 0 {
+		return nil, errors.New("a chain id is needed")
+	}
+	cs.mu.RLock()
+	err := cs.canCreate(spec)
+	cs.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	app, err := cs.factory(spec)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := chainID(app); err != nil || id != spec.ChainID {
+		app.Close()
+		return nil, fmt.Errorf("the factory created chain %d instead of %d", id, spec.ChainID)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	// the chain may have been created, or the parent deleted, meanwhile
+	if err := cs.canCreate(spec); err != nil {
+		app.Close()
+		return nil, err
+	}
+	cs.chains[spec.ChainID] = app
+	if spec.Settlement != nil {
+		cs.settlements[spec.ChainID] = spec.Settlement
+	}
+	return app, nil
+}
+
+// canCreate checks that the chain of spec can be hosted. cs.mu must be held.
+func (cs *Chains) canCreate(spec ChainSpec) error {
+	if _, ok := cs.chains[spec.ChainID]; ok {
+		return fmt.Errorf("%w: %d", ErrChainExists, spec.ChainID)
+	}
+	if cs.settling[spec.ChainID] {
+		return fmt.Errorf("%w: %d", ErrSettling, spec.ChainID)
+	}
+	if spec.Settlement != nil {
+		if _, ok := cs.chains[spec.Settlement.Parent]; !ok {
+			return fmt.Errorf("parent: %w: %d", ErrUnknownChain, spec.Settlement.Parent)
+		}
+	}
+	return nil
+}
+
+// Get returns the chain with the given id.
+func (cs *Chains) Get(id uint64) (*App, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	app, ok := cs.chains[id]
+	return app, ok
+}
+
+// chain returns the chain whose id is given in a route.
+func (cs *Chains) chain(param string) (*App, error) {
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChain, param)
+	}
+	app, ok := cs.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, id)
+	}
+	return app, nil
+}
+
+// IDs returns the ids of the hosted chains in increasing order.
+func (cs *Chains) IDs() []uint64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	ids := make([]uint64, 0, len(cs.chains))
+	for id := range cs.chains {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Delete stops hosting a chain and closes it. The requests it is serving
+// fail with cvm.ErrExecutorClosed.
+func (cs *Chains) Delete(id uint64) (*cvm.Block, error) {
+	if id == cs.def {
+		return nil, ErrDefaultChain
+	}
+	cs.mu.Lock()
+	if cs.settling[id] {
+		cs.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrSettling, id)
+	}
+	app, ok := cs.chains[id]
+	delete(cs.chains, id)
+	delete(cs.settlements, id)
+	cs.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, id)
+	}
+	return app.Close()
+}
+
+// Settle ends the match played on a chain created with a settlement spec. It
+// stops serving the chain, settles its final state, see cvm.NodeCtx.Settle,
+// and submits the settlement to the settlement contract of the parent chain.
+// The chain is then closed. If the parent rejects the settlement, the chain
+// is served again so that settling can be retried. Meanwhile its id stays
+// reserved: it can't be created, deleted or settled again.
+func (cs *Chains) Settle(id uint64) (*cvm.Settlement, error) {
+	cs.mu.Lock()
+	app, ok := cs.chains[id]
+	spec := cs.settlements[id]
+	switch {
+	case cs.settling[id]:
+		cs.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrSettling, id)
+	case !ok:
+		cs.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, id)
+	case spec == nil:
+		cs.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrNoSettlement, id)
+	}
+	parent, ok := cs.chains[spec.Parent]
+	if !ok {
+		cs.mu.Unlock()
+		return nil, fmt.Errorf("parent: %w: %d", ErrUnknownChain, spec.Parent)
+	}
+	delete(cs.chains, id)
+	cs.settling[id] = true
+	cs.mu.Unlock()
+
+	settlement, err := cs.settle(app, parent, spec)
+	cs.mu.Lock()
+	delete(cs.settling, id)
+	if err != nil {
+		// the id was reserved, but the chains may have been closed meanwhile
+		if _, ok := cs.chains[id]; !ok && !cs.closed {
+			cs.chains[id] = app
+			cs.mu.Unlock()
+			return nil, err
+		}
+		cs.mu.Unlock()
+		app.Close()
+		return nil, err
+	}
+	delete(cs.settlements, id)
+	cs.mu.Unlock()
+	_, err = app.Close()
+	return settlement, err
+}
+
+// settle settles the chain of app into parent.
+func (cs *Chains) settle(app, parent *App, spec *SettlementSpec) (*cvm.Settlement, error) {
+	var settlement *cvm.Settlement
+	err := app.Exec.Do(func(n *cvm.NodeCtx) (err error) {
+		settlement, err = n.Settle(spec.Storage)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := settlement.Transaction(spec.From, spec.Contract, spec.Gas)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := parent.Exec.ApplyTransaction(tx); err != nil {
+		return nil, fmt.Errorf("parent: %w", err)
+	}
+	return settlement, nil
+}
+
+// Close closes every chain, the default one included.
+func (cs *Chains) Close() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.closed = true
+	var errs []error
+	for id, app := range cs.chains {
+		if _, err := app.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("chain %d: %w", id, err))
+		}
+		delete(cs.chains, id)
+	}
+	return errors.Join(errs...)
+}
+
+// chainID returns the chain id of the node of app.
+func chainID(app *App) (uint64, error) {
+	var id uint64
+	err := app.Exec.Do(func(n *cvm.NodeCtx) error {
+		id = n.Evm.ChainConfig().ChainID.Uint64()
+		return nil
+	})
+	return id, err
+}
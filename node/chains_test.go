@@ -0,0 +1,72 @@
+package node
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	cvm "github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChain creates the server of an in-memory chain with the given id.
+func newChain(id uint64) (*App, error) {
+	genesis := cvm.DefaultGenesis()
+	config := *genesis.Config
+	config.ChainID = new(big.Int).SetUint64(id)
+	genesis.Config = &config
+	node, err := cvm.NewNode(cvm.NewMemoryDatabase(), genesis)
+	if err != nil {
+		return nil, err
+	}
+	return NewServerForNode(node), nil
+}
+
+func TestSettleReservesChainID(t *testing.T) {
+	def, err := newChain(params.TestChainConfig.ChainID.Uint64())
+	require.NoError(t, err)
+	cs, err := NewChains(def, func(spec ChainSpec) (*App, error) { return newChain(spec.ChainID) })
+	require.NoError(t, err)
+	defer cs.Close()
+
+	// the parent rejects the settlement, sent from the system address
+	spec := ChainSpec{ChainID: 42, Settlement: &SettlementSpec{Parent: cs.def, From: cvm.SystemAddress, Gas: 100000}}
+	match, err := cs.Create(spec)
+	require.NoError(t, err)
+
+	// hold the parent so that settling waits for it
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go def.Exec.Do(func(*cvm.NodeCtx) error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+	settled := make(chan error)
+	go func() {
+		_, err := cs.Settle(42)
+		settled <- err
+	}()
+	require.Eventually(t, func() bool {
+		_, ok := cs.Get(42)
+		return !ok
+	}, time.Second, time.Millisecond)
+
+	_, err = cs.Create(ChainSpec{ChainID: 42})
+	assert.ErrorIs(t, err, ErrSettling)
+	_, err = cs.Delete(42)
+	assert.ErrorIs(t, err, ErrSettling)
+	_, err = cs.Settle(42)
+	assert.ErrorIs(t, err, ErrSettling)
+
+	close(release)
+	assert.ErrorIs(t, <-settled, cvm.ErrSystemSender)
+	app, ok := cs.Get(42)
+	require.True(t, ok)
+	assert.Same(t, match, app, "the settled chain is served again")
+	_, err = cs.Settle(42)
+	assert.ErrorIs(t, err, cvm.ErrSystemSender, "and can settle again")
+}
@@ -0,0 +1,60 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+
+	cvm "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/debugger"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// launchArgs are the arguments of the launch request of a debug session: a
+// call, a transaction, or the replay of a transaction the node executed.
+type launchArgs struct {
+	Tx     string      `json:"tx"`     // raw transaction, like the parameter of eth_call
+	Send   bool        `json:"send"`   // execute tx as part of the block being built instead of calling it
+	TxHash common.Hash `json:"txHash"` // transaction to replay instead of tx
+}
+
+// debugTarget runs the executions of the debug sessions of an app.
+type debugTarget struct {
+	app *App
+}
+
+// DebugTarget returns the target of a debugger.Server debugging the app's
+// node. A call runs on a copy of the state and doesn't hold the node, while a
+// sent or replayed transaction holds it until it ends, so the node executes
+// nothing else while it is paused.
+func (app *App) DebugTarget() debugger.Target {
+	return debugTarget{app: app}
+}
+
+func (t debugTarget) Run(data json.RawMessage, tracer vm.EVMLogger) ([]byte, error) {
+	var args launchArgs
+	if err := json.Unmarshal(data, &args); err != nil {
+		return nil, err
+	}
+	exec := t.app.Exec
+	switch {
+	case args.TxHash != (common.Hash{}):
+		return nil, exec.Do(func(n *cvm.NodeCtx) error { return n.TraceTransaction(args.TxHash, tracer) })
+	case args.Tx == "":
+		return nil, errors.New("launch: tx or txHash is required")
+	case args.Send:
+		output, _, err := exec.ApplyTransactionWithTracer(RawTxToTxObject(args.Tx), tracer)
+		return output, err
+	default:
+		output, _, err := exec.CallWithTracer(RawTxToTxObject(args.Tx), tracer)
+		return output, err
+	}
+}
+
+func (t debugTarget) Code(addr common.Address) ([]byte, error) {
+	statedb, err := t.app.Exec.State()
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(addr), nil
+}
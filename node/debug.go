@@ -0,0 +1,207 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	cvm "github.com/daweth/gevm/core"
+	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/tracers"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// rpcResponse is a standard JSON-RPC response, returned by the methods whose
+// results tools such as debuggers read in the format of geth.
+type rpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// txTraceResult is the trace of one transaction of a traced block.
+type txTraceResult struct {
+	TxHash common.Hash     `json:"txHash"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// handleDebug serves the tracing methods of geth's debug namespace:
+//
+//	debug_traceTransaction    [txHash, options]
+//	debug_traceCall           [rawTx, block, options]
+//	debug_traceBlockByNumber  [number, options]
+//	debug_traceBlockByHash    [hash, options]
+//
+// debug_traceBlock takes either a number or a hash. The options are the ones
+// of tracers.Config. ok is false if the method is not one of them.
+func (app *App) handleDebug(r gt.Request) (resp rpcResponse, ok bool) {
+	var (
+		result json.RawMessage
+		err    error
+	)
+	switch r.Method {
+	case "debug_traceTransaction":
+		result, err = app.traceTransaction(r.Params)
+	case "debug_traceCall":
+		result, err = app.traceCall(r.Params)
+	case "debug_traceBlock", "debug_traceBlockByNumber", "debug_traceBlockByHash":
+		result, err = app.traceBlock(r.Params)
+	default:
+		return rpcResponse{}, false
+	}
+
+	resp = rpcResponse{JsonRpc: "2.0", Id: r.Id, Result: result}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+	}
+	return resp, true
+}
+
+func (app *App) traceTransaction(params []interface{}) (json.RawMessage, error) {
+	hash, err := paramHash(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	tracer, cfg, err := newTracer(params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.Exec.TraceTransaction(hash, tracer); err != nil {
+		return nil, err
+	}
+	result, err := tracer.GetResult()
+	return app.decodeTrace(cfg, result), err
+}
+
+func (app *App) traceCall(params []interface{}) (json.RawMessage, error) {
+	raw, err := param(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("parameter 0: invalid transaction %v", raw)
+	}
+	tx := RawTxToTxObject(s)
+	tracer, cfg, err := newTracer(params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if tag, _ := param(params, 1); tag == nil || tag == "pending" {
+		// the block being built, like eth_call. A failed call is part of
+		// the trace.
+		if _, _, err := app.Exec.CallWithTracer(tx, tracer); errors.Is(err, cvm.ErrExecutorClosed) {
+			return nil, err
+		}
+	} else {
+		err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+			block, err := blockParam(n, params, 1)
+			if err != nil {
+				return err
+			}
+			return n.TraceCall(block, tx, tracer)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	result, err := tracer.GetResult()
+	return app.decodeTrace(cfg, result), err
+}
+
+func (app *App) traceBlock(params []interface{}) (json.RawMessage, error) {
+	cfg, err := paramTraceConfig(params, 1)
+	if err != nil {
+		return nil, err
+	}
+	var results []txTraceResult
+	err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+		block, err := blockParam(n, params, 0)
+		if err != nil {
+			return err
+		}
+		traced := make([]tracers.Tracer, len(block.Transactions))
+		for i := range traced {
+			if traced[i], err = tracers.New(cfg); err != nil {
+				return err
+			}
+		}
+		if err := n.TraceBlock(block, func(i int) vm.EVMLogger { return traced[i] }); err != nil {
+			return err
+		}
+		results = make([]txTraceResult, len(traced))
+		for i, tracer := range traced {
+			results[i].TxHash = block.TxHashes[i]
+			if results[i].Result, err = tracer.GetResult(); err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Result = app.decodeTrace(cfg, results[i].Result)
+	}
+	if results == nil {
+		results = []txTraceResult{}
+	}
+	return json.Marshal(results)
+}
+
+// newTracer creates the tracer selected by the options parameter at index i.
+func newTracer(params []interface{}, i int) (tracers.Tracer, *tracers.Config, error) {
+	cfg, err := paramTraceConfig(params, i)
+	if err != nil {
+		return nil, nil, err
+	}
+	tracer, err := tracers.New(cfg)
+	return tracer, cfg, err
+}
+
+// blockParam returns the sealed block given by number, hash or tag.
+func blockParam(n *cvm.NodeCtx, params []interface{}, i int) (*cvm.Block, error) {
+	p, err := param(params, i)
+	if err != nil {
+		return nil, err
+	}
+	var block *cvm.Block
+	switch s, _ := p.(string); {
+	case s == "latest":
+		block = n.Head()
+	case len(s) == 66:
+		block = n.BlockByHash(common.HexToHash(s))
+	default:
+		number, err := paramUint64(params, i)
+		if err != nil {
+			return nil, err
+		}
+		block = n.BlockByNumber(number)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("parameter %d: block %v not found", i, p)
+	}
+	return block, nil
+}
+
+// paramHash reads a 32 byte hash.
+func paramHash(params []interface{}, i int) (common.Hash, error) {
+	p, err := param(params, i)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if s, ok := p.(string); ok && len(s) == 66 {
+		return common.HexToHash(s), nil
+	}
+	return common.Hash{}, fmt.Errorf("parameter %d: invalid hash %v", i, p)
+}
@@ -0,0 +1,207 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	cvm "github.com/daweth/gevm/core"
+	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// handleDev serves the test methods of Hardhat and Anvil. The account setters
+// are served under both the hardhat_ and the anvil_ prefix. ok is false if the
+// method is not one of them.
+func (app *App) handleDev(r gt.Request) (resp gt.Response, ok bool) {
+	method := r.Method
+	for _, prefix := range []string{"hardhat_", "anvil_"} {
+		if strings.HasPrefix(method, prefix) {
+			method = "dev_" + strings.TrimPrefix(method, prefix)
+		}
+	}
+
+	var (
+		result []byte
+		err    error
+	)
+	switch method {
+	case "evm_snapshot":
+		err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+			result = Uint64ToBytes(n.Snapshot())
+			return nil
+		})
+	case "evm_revert":
+		// like Hardhat, reverting to an unknown snapshot returns false
+		var id uint64
+		if id, err = paramUint64(r.Params, 0); err == nil {
+			err = app.Exec.Do(func(n *cvm.NodeCtx) error { return n.RevertToSnapshot(id) })
+			result = BoolToBytes(err == nil)
+			if errors.Is(err, cvm.ErrUnknownSnapshot) {
+				err = nil
+			}
+		}
+	case "evm_increaseTime":
+		var seconds uint64
+		if seconds, err = paramUint64(r.Params, 0); err == nil {
+			err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+				result = Uint64ToBytes(n.IncreaseTime(seconds))
+				return nil
+			})
+		}
+	case "evm_setNextBlockTimestamp":
+		var timestamp uint64
+		if timestamp, err = paramUint64(r.Params, 0); err == nil {
+			err = app.Exec.Do(func(n *cvm.NodeCtx) error { return n.SetNextBlockTimestamp(timestamp) })
+		}
+	case "evm_mine":
+		// the timestamp of the block is optional
+		var timestamp uint64
+		if len(r.Params) > 0 {
+			timestamp, err = paramUint64(r.Params, 0)
+		}
+		if err == nil {
+			err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+				if timestamp != 0 {
+					if err := n.SetNextBlockTimestamp(timestamp); err != nil {
+						return err
+					}
+				}
+				block, err := n.SealBlock()
+				if err == nil {
+					result = Uint64ToBytes(block.NumberU64())
+				}
+				return err
+			})
+		}
+	case "dev_setBalance":
+		var (
+			addr    common.Address
+			balance *big.Int
+		)
+		if addr, err = paramAddress(r.Params, 0); err == nil {
+			if balance, err = paramBig(r.Params, 1); err == nil {
+				err = app.Exec.Do(func(n *cvm.NodeCtx) error { n.SetBalance(addr, balance); return nil })
+			}
+		}
+	case "dev_setCode":
+		var (
+			addr common.Address
+			code []byte
+		)
+		if addr, err = paramAddress(r.Params, 0); err == nil {
+			if code, err = paramBytes(r.Params, 1); err == nil {
+				err = app.Exec.Do(func(n *cvm.NodeCtx) error { n.SetCode(addr, code); return nil })
+			}
+		}
+	case "dev_setStorageAt":
+		var (
+			addr        common.Address
+			slot, value *big.Int
+		)
+		if addr, err = paramAddress(r.Params, 0); err == nil {
+			if slot, err = paramBig(r.Params, 1); err == nil {
+				if value, err = paramBig(r.Params, 2); err == nil {
+					err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+						n.SetStorageAt(addr, common.BigToHash(slot), common.BigToHash(value))
+						return nil
+					})
+				}
+			}
+		}
+	case "dev_setNonce":
+		var (
+			addr  common.Address
+			nonce uint64
+		)
+		if addr, err = paramAddress(r.Params, 0); err == nil {
+			if nonce, err = paramUint64(r.Params, 1); err == nil {
+				err = app.Exec.Do(func(n *cvm.NodeCtx) error { n.SetNonce(addr, nonce); return nil })
+			}
+		}
+	case "dev_impersonateAccount", "dev_stopImpersonatingAccount":
+		// gevm transactions are not signed, so the node already accepts
+		// transactions from any sender. The methods are accepted so that
+		// test tooling calling them works unchanged.
+		_, err = paramAddress(r.Params, 0)
+	default:
+		return gt.Response{}, false
+	}
+
+	return gt.Response{
+		JsonRpc: "2.0",
+		Id:      r.Id,
+		Error:   errorBytes(err),
+		Result:  result,
+	}, true
+}
+
+// param returns the i-th parameter of a request.
+func param(params []interface{}, i int) (interface{}, error) {
+	if i >= len(params) {
+		return nil, fmt.Errorf("missing parameter %d", i)
+	}
+	return params[i], nil
+}
+
+// paramBig reads a quantity, given either as a hex string or a JSON number.
+func paramBig(params []interface{}, i int) (*big.Int, error) {
+	p, err := param(params, i)
+	if err != nil {
+		return nil, err
+	}
+	switch v := p.(type) {
+	case string:
+		// storage slots and values are often given zero padded
+		b, err := hexutil.Decode(v)
+		if err != nil {
+			return hexutil.DecodeBig(v)
+		}
+		return new(big.Int).SetBytes(b), nil
+	case float64:
+		if v < 0 || v != float64(uint64(v)) {
+			return nil, fmt.Errorf("parameter %d: invalid quantity %v", i, v)
+		}
+		return new(big.Int).SetUint64(uint64(v)), nil
+	}
+	return nil, fmt.Errorf("parameter %d: invalid quantity %v", i, p)
+}
+
+// paramUint64 reads a quantity that fits in 64 bits.
+func paramUint64(params []interface{}, i int) (uint64, error) {
+	v, err := paramBig(params, i)
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsUint64() {
+		return 0, fmt.Errorf("parameter %d: %v overflows 64 bits", i, v)
+	}
+	return v.Uint64(), nil
+}
+
+// paramAddress reads a hex encoded address.
+func paramAddress(params []interface{}, i int) (common.Address, error) {
+	p, err := param(params, i)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if s, ok := p.(string); ok && common.IsHexAddress(s) {
+		return common.HexToAddress(s), nil
+	}
+	return common.Address{}, fmt.Errorf("parameter %d: invalid address %v", i, p)
+}
+
+// paramBytes reads hex encoded bytes.
+func paramBytes(params []interface{}, i int) ([]byte, error) {
+	p, err := param(params, i)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := p.(string)
+	if !ok {
+		return nil, fmt.Errorf("parameter %d: invalid bytes %v", i, p)
+	}
+	return hexutil.Decode(s)
+}
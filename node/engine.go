@@ -0,0 +1,198 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	kserver "github.com/curio-research/keystone/server"
+	"github.com/curio-research/keystone/state"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// streamInterval is how often the updates of an engine are pushed to its
+// websocket clients, as in Keystone.
+const streamInterval = 100 * time.Millisecond
+
+// Engine runs the Keystone game engine of a chain: its game tick, the stream
+// of its updates to websocket clients and its HTTP server. It does what
+// EngineCtx.Start does, but EngineCtx.Start serves until the process exits,
+// while an Engine stops, freeing its ports, when its chain is closed.
+type Engine struct {
+	Ctx *kserver.EngineCtx
+
+	servers []*http.Server
+	quit    chan struct{}
+	wg      sync.WaitGroup // the tick and stream loops
+}
+
+// StartEngine starts the engine ctx, configured as for EngineCtx.Start. It
+// fails if a port of the engine is taken.
+func StartEngine(ctx *kserver.EngineCtx) (*Engine, error) {
+	ports := []int{ctx.HttpPort}
+	if ctx.Stream.Port != ctx.HttpPort {
+		ports = append(ports, ctx.Stream.Port)
+	}
+	listeners := make([]net.Listener, 0, len(ports))
+	for _, port := range ports {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("keystone: %w", err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	e := &Engine{Ctx: ctx, quit: make(chan struct{})}
+	e.streamRoutes()
+	ctx.SetGameLiveliness(true)
+	for _, l := range listeners {
+		srv := &http.Server{Handler: ctx.GinHttpEngine}
+		e.servers = append(e.servers, srv)
+		go srv.Serve(l)
+	}
+	e.every(time.Duration(ctx.GameTick.TickRateMs)*time.Millisecond, e.tick)
+	e.every(streamInterval, e.publish)
+	return e, nil
+}
+
+// Stop stops the ticks of the engine and closes its servers and websocket
+// connections. A tick in progress is waited for.
+func (e *Engine) Stop() error {
+	close(e.quit)
+	e.wg.Wait()
+	e.Ctx.SetGameLiveliness(false)
+
+	var errs []error
+	for _, srv := range e.servers {
+		errs = append(errs, srv.Close())
+	}
+	// the servers don't track the hijacked websocket connections
+	s := e.Ctx.Stream
+	s.ConnsMutex.Lock()
+	for conn := range s.Conns {
+		conn.Close()
+	}
+	s.ConnsMutex.Unlock()
+	return errors.Join(errs...)
+}
+
+// every calls f every interval until the engine stops.
+func (e *Engine) every(interval time.Duration, f func()) {
+	ticker := time.NewTicker(interval)
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+}
+
+// tick runs the systems due at the current tick, like GameTick.Start.
+func (e *Engine) tick() {
+	ctx, g := e.Ctx, e.Ctx.GameTick
+	if !ctx.IsLive {
+		return
+	}
+	ctx.AddTransactionsToSave()
+	for _, system := range g.Schedule.ScheduledTickSystems {
+		if kserver.ShouldTriggerTick(g.TickNumber, g.TickRateMs, system.TickInterval) {
+			system.TickFunction(ctx)
+		}
+	}
+	ctx.AddStateUpdatesToSave()
+	kserver.DeleteAllTicksAtTickNumber(ctx.World, g.TickNumber)
+	g.TickNumber++
+}
+
+// streamRoutes serves the websockets of the stream: / for the requests and
+// events of players, /subscribeAllTableUpdates for the table updates.
+func (e *Engine) streamRoutes() {
+	ctx, s := e.Ctx, e.Ctx.Stream
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     func(*http.Request) bool { return true },
+	}
+	serve := func(subscribe bool) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+			if err != nil {
+				return // the upgrader replied with the error
+			}
+			s.AddConnection(conn, subscribe)
+			defer s.RemoveConnection(conn)
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if !subscribe && s.SocketRequestRouter != nil {
+					s.SocketRequestRouter(ctx, kserver.NewMessageFromBuffer(msg), conn)
+				}
+			}
+		}
+	}
+	ctx.GinHttpEngine.GET("/", serve(false))
+	ctx.GinHttpEngine.GET("/subscribeAllTableUpdates", serve(true))
+}
+
+// publish pushes the queued table updates to the clients subscribed to them,
+// and the queued events to every client or to their players, like
+// StreamServer.PublishMessage.
+func (e *Engine) publish() {
+	s := e.Ctx.Stream
+	events := s.FetchEventsFromQueue()
+	s.ClearClientMessageQueue()
+	updates := s.FetchTableUpdatesFromQueue()
+	s.ClearTableUpdatesQueue()
+
+	s.ConnsMutex.Lock()
+	conns := make(map[*websocket.Conn]kserver.ConnectionType, len(s.Conns))
+	for conn, typ := range s.Conns {
+		conns[conn] = typ
+	}
+	s.ConnsMutex.Unlock()
+
+	data, _ := state.EncodeTableUpdateArrayToBytes(updates)
+	for conn, typ := range conns {
+		if typ.SubscribeAllStateUpdates {
+			conn.WriteMessage(websocket.TextMessage, data)
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	s.ProtoBufPacketsMutex.Lock()
+	defer s.ProtoBufPacketsMutex.Unlock()
+	for _, event := range events {
+		buffer := event.NetworkMessage.ParseToBuffer()
+		if event.PlayerIds == nil {
+			for conn := range conns {
+				conn.WriteMessage(websocket.BinaryMessage, buffer)
+			}
+			continue
+		}
+		s.PlayerIdToConnectionMutex.Lock()
+		for _, id := range event.PlayerIds {
+			if conn := s.PlayerIdToConnection[id]; conn != nil {
+				conn.WriteMessage(websocket.BinaryMessage, buffer)
+			}
+		}
+		s.PlayerIdToConnectionMutex.Unlock()
+	}
+}
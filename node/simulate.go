@@ -0,0 +1,199 @@
+package node
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	cvm "github.com/daweth/gevm/core"
+	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionTTL is how long a simulation session is kept without being used.
+const sessionTTL = 10 * time.Minute
+
+// sweepInterval is how often the expired sessions are discarded.
+const sweepInterval = time.Minute
+
+// sessions are the live simulation sessions of a server.
+type sessions struct {
+	mu   sync.Mutex
+	byID map[string]*session
+
+	quit chan struct{} // stops the sweep, see sweepEvery
+	done chan struct{} // closed when the sweep stopped
+}
+
+func newSessions() *sessions {
+	return &sessions{byID: make(map[string]*session)}
+}
+
+type session struct {
+	*cvm.Session
+	used time.Time
+}
+
+// simStep is a step of a simulation request.
+type simStep struct {
+	From  string        `json:"from"`
+	To    string        `json:"to"` // empty to create a contract
+	Gas   uint64        `json:"gas"`
+	Value uint64        `json:"value"`
+	Data  hexutil.Bytes `json:"data"`
+	Call  bool          `json:"call"` // discard the changes of the step
+}
+
+// simRoutes serves the simulation API:
+//
+//	POST   /sim      starts a session on a copy of the block being built
+//	POST   /sim/:id  runs {"steps": [...]} in the session
+//	DELETE /sim/:id  discards the session
+//
+// Sessions unused for sessionTTL are discarded.
+func (app *App) simRoutes() {
+	app.Server.POST("/sim", func(c *gin.Context) {
+		s, err := app.Exec.Simulate()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": app.sessions.add(s)})
+	})
+
+	app.Server.POST("/sim/:id", func(c *gin.Context) {
+		var req struct {
+			Steps []simStep `json:"steps"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s, ok := app.sessions.get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown session"})
+			return
+		}
+		steps := make([]cvm.SimStep, len(req.Steps))
+		for i, step := range req.Steps {
+			steps[i] = cvm.SimStep{
+				Tx: gt.Transaction{
+					From:  step.From,
+					To:    step.To,
+					Gas:   step.Gas,
+					Value: step.Value,
+					Data:  string(step.Data),
+				},
+				Call: step.Call,
+			}
+		}
+		results, err := s.Run(steps...)
+		if err != nil {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": app.decodeSimResults(s, steps, results)})
+	})
+
+	app.Server.DELETE("/sim/:id", func(c *gin.Context) {
+		if !app.sessions.remove(c.Param("id")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "Success"})
+	})
+}
+
+// add keeps s and returns its id.
+func (ss *sessions) add(s *cvm.Session) string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	id := newSessionID()
+	ss.byID[id] = &session{Session: s, used: time.Now()}
+	return id
+}
+
+// get returns the session with the given id, discarding it if it expired.
+func (ss *sessions) get(id string) (*cvm.Session, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s, ok := ss.byID[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(s.used) > sessionTTL {
+		s.Close()
+		delete(ss.byID, id)
+		return nil, false
+	}
+	s.used = time.Now()
+	return s.Session, true
+}
+
+// sweep discards the sessions unused for sessionTTL.
+func (ss *sessions) sweep() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	now := time.Now()
+	for id, s := range ss.byID {
+		if now.Sub(s.used) > sessionTTL {
+			s.Close()
+			delete(ss.byID, id)
+		}
+	}
+}
+
+// sweepEvery sweeps the sessions every interval until close.
+func (ss *sessions) sweepEvery(interval time.Duration) {
+	ss.quit, ss.done = make(chan struct{}), make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(ss.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ss.sweep()
+			case <-ss.quit:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the sweep and discards every session.
+func (ss *sessions) close() {
+	if ss.quit != nil {
+		close(ss.quit)
+		<-ss.done
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for id, s := range ss.byID {
+		s.Close()
+		delete(ss.byID, id)
+	}
+}
+
+// remove discards the session with the given id.
+func (ss *sessions) remove(id string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	s, ok := ss.byID[id]
+	if ok {
+		s.Close()
+		delete(ss.byID, id)
+	}
+	return ok
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,42 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	cvm "github.com/daweth/gevm/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionsExpire(t *testing.T) {
+	ss := newSessions()
+	expire := func(id string) {
+		ss.mu.Lock()
+		ss.byID[id].used = time.Now().Add(-sessionTTL - time.Second)
+		ss.mu.Unlock()
+	}
+
+	id := ss.add(&cvm.Session{})
+	_, ok := ss.get(id)
+	assert.True(t, ok)
+	expire(id)
+	_, ok = ss.get(id)
+	assert.False(t, ok)
+	assert.NotContains(t, ss.byID, id, "an expired session is discarded when looked up")
+
+	// the sweep discards the sessions nobody looks up
+	ss.sweepEvery(time.Millisecond)
+	kept, expired := ss.add(&cvm.Session{}), ss.add(&cvm.Session{})
+	expire(expired)
+	assert.Eventually(t, func() bool {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		_, ok := ss.byID[expired]
+		return !ok
+	}, time.Second, time.Millisecond)
+	_, ok = ss.get(kept)
+	assert.True(t, ok)
+
+	ss.close()
+	assert.Empty(t, ss.byID)
+}
@@ -0,0 +1,199 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/daweth/gevm/console"
+	cvm "github.com/daweth/gevm/core"
+	"github.com/daweth/gevm/decoder"
+	gt "github.com/daweth/gevm/gevmtypes"
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/tracers"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// traced runs a request with the tracer selected by the optional parameter at
+// index i, in the format of tracers.Config, and returns the result of the
+// tracer, decoded with the ABIs of the app, see decodeTrace. run is given a
+// nil tracer if the request is not traced, so that untraced requests pay
+// nothing for tracing.
+func (app *App) traced(params []interface{}, i int, run func(tracer vm.EVMLogger) ([]byte, uint64, error)) ([]byte, uint64, json.RawMessage, error) {
+	cfg, err := paramTraceConfig(params, i)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if cfg == nil {
+		o, g, err := run(nil)
+		return o, g, nil, err
+	}
+	tracer, err := tracers.New(cfg)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	o, g, err := run(tracer)
+	trace, terr := tracer.GetResult()
+	if err == nil {
+		err = terr
+	}
+	return o, g, app.decodeTrace(cfg, trace), err
+}
+
+// paramTraceConfig reads optional tracer options, nil if they are absent.
+func paramTraceConfig(params []interface{}, i int) (*tracers.Config, error) {
+	if i >= len(params) || params[i] == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(params[i])
+	if err != nil {
+		return nil, err
+	}
+	var cfg tracers.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parameter %d: invalid tracer options: %w", i, err)
+	}
+	return &cfg, nil
+}
+
+// UseArtifacts makes the app map the failures of the executions of requests to
+// the Solidity sources of the contracts of registry, see withDebug.
+func (app *App) UseArtifacts(registry *sourcemap.Registry) {
+	app.artifacts = registry
+}
+
+// EnableConsole makes the app collect the messages contracts print with
+// Hardhat's console.log, see withDebug.
+func (app *App) EnableConsole() {
+	app.console = true
+}
+
+// debugData is the debug output of an execution.
+type debugData struct {
+	Console    []string             `json:"console,omitempty"`
+	StackTrace sourcemap.StackTrace `json:"stackTrace,omitempty"`
+	Call       *decoder.Decoded     `json:"call,omitempty"`
+	Output     *decoder.Decoded     `json:"output,omitempty"`
+	Revert     *decoder.Revert      `json:"revert,omitempty"`
+}
+
+// execution is the execution of the transaction or call of a request, see
+// withDebug.
+type execution struct {
+	tx gt.Transaction
+	// run executes tx with tracer attached
+	run func(tracer vm.EVMLogger) ([]byte, uint64, error)
+	// retrace executes tx again with tracer attached, once run failed
+	retrace func(tracer vm.EVMLogger)
+	// codeHash is the hash of the code of tx.To, read by run where tx ran, so
+	// that its ABI is found without reading the state again
+	codeHash common.Hash
+}
+
+// callExecution is the execution of the call tx on the block being built. It
+// is retraced by calling it again, on the block being built by then.
+func (app *App) callExecution(tx gt.Transaction) *execution {
+	e := &execution{tx: tx}
+	e.run = func(tracer vm.EVMLogger) ([]byte, uint64, error) {
+		return app.Exec.CallAndRead(tx, tracer, func(statedb *gstate.StateDB) {
+			e.codeHash = codeHash(statedb, tx)
+		})
+	}
+	e.retrace = func(tracer vm.EVMLogger) {
+		app.Exec.CallWithTracer(tx, tracer)
+	}
+	return e
+}
+
+// sendExecution is the execution of the transaction tx as part of the block
+// being built. It is retraced by replaying it, see cvm.NodeCtx.TraceTransaction.
+func (app *App) sendExecution(tx gt.Transaction) *execution {
+	var (
+		e    = &execution{tx: tx}
+		hash common.Hash
+	)
+	e.run = func(tracer vm.EVMLogger) (o []byte, g uint64, err error) {
+		err = app.Exec.Do(func(n *cvm.NodeCtx) error {
+			var vmerr error
+			before := len(n.PendingTxHashes())
+			o, g, vmerr = n.ApplyTransactionWithTracer(tx, tracer)
+			// refused transactions are not part of the block
+			if hashes := n.PendingTxHashes(); len(hashes) > before {
+				hash = hashes[len(hashes)-1]
+			}
+			e.codeHash = codeHash(n.StateDB, tx)
+			return vmerr
+		})
+		return o, g, err
+	}
+	e.retrace = func(tracer vm.EVMLogger) {
+		app.Exec.TraceTransaction(hash, tracer)
+	}
+	return e
+}
+
+// codeHash returns the hash of the code of the contract tx calls in statedb,
+// the zero hash for a contract creation.
+func codeHash(statedb *gstate.StateDB, tx gt.Transaction) common.Hash {
+	if tx.To == "" {
+		return common.Hash{}
+	}
+	return statedb.GetCodeHash(common.HexToAddress(tx.To))
+}
+
+// withDebug returns the run func of e, wrapped so that the debug output of the
+// execution is logged and stored in data: the messages printed with
+// console.log if the console is enabled, the source-level stack trace of its
+// failure if the app has artifacts, and its calldata, return data and revert
+// data decoded if the app has ABIs. Executions are only followed by the
+// console logger if it is on, and only failed ones are retraced for their
+// stack trace.
+func (app *App) withDebug(data *json.RawMessage, e *execution) func(tracer vm.EVMLogger) ([]byte, uint64, error) {
+	if app.artifacts == nil && !app.console && app.abis.Len() == 0 {
+		return e.run
+	}
+	tx := e.tx
+	return func(tracer vm.EVMLogger) ([]byte, uint64, error) {
+		var cl *console.Logger
+		if app.console {
+			cl = console.NewLogger()
+			tracer = tracers.Multiplex(tracer, cl)
+		}
+		o, g, err := e.run(tracer)
+
+		var out debugData
+		if cl != nil {
+			out.Console = cl.Messages()
+			for _, msg := range out.Console {
+				log.Info("console.log", "msg", msg)
+			}
+		}
+		if app.artifacts != nil && err != nil {
+			st := sourcemap.NewStackTracer(app.artifacts)
+			e.retrace(st)
+			if trace := st.StackTrace(); trace != nil {
+				log.Warn("Execution failed", "err", err, "trace", trace.String())
+				out.StackTrace = trace
+			}
+		}
+		if tx.To != "" && app.abis.Len() > 0 {
+			c := app.abis.Lookup(common.HexToAddress(tx.To), e.codeHash)
+			out.Call = app.decodeCall(c, []byte(tx.Data))
+			if err == nil {
+				if d, _ := app.abis.DecodeOutput(c, []byte(tx.Data), o); d != nil && len(d.Args) > 0 {
+					out.Output = d
+				}
+			}
+			if out.Revert = app.decodeRevert(c, o, errors.Is(err, vm.ErrExecutionReverted)); out.Revert != nil {
+				log.Warn("Execution reverted", "reason", out.Revert.Reason)
+			}
+		}
+		if out.Console != nil || out.StackTrace != nil || out.Call != nil || out.Output != nil || out.Revert != nil {
+			*data, _ = json.Marshal(out)
+		}
+		return o, g, err
+	}
+}
@@ -17,6 +17,14 @@ func Uint64ToBytes(num uint64) []byte {
 	return bytes
 }
 
+// BoolToBytes converts a bool to a single byte, 1 for true
+func BoolToBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
 // BytesToUint64 converts a slice of 8 bytes to a uint64
 func BytesToUint64(bytes []byte) uint64 {
 	if len(bytes) < 8 {
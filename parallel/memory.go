@@ -0,0 +1,145 @@
+package parallel
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+)
+
+// account is the state of an account as seen by a transaction.
+type account struct {
+	exists   bool
+	balance  *big.Int
+	nonce    uint64
+	code     []byte
+	codeHash common.Hash
+}
+
+func (a *account) equal(b *account) bool {
+	return a.exists == b.exists && a.balance.Cmp(b.balance) == 0 && a.nonce == b.nonce && a.codeHash == b.codeHash
+}
+
+// readAccount reads an account from a StateDB.
+func readAccount(statedb *gstate.StateDB, addr common.Address) account {
+	return account{
+		exists:   statedb.Exist(addr),
+		balance:  new(big.Int).Set(statedb.GetBalance(addr)),
+		nonce:    statedb.GetNonce(addr),
+		code:     statedb.GetCode(addr),
+		codeHash: statedb.GetCodeHash(addr),
+	}
+}
+
+// write is what a transaction did to an account: the account as it left it,
+// and the storage slots it wrote. If reset is set, the storage was cleared
+// first, because the account was created or destroyed.
+type write struct {
+	account account
+	reset   bool
+	storage map[common.Hash]common.Hash
+}
+
+// version is the write of one transaction to an account.
+type version struct {
+	tx    int
+	write *write
+}
+
+// memory holds the writes of the latest execution of every transaction, so
+// that a transaction reads what the transactions before it wrote.
+type memory struct {
+	mu       sync.RWMutex
+	accounts map[common.Address][]version // sorted by transaction
+	written  map[int][]common.Address     // accounts written by each transaction
+}
+
+func newMemory() *memory {
+	return &memory{
+		accounts: make(map[common.Address][]version),
+		written:  make(map[int][]common.Address),
+	}
+}
+
+// record replaces the writes of the previous execution of tx.
+func (m *memory) record(tx int, writes map[common.Address]*write) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, addr := range m.written[tx] {
+		versions := m.accounts[addr]
+		if i, ok := find(versions, tx); ok {
+			m.accounts[addr] = append(versions[:i], versions[i+1:]...)
+		}
+	}
+	addrs := make([]common.Address, 0, len(writes))
+	for addr, w := range writes {
+		versions := m.accounts[addr]
+		i, _ := find(versions, tx)
+		versions = append(versions, version{})
+		copy(versions[i+1:], versions[i:])
+		versions[i] = version{tx: tx, write: w}
+		m.accounts[addr] = versions
+		addrs = append(addrs, addr)
+	}
+	// the order of the written accounts doesn't depend on map iteration, so
+	// applying the writes is deterministic
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	m.written[tx] = addrs
+}
+
+// find returns the position of tx in versions, or where it would be inserted.
+func find(versions []version, tx int) (int, bool) {
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].tx >= tx })
+	return i, i < len(versions) && versions[i].tx == tx
+}
+
+// account returns the account as the transactions before tx left it. ok is
+// false if none of them wrote it.
+func (m *memory) account(addr common.Address, tx int) (a account, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.accounts[addr]
+	if i, _ := find(versions, tx); i > 0 {
+		return versions[i-1].write.account, true
+	}
+	return account{}, false
+}
+
+// storage returns a storage slot as the transactions before tx left it. ok is
+// false if none of them wrote or cleared it.
+func (m *memory) storage(addr common.Address, slot common.Hash, tx int) (value common.Hash, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.accounts[addr]
+	i, _ := find(versions, tx)
+	for i--; i >= 0; i-- {
+		w := versions[i].write
+		if v, ok := w.storage[slot]; ok {
+			return v, true
+		}
+		if w.reset {
+			return common.Hash{}, true
+		}
+	}
+	return common.Hash{}, false
+}
+
+// writes returns the accounts written by tx and what was written to them.
+func (m *memory) writes(tx int) ([]common.Address, []*write) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	addrs := m.written[tx]
+	writes := make([]*write, len(addrs))
+	for i, addr := range addrs {
+		j, _ := find(m.accounts[addr], tx)
+		writes[i] = m.accounts[addr][j].write
+	}
+	return addrs, writes
+}
@@ -0,0 +1,172 @@
+// Package parallel executes a batch of transactions on all cores, with the
+// optimistic concurrency of Block-STM.
+//
+// Transactions run speculatively and concurrently, each on a state that
+// records what it read and what it wrote. A transaction reads the writes of
+// the latest execution of the transactions before it, falling back to the
+// state the batch started from. Executions are then validated in order: a
+// transaction whose reads no longer match what the transactions before it
+// wrote is executed again. Once every transaction has been validated against
+// the final writes of the ones before it, applying the writes in order gives
+// exactly the state of sequential execution.
+package parallel
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Task executes transaction i of a batch on statedb. It is called again, on a
+// fresh state, every time the transaction has to be executed again, so any
+// result it keeps must be stored per transaction and overwritten. Executions
+// of different transactions run concurrently, but those of the same
+// transaction never do.
+type Task func(i int, statedb vm.StateDB)
+
+// Result holds the outcome of a batch.
+type Result struct {
+	Executions int // executions of transactions, including re-executions
+
+	mem  *memory
+	logs [][]*types.Log
+}
+
+// Execute runs n transactions on top of base using the given number of
+// workers, or one per core if workers is zero. base is only read, through
+// copies, and must not be used until Execute returns.
+func Execute(base *gstate.StateDB, n int, workers int, task Task) *Result {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	res := &Result{mem: newMemory(), logs: make([][]*types.Log, n)}
+	if n == 0 {
+		return res
+	}
+
+	bases := make([]*gstate.StateDB, workers)
+	for i := range bases {
+		bases[i] = base.Copy()
+	}
+	validator := base.Copy()
+
+	txReads := make([]reads, n)
+	pending := make([]int, n)
+	for i := range pending {
+		pending[i] = i
+	}
+	for committed := 0; committed < n; {
+		// execute the pending transactions
+		var (
+			wg   sync.WaitGroup
+			next = make(chan int)
+		)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(base *gstate.StateDB) {
+				defer wg.Done()
+				for i := range next {
+					statedb := newTxState(i, res.mem, base)
+					task(i, statedb)
+					res.mem.record(i, statedb.finalise())
+					txReads[i], res.logs[i] = statedb.reads, statedb.logs
+				}
+			}(bases[w])
+		}
+		for _, i := range pending {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+		res.Executions += len(pending)
+
+		// validate in order. The first transaction that fails validation saw
+		// the final writes of all the transactions before it when executed
+		// again, so every round commits at least one transaction.
+		pending = pending[:0]
+		for i := committed; i < n; i++ {
+			if !res.valid(validator, i, txReads[i]) {
+				pending = append(pending, i)
+			} else if i == committed {
+				committed++
+			}
+		}
+	}
+	return res
+}
+
+// valid reports whether the values read by transaction tx are still those the
+// transactions before it wrote.
+func (r *Result) valid(base *gstate.StateDB, tx int, reads reads) bool {
+	for addr, seen := range reads.accounts {
+		a, ok := r.mem.account(addr, tx)
+		if !ok {
+			a = readAccount(base, addr)
+		}
+		if !a.equal(&seen) {
+			return false
+		}
+	}
+	for key, seen := range reads.slots {
+		v, ok := r.mem.storage(key.addr, key.slot, tx)
+		if !ok {
+			v = base.GetState(key.addr, key.slot)
+		}
+		if v != seen {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply makes the changes of transaction i to statedb, and adds its logs under
+// the transaction context statedb was given. Transactions must be applied in
+// order, each followed by statedb.Finalise, to reproduce sequential execution.
+func (r *Result) Apply(i int, statedb *gstate.StateDB) {
+	addrs, writes := r.mem.writes(i)
+	for j, addr := range addrs {
+		w := writes[j]
+		if !w.account.exists {
+			// destroyed, the account is deleted when the state is finalised
+			statedb.SelfDestruct(addr)
+			continue
+		}
+		if w.reset || !statedb.Exist(addr) {
+			statedb.CreateAccount(addr)
+		}
+		statedb.SetBalance(addr, w.account.balance)
+		statedb.SetNonce(addr, w.account.nonce)
+		if statedb.GetCodeHash(addr) != w.account.codeHash {
+			statedb.SetCode(addr, w.account.code)
+		}
+		for _, slot := range sortedSlots(w.storage) {
+			statedb.SetState(addr, slot, w.storage[slot])
+		}
+	}
+	for _, log := range r.logs[i] {
+		statedb.AddLog(log)
+	}
+}
+
+// Logs returns the logs emitted by transaction i.
+func (r *Result) Logs(i int) []*types.Log {
+	return r.logs[i]
+}
+
+func sortedSlots(storage map[common.Hash]common.Hash) []common.Hash {
+	slots := make([]common.Hash, 0, len(storage))
+	for slot := range storage {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i][:], slots[j][:]) < 0 })
+	return slots
+}
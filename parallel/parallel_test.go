@@ -0,0 +1,123 @@
+package parallel
+
+import (
+	"math/big"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// randomTask returns transactions that move balances between a few accounts,
+// read and write shared storage, revert part of their work, and create and
+// destroy accounts, so that they conflict a lot.
+func randomTask(seed int64) Task {
+	addrs := make([]common.Address, 6)
+	for i := range addrs {
+		addrs[i] = common.BytesToAddress([]byte{byte(i + 1)})
+	}
+	return func(i int, statedb vm.StateDB) {
+		rng := rand.New(rand.NewSource(seed + int64(i)))
+		pick := func() common.Address { return addrs[rng.Intn(len(addrs))] }
+		slot := func() common.Hash { return common.BigToHash(big.NewInt(rng.Int63n(4))) }
+
+		for op := 0; op < 8; op++ {
+			switch rng.Intn(7) {
+			case 0:
+				from, to := pick(), pick()
+				if bal := statedb.GetBalance(from); bal.Sign() > 0 {
+					amount := new(big.Int).Div(bal, big.NewInt(2))
+					statedb.SubBalance(from, amount)
+					statedb.AddBalance(to, amount)
+				}
+			case 1:
+				addr, key := pick(), slot()
+				v := statedb.GetState(addr, key).Big()
+				statedb.SetState(addr, key, common.BigToHash(v.Add(v, big.NewInt(int64(i)))))
+			case 2:
+				addr := pick()
+				statedb.SetNonce(addr, statedb.GetNonce(addr)+1)
+			case 3:
+				snap := statedb.Snapshot()
+				statedb.SetState(pick(), slot(), common.Hash{0xff})
+				statedb.AddBalance(pick(), big.NewInt(1000))
+				statedb.RevertToSnapshot(snap)
+			case 4:
+				statedb.CreateAccount(pick())
+			case 5:
+				statedb.SelfDestruct(pick())
+			case 6:
+				addr := pick()
+				if statedb.GetCodeSize(addr) == 0 {
+					statedb.SetCode(addr, []byte{byte(i)})
+				}
+				statedb.AddLog(&types.Log{Address: addr})
+			}
+		}
+	}
+}
+
+func newBase(t *testing.T) *gstate.StateDB {
+	statedb, err := gstate.New(types.EmptyRootHash, gstate.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		addr := common.BytesToAddress([]byte{byte(i)})
+		statedb.AddBalance(addr, big.NewInt(1e6))
+		statedb.SetState(addr, common.Hash{}, common.Hash{byte(i)})
+	}
+	statedb.Finalise(false)
+	return statedb
+}
+
+func TestExecuteMatchesSequential(t *testing.T) {
+	const n = 64
+	for seed := int64(0); seed < 10; seed++ {
+		task := randomTask(seed * 1000)
+
+		sequential := newBase(t)
+		var wantLogs int
+		for i := 0; i < n; i++ {
+			sequential.SetTxContext(common.Hash{byte(i)}, i)
+			task(i, sequential)
+			wantLogs += len(sequential.GetLogs(common.Hash{byte(i)}, 0, common.Hash{}))
+			sequential.Finalise(false)
+		}
+
+		// the first executions finish in reverse order, so that most of them
+		// read stale state and must be executed again
+		var started [n]atomic.Bool
+		slowTask := func(i int, statedb vm.StateDB) {
+			if !started[i].Swap(true) {
+				time.Sleep(time.Duration(n-i) * 50 * time.Microsecond)
+			}
+			task(i, statedb)
+		}
+		concurrent := newBase(t)
+		res := Execute(concurrent, n, n, slowTask)
+		var haveLogs int
+		for i := 0; i < n; i++ {
+			concurrent.SetTxContext(common.Hash{byte(i)}, i)
+			res.Apply(i, concurrent)
+			haveLogs += len(concurrent.GetLogs(common.Hash{byte(i)}, 0, common.Hash{}))
+			concurrent.Finalise(false)
+		}
+
+		if want, have := sequential.IntermediateRoot(false), concurrent.IntermediateRoot(false); want != have {
+			t.Errorf("seed %d: root %v, want %v", seed, have, want)
+		}
+		if wantLogs != haveLogs {
+			t.Errorf("seed %d: %d logs, want %d", seed, haveLogs, wantLogs)
+		}
+		if res.Executions <= n {
+			t.Errorf("seed %d: %d executions for %d transactions", seed, res.Executions, n)
+		}
+	}
+}
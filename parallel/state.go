@@ -0,0 +1,404 @@
+package parallel
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	gstate "github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type slotKey struct {
+	addr common.Address
+	slot common.Hash
+}
+
+// reads is what a transaction read from outside of itself.
+type reads struct {
+	accounts map[common.Address]account
+	slots    map[slotKey]common.Hash
+}
+
+// object is an account loaded into a transaction.
+type object struct {
+	account
+	prior      account                     // the account before the transaction
+	origin     map[common.Hash]common.Hash // storage before the transaction
+	storage    map[common.Hash]common.Hash // storage written by the transaction
+	reset      bool                        // storage cleared by the transaction
+	created    bool                        // created by the transaction
+	destructed bool
+}
+
+// txState is the state a single transaction executes on. Reads come from the
+// transaction's own writes, then from the writes of the transactions before it
+// in memory, then from the base state, and are recorded so the execution can
+// be validated. It implements vm.StateDB with the semantics of a StateDB that
+// is finalised after every transaction.
+type txState struct {
+	tx   int
+	mem  *memory
+	base *gstate.StateDB
+
+	reads   reads
+	objects map[common.Address]*object
+
+	journal   []func()
+	refund    uint64
+	logs      []*types.Log
+	access    map[common.Address]map[common.Hash]bool
+	transient map[slotKey]common.Hash
+}
+
+func newTxState(tx int, mem *memory, base *gstate.StateDB) *txState {
+	return &txState{
+		tx:   tx,
+		mem:  mem,
+		base: base,
+		reads: reads{
+			accounts: make(map[common.Address]account),
+			slots:    make(map[slotKey]common.Hash),
+		},
+		objects:   make(map[common.Address]*object),
+		access:    make(map[common.Address]map[common.Hash]bool),
+		transient: make(map[slotKey]common.Hash),
+	}
+}
+
+// priorAccount reads an account as the transactions before this one left it.
+func (s *txState) priorAccount(addr common.Address) account {
+	a, ok := s.mem.account(addr, s.tx)
+	if !ok {
+		a = readAccount(s.base, addr)
+	}
+	s.reads.accounts[addr] = a
+	return a
+}
+
+// priorSlot reads a storage slot as the transactions before this one left it.
+func (s *txState) priorSlot(addr common.Address, slot common.Hash) common.Hash {
+	v, ok := s.mem.storage(addr, slot, s.tx)
+	if !ok {
+		v = s.base.GetState(addr, slot)
+	}
+	s.reads.slots[slotKey{addr, slot}] = v
+	return v
+}
+
+func (s *txState) object(addr common.Address) *object {
+	obj, ok := s.objects[addr]
+	if !ok {
+		prior := s.priorAccount(addr)
+		obj = &object{
+			account: prior,
+			prior:   prior,
+			origin:  make(map[common.Hash]common.Hash),
+			storage: make(map[common.Hash]common.Hash),
+		}
+		obj.balance = new(big.Int).Set(prior.balance)
+		s.objects[addr] = obj
+	}
+	return obj
+}
+
+// existing returns the account if it exists, like getStateObject.
+func (s *txState) existing(addr common.Address) *object {
+	if obj := s.object(addr); obj.exists {
+		return obj
+	}
+	return nil
+}
+
+// writable returns the account, creating it if it doesn't exist, like
+// GetOrNewStateObject.
+func (s *txState) writable(addr common.Address) *object {
+	obj := s.object(addr)
+	if !obj.exists {
+		s.create(obj)
+	}
+	return obj
+}
+
+// create resets obj to a new account.
+func (s *txState) create(obj *object) {
+	prev := *obj
+	s.journal = append(s.journal, func() { *obj = prev })
+	obj.account = account{exists: true, balance: new(big.Int), codeHash: types.EmptyCodeHash}
+	obj.storage = make(map[common.Hash]common.Hash)
+	obj.reset, obj.created, obj.destructed = true, true, false
+}
+
+func (s *txState) CreateAccount(addr common.Address) {
+	obj := s.object(addr)
+	balance := new(big.Int).Set(obj.balance)
+	existed := obj.exists
+	s.create(obj)
+	if existed {
+		obj.balance = balance
+	}
+}
+
+func (s *txState) setBalance(obj *object, balance *big.Int) {
+	prev := obj.balance
+	s.journal = append(s.journal, func() { obj.balance = prev })
+	obj.balance = balance
+}
+
+func (s *txState) SubBalance(addr common.Address, amount *big.Int) {
+	obj := s.writable(addr)
+	if amount.Sign() != 0 {
+		s.setBalance(obj, new(big.Int).Sub(obj.balance, amount))
+	}
+}
+
+func (s *txState) AddBalance(addr common.Address, amount *big.Int) {
+	obj := s.writable(addr)
+	if amount.Sign() != 0 {
+		s.setBalance(obj, new(big.Int).Add(obj.balance, amount))
+	}
+}
+
+func (s *txState) GetBalance(addr common.Address) *big.Int {
+	if obj := s.existing(addr); obj != nil {
+		return obj.balance
+	}
+	return common.Big0
+}
+
+func (s *txState) GetNonce(addr common.Address) uint64 {
+	if obj := s.existing(addr); obj != nil {
+		return obj.nonce
+	}
+	return 0
+}
+
+func (s *txState) SetNonce(addr common.Address, nonce uint64) {
+	obj := s.writable(addr)
+	prev := obj.nonce
+	s.journal = append(s.journal, func() { obj.nonce = prev })
+	obj.nonce = nonce
+}
+
+func (s *txState) GetCodeHash(addr common.Address) common.Hash {
+	if obj := s.existing(addr); obj != nil {
+		return obj.codeHash
+	}
+	return common.Hash{}
+}
+
+func (s *txState) GetCode(addr common.Address) []byte {
+	if obj := s.existing(addr); obj != nil {
+		return obj.code
+	}
+	return nil
+}
+
+func (s *txState) SetCode(addr common.Address, code []byte) {
+	obj := s.writable(addr)
+	prevCode, prevHash := obj.code, obj.codeHash
+	s.journal = append(s.journal, func() { obj.code, obj.codeHash = prevCode, prevHash })
+	obj.code, obj.codeHash = code, crypto.Keccak256Hash(code)
+}
+
+func (s *txState) GetCodeSize(addr common.Address) int {
+	return len(s.GetCode(addr))
+}
+
+func (s *txState) AddRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	s.refund += gas
+}
+
+func (s *txState) SubRefund(gas uint64) {
+	if gas > s.refund {
+		panic("refund counter below zero")
+	}
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	s.refund -= gas
+}
+
+func (s *txState) GetRefund() uint64 {
+	return s.refund
+}
+
+// GetCommittedState returns the value of a slot before the transaction.
+func (s *txState) GetCommittedState(addr common.Address, slot common.Hash) common.Hash {
+	obj := s.existing(addr)
+	if obj == nil || obj.reset {
+		return common.Hash{}
+	}
+	if v, ok := obj.origin[slot]; ok {
+		return v
+	}
+	v := s.priorSlot(addr, slot)
+	obj.origin[slot] = v
+	return v
+}
+
+func (s *txState) GetState(addr common.Address, slot common.Hash) common.Hash {
+	obj := s.existing(addr)
+	if obj == nil {
+		return common.Hash{}
+	}
+	if v, ok := obj.storage[slot]; ok {
+		return v
+	}
+	return s.GetCommittedState(addr, slot)
+}
+
+func (s *txState) SetState(addr common.Address, slot common.Hash, value common.Hash) {
+	obj := s.writable(addr)
+	prev, written := obj.storage[slot]
+	s.journal = append(s.journal, func() {
+		if written {
+			obj.storage[slot] = prev
+		} else {
+			delete(obj.storage, slot)
+		}
+	})
+	obj.storage[slot] = value
+}
+
+func (s *txState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	return s.transient[slotKey{addr, key}]
+}
+
+func (s *txState) SetTransientState(addr common.Address, key, value common.Hash) {
+	k := slotKey{addr, key}
+	prev, ok := s.transient[k]
+	s.journal = append(s.journal, func() {
+		if ok {
+			s.transient[k] = prev
+		} else {
+			delete(s.transient, k)
+		}
+	})
+	s.transient[k] = value
+}
+
+func (s *txState) SelfDestruct(addr common.Address) {
+	obj := s.existing(addr)
+	if obj == nil {
+		return
+	}
+	prevBalance, prevDestructed := obj.balance, obj.destructed
+	s.journal = append(s.journal, func() { obj.balance, obj.destructed = prevBalance, prevDestructed })
+	obj.balance, obj.destructed = new(big.Int), true
+}
+
+func (s *txState) HasSelfDestructed(addr common.Address) bool {
+	if obj := s.existing(addr); obj != nil {
+		return obj.destructed
+	}
+	return false
+}
+
+func (s *txState) Selfdestruct6780(addr common.Address) {
+	if obj := s.existing(addr); obj != nil && obj.created {
+		s.SelfDestruct(addr)
+	}
+}
+
+func (s *txState) Exist(addr common.Address) bool {
+	return s.existing(addr) != nil
+}
+
+func (s *txState) Empty(addr common.Address) bool {
+	obj := s.existing(addr)
+	return obj == nil || (obj.nonce == 0 && obj.balance.Sign() == 0 && obj.codeHash == types.EmptyCodeHash)
+}
+
+func (s *txState) AddressInAccessList(addr common.Address) bool {
+	_, ok := s.access[addr]
+	return ok
+}
+
+func (s *txState) SlotInAccessList(addr common.Address, slot common.Hash) (bool, bool) {
+	slots, ok := s.access[addr]
+	return ok, slots[slot]
+}
+
+func (s *txState) AddAddressToAccessList(addr common.Address) {
+	if _, ok := s.access[addr]; ok {
+		return
+	}
+	s.journal = append(s.journal, func() { delete(s.access, addr) })
+	s.access[addr] = make(map[common.Hash]bool)
+}
+
+func (s *txState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.AddAddressToAccessList(addr)
+	if s.access[addr][slot] {
+		return
+	}
+	s.journal = append(s.journal, func() { delete(s.access[addr], slot) })
+	s.access[addr][slot] = true
+}
+
+func (s *txState) Prepare(rules params.Rules, sender, coinbase common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	if rules.IsBerlin {
+		s.access = make(map[common.Address]map[common.Hash]bool)
+		s.AddAddressToAccessList(sender)
+		if dst != nil {
+			s.AddAddressToAccessList(*dst)
+		}
+		for _, addr := range precompiles {
+			s.AddAddressToAccessList(addr)
+		}
+		for _, el := range list {
+			s.AddAddressToAccessList(el.Address)
+			for _, key := range el.StorageKeys {
+				s.AddSlotToAccessList(el.Address, key)
+			}
+		}
+		if rules.IsShanghai {
+			s.AddAddressToAccessList(coinbase)
+		}
+	}
+	s.transient = make(map[slotKey]common.Hash)
+}
+
+func (s *txState) Snapshot() int {
+	return len(s.journal)
+}
+
+func (s *txState) RevertToSnapshot(id int) {
+	for i := len(s.journal) - 1; i >= id; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:id]
+}
+
+func (s *txState) AddLog(log *types.Log) {
+	n := len(s.logs)
+	s.journal = append(s.journal, func() { s.logs = s.logs[:n] })
+	s.logs = append(s.logs, log)
+}
+
+// AddPreimage does nothing, preimages are not recorded.
+func (s *txState) AddPreimage(common.Hash, []byte) {}
+
+// finalise ends the transaction, destroying the accounts that self-destructed,
+// and returns the accounts it changed.
+func (s *txState) finalise() map[common.Address]*write {
+	writes := make(map[common.Address]*write)
+	for addr, obj := range s.objects {
+		if obj.destructed {
+			obj.account = account{balance: new(big.Int)}
+			obj.storage = make(map[common.Hash]common.Hash)
+			obj.reset = true
+		}
+		if !obj.reset && len(obj.storage) == 0 && obj.account.equal(&obj.prior) {
+			continue
+		}
+		if !obj.exists && !obj.prior.exists {
+			continue
+		}
+		writes[addr] = &write{account: obj.account, reset: obj.reset, storage: obj.storage}
+	}
+	return writes
+}
@@ -0,0 +1,127 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// Value is a quantity measured by the profiler.
+type Value int
+
+const (
+	Gas  Value = iota // gas consumed
+	Time              // wall time, in nanoseconds
+	Ops               // opcodes executed
+)
+
+func (s Sample) value(v Value) int64 {
+	switch v {
+	case Time:
+		return int64(s.Time)
+	case Ops:
+		return int64(s.Ops)
+	}
+	return int64(s.Gas)
+}
+
+// Profile returns the profile in the format of pprof, with gas, time and
+// opcode samples, gas being the default.
+func (p *Profiler) Profile() *profile.Profile {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "gas", Unit: "count"},
+			{Type: "time", Unit: "nanoseconds"},
+			{Type: "ops", Unit: "count"},
+		},
+		DefaultSampleType: "gas",
+		PeriodType:        &profile.ValueType{Type: "gas", Unit: "count"},
+		Period:            1,
+	}
+	type fn struct{ name, file string }
+	type loc struct {
+		fn   fn
+		line int
+	}
+	var (
+		functions = make(map[fn]*profile.Function)
+		locations = make(map[loc]*profile.Location)
+	)
+	location := func(f Frame) *profile.Location {
+		l := loc{fn: fn{name: f.Function, file: f.File}, line: f.Line}
+		if location, ok := locations[l]; ok {
+			return location
+		}
+		function, ok := functions[l.fn]
+		if !ok {
+			function = &profile.Function{ID: uint64(len(prof.Function) + 1), Name: f.Function, SystemName: f.Function, Filename: f.File}
+			functions[l.fn] = function
+			prof.Function = append(prof.Function, function)
+		}
+		location := &profile.Location{
+			ID:   uint64(len(prof.Location) + 1),
+			Line: []profile.Line{{Function: function, Line: int64(f.Line)}},
+		}
+		locations[l] = location
+		prof.Location = append(prof.Location, location)
+		return location
+	}
+	for _, s := range p.Samples() {
+		sample := &profile.Sample{Value: []int64{s.value(Gas), s.value(Time), s.value(Ops)}}
+		// pprof stacks start with the innermost function
+		for i := len(s.Stack) - 1; i >= 0; i-- {
+			sample.Location = append(sample.Location, location(s.Stack[i]))
+		}
+		prof.Sample = append(prof.Sample, sample)
+	}
+	return prof
+}
+
+// WritePprof writes the profile in the gzipped protobuf format of pprof, for
+// go tool pprof.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	return p.Profile().Write(w)
+}
+
+// WriteFolded writes the profile as folded stacks of value, one stack per
+// line, for flamegraph.pl, speedscope or inferno:
+//
+//	Vault.withdraw;Vault.check;Vault.sol:7 2300
+//
+// The last frame of a stack is the line being executed, if known. Stacks
+// with a zero value are left out.
+func (p *Profiler) WriteFolded(w io.Writer, v Value) error {
+	bw := bufio.NewWriter(w)
+	totals := make(map[string]int64)
+	var order []string
+	for _, s := range p.Samples() {
+		value := s.value(v)
+		if value == 0 {
+			continue
+		}
+		frames := make([]string, 0, len(s.Stack)+1)
+		for _, f := range s.Stack {
+			frames = append(frames, folded(f.Function))
+		}
+		if leaf := s.Stack[len(s.Stack)-1]; leaf.File != "" {
+			frames = append(frames, folded(fmt.Sprintf("%s:%d", leaf.File, leaf.Line)))
+		}
+		stack := strings.Join(frames, ";")
+		if _, ok := totals[stack]; !ok {
+			order = append(order, stack)
+		}
+		totals[stack] += value
+	}
+	for _, stack := range order {
+		fmt.Fprintf(bw, "%s %d\n", stack, totals[stack])
+	}
+	return bw.Flush()
+}
+
+// folded escapes the separators of the folded format in a frame.
+func folded(frame string) string {
+	return strings.NewReplacer(";", "_", " ", "_", "\n", "_").Replace(frame)
+}
@@ -0,0 +1,260 @@
+// Package profiler attributes the gas and the time spent by executions to the
+// contracts, functions and, with source maps, the Solidity lines they were
+// spent in, and writes them as pprof profiles and folded stacks for flame
+// graphs.
+package profiler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Frame is a function of a stack.
+type Frame struct {
+	Function string // e.g. Vault.withdraw, or address.selector without artifacts
+	File     string // empty if unknown
+	Line     int    // line being executed in the function, zero if unknown
+}
+
+// Sample is the gas and the time spent in a stack.
+type Sample struct {
+	Stack []Frame // the outermost function first
+	Gas   uint64
+	Time  time.Duration
+	Ops   uint64 // number of opcodes executed
+}
+
+// call is a call being executed.
+type call struct {
+	name      string // of the function called
+	label     string // of the contract called, prefix of its internal functions
+	contract  *sourcemap.Contract
+	functions *sourcemap.Functions
+	gas       uint64 // given to the call
+
+	pending  *Sample // of the opcode being executed, until its gas is known
+	opGas    uint64  // gas left before the pending opcode
+	childGas uint64  // used by the calls made by the pending opcode
+}
+
+// Profiler is an EVM logger accumulating the gas and the time spent by the
+// executions it is attached to. The gas of an opcode is the gas it consumed,
+// without the gas used by the calls it made; the time of an opcode is the
+// time between the tracer events around it, so it doesn't include the time
+// spent tracing. The intrinsic gas of transactions is not attributed.
+//
+// A profiler may be attached to several executions one after the other, but
+// not concurrently. Its profile is read once they are done.
+type Profiler struct {
+	registry *sourcemap.Registry
+	env      *vm.EVM
+	calls    []*call
+
+	samples map[string]*Sample // by stack
+	last    *Sample            // of the opcode timed
+	since   time.Time          // start of the opcode timed
+}
+
+// New returns a profiler mapping the contracts of registry to their sources,
+// or only naming contracts by address and functions by selector if registry is
+// nil.
+func New(registry *sourcemap.Registry) *Profiler {
+	return &Profiler{registry: registry, samples: make(map[string]*Sample)}
+}
+
+// Samples returns the samples of the profile, sorted by stack.
+func (p *Profiler) Samples() []Sample {
+	keys := make([]string, 0, len(p.samples))
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, len(keys))
+	for i, key := range keys {
+		samples[i] = *p.samples[key]
+	}
+	return samples
+}
+
+// Reset discards the profile.
+func (p *Profiler) Reset() {
+	p.samples = make(map[string]*Sample)
+	p.last = nil
+}
+
+func (p *Profiler) CaptureTxStart(gasLimit uint64) {}
+
+func (p *Profiler) CaptureTxEnd(restGas uint64) {}
+
+func (p *Profiler) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	p.env = env
+	p.calls = p.calls[:0]
+	p.enter(to, create, input, gas)
+}
+
+func (p *Profiler) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	p.exit(gasUsed)
+}
+
+func (p *Profiler) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	p.enter(to, typ == vm.CREATE || typ == vm.CREATE2, input, gas)
+}
+
+func (p *Profiler) CaptureExit(output []byte, gasUsed uint64, err error) {
+	p.exit(gasUsed)
+}
+
+func (p *Profiler) enter(to common.Address, create bool, input []byte, gas uint64) {
+	p.stopClock()
+	c := &call{label: to.Hex(), gas: gas}
+	if !create && p.registry != nil {
+		// the initialization code of created contracts is not mapped
+		c.contract = p.registry.Lookup(p.env.StateDB.GetCode(to))
+	}
+	if c.contract != nil {
+		c.label = c.contract.Name
+	}
+	c.functions = sourcemap.NewFunctions(c.contract)
+
+	var method string
+	if c.contract != nil {
+		method, _, _ = c.contract.Call(input)
+	}
+	switch {
+	case create:
+		method = "constructor"
+	case method != "":
+	case len(input) >= 4:
+		method = "0x" + hex.EncodeToString(input[:4])
+	default:
+		method = "fallback"
+	}
+	c.name = c.label + "." + method
+	p.calls = append(p.calls, c)
+}
+
+// exit attributes the gas left unaccounted for when a call ends: the gas of
+// its last opcode, or all of its gas if it ran no code, like a precompile.
+func (p *Profiler) exit(gasUsed uint64) {
+	if len(p.calls) == 0 {
+		return
+	}
+	p.stopClock()
+	c := p.calls[len(p.calls)-1]
+	if c.pending != nil {
+		c.settle(c.gas - gasUsed)
+	} else if gasUsed > 0 {
+		p.sample(p.stack()).Gas += gasUsed
+	}
+	p.calls = p.calls[:len(p.calls)-1]
+	if len(p.calls) > 0 {
+		parent := p.calls[len(p.calls)-1]
+		parent.childGas += gasUsed
+		// the rest of the calling opcode
+		p.startClock(parent.pending)
+	}
+}
+
+// settle attributes the gas of the pending opcode, given the gas left after
+// it.
+func (c *call) settle(gasLeft uint64) {
+	if used := c.opGas - gasLeft; gasLeft <= c.opGas && used >= c.childGas {
+		c.pending.Gas += used - c.childGas
+	}
+	c.pending, c.childGas = nil, 0
+}
+
+func (p *Profiler) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if len(p.calls) == 0 {
+		return
+	}
+	p.stopClock()
+	c := p.calls[len(p.calls)-1]
+	if c.pending != nil {
+		c.settle(gas)
+	}
+	c.functions.Step(pc, op)
+	s := p.sample(p.stack())
+	s.Ops++
+	c.pending, c.opGas = s, gas
+	p.startClock(s)
+}
+
+func (p *Profiler) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (p *Profiler) startClock(s *Sample) {
+	p.last = s
+	p.since = time.Now()
+}
+
+func (p *Profiler) stopClock() {
+	if p.last != nil {
+		p.last.Time += time.Since(p.since)
+		p.last = nil
+	}
+}
+
+// stack returns the functions being executed, the outermost first.
+func (p *Profiler) stack() []Frame {
+	var stack []Frame
+	for _, c := range p.calls {
+		stack = append(stack, c.frames()...)
+	}
+	return stack
+}
+
+// frames returns the functions a call is in, the outermost first.
+func (c *call) frames() []Frame {
+	internal := c.functions.Calls
+	if len(internal) > 0 && c.label+"."+internal[0].Function == c.name {
+		// the body of the called method, jumped into by the dispatcher
+		internal = internal[1:]
+	}
+	frames := make([]Frame, 0, len(internal)+1)
+	frames = append(frames, Frame{Function: c.name})
+	for _, in := range internal {
+		name := in.Function
+		if name == "" {
+			name = fmt.Sprintf("<pc %d>", in.Site)
+		}
+		frames = append(frames, Frame{Function: c.label + "." + name})
+	}
+	// each function is at the jump into the next one, the last one at the
+	// instruction being executed
+	for i := range frames {
+		pc := c.functions.PC()
+		if i+1 < len(frames) {
+			pc = internal[i].Site
+		}
+		if c.contract != nil {
+			if loc, ok := c.contract.Location(pc); ok {
+				frames[i].File, frames[i].Line = loc.File, loc.Line
+			}
+		}
+	}
+	return frames
+}
+
+// sample returns the sample of stack, adding it if it is new.
+func (p *Profiler) sample(stack []Frame) *Sample {
+	var b strings.Builder
+	for _, f := range stack {
+		fmt.Fprintf(&b, "%s\x00%s\x00%d\x00", f.Function, f.File, f.Line)
+	}
+	key := b.String()
+	s, ok := p.samples[key]
+	if !ok {
+		s = &Sample{Stack: stack}
+		p.samples[key] = s
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/daweth/gevm/internal/evmtest"
+	"github.com/daweth/gevm/sourcemap"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	counter = common.HexToAddress("0xc0ffee")
+	caller  = common.HexToAddress("0xca11")
+
+	// SLOAD(0) + 1 -> SSTORE(0): PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE STOP
+	counterCode = common.FromHex("0x60005460010160005500")
+	// CALL(gas, counter, 0, 0, 0, 0, 0) then STOP
+	callerCode = common.FromHex("0x6000600060006000600062c0ffee5af100")
+)
+
+const counterSource = `contract Counter {
+    uint256 count;
+
+    function inc() external {
+        count += 1;
+    }
+}
+`
+
+// counterArtifact maps every instruction of the counter but its STOP to
+// count += 1.
+func counterArtifact() *sourcemap.Artifact {
+	line := fmt.Sprintf("%d:10:0:-", strings.Index(counterSource, "count += 1"))
+	return &sourcemap.Artifact{
+		Name:      "Counter",
+		ABI:       json.RawMessage(`[{"type":"function","name":"inc","inputs":[],"outputs":[],"stateMutability":"nonpayable"}]`),
+		Code:      counterCode,
+		SourceMap: strings.Repeat(line+";", 6) + "0:0:-1:-",
+		Sources:   []sourcemap.Source{{Name: "Counter.sol", Content: counterSource}},
+	}
+}
+
+// run calls to with input, with tracer attached, and returns the gas used.
+func run(t *testing.T, tracer vm.EVMLogger, to common.Address, input []byte) uint64 {
+	code := map[common.Address][]byte{counter: counterCode, caller: callerCode}
+	_, gasUsed, err := evmtest.Run(t, code, tracer, to, input)
+	require.NoError(t, err)
+	return gasUsed
+}
+
+func TestCallTree(t *testing.T) {
+	p := New(nil)
+	gasUsed := run(t, p, caller, nil)
+
+	var total, ops uint64
+	for _, s := range p.Samples() {
+		total += s.Gas
+		ops += s.Ops
+	}
+	assert.Equal(t, gasUsed, total)
+	assert.Equal(t, uint64(9+7), ops)
+
+	var out bytes.Buffer
+	require.NoError(t, p.WriteFolded(&out, Gas))
+	assert.Equal(t, fmt.Sprintf("%[1]s.fallback %[3]d\n%[1]s.fallback;%[2]s.fallback 22112\n", caller.Hex(), counter.Hex(), gasUsed-22112), out.String())
+
+	// profiles add up
+	run(t, p, caller, nil)
+	out.Reset()
+	require.NoError(t, p.WriteFolded(&out, Ops))
+	assert.Equal(t, fmt.Sprintf("%[1]s.fallback 18\n%[1]s.fallback;%[2]s.fallback 14\n", caller.Hex(), counter.Hex()), out.String())
+
+	p.Reset()
+	assert.Empty(t, p.Samples())
+}
+
+func TestSourceLines(t *testing.T) {
+	r := sourcemap.NewRegistry()
+	require.NoError(t, r.Add(counterArtifact()))
+	p := New(r)
+	run(t, p, counter, crypto.Keccak256([]byte("inc()"))[:4])
+
+	var out bytes.Buffer
+	require.NoError(t, p.WriteFolded(&out, Gas))
+	assert.Equal(t, "Counter.inc;Counter.sol:5 22112\n", out.String())
+
+	out.Reset()
+	require.NoError(t, p.WritePprof(&out))
+	prof, err := profile.Parse(&out)
+	require.NoError(t, err)
+	assert.Equal(t, "gas", prof.SampleType[0].Type)
+	assert.Equal(t, "time", prof.SampleType[1].Type)
+	var gas, ns int64
+	lines := make(map[int64]int64)
+	for _, s := range prof.Sample {
+		gas += s.Value[0]
+		ns += s.Value[1]
+		line := s.Location[0].Line[0]
+		assert.Equal(t, "Counter.inc", line.Function.Name)
+		lines[line.Line] += s.Value[0]
+	}
+	assert.Equal(t, int64(22112), gas)
+	assert.Equal(t, map[int64]int64{5: 22112, 0: 0}, lines)
+	assert.Positive(t, ns)
+}
@@ -0,0 +1,94 @@
+// Package scheduler runs EVM system calls as the Keystone game advances. Each
+// game tick executes the calls that are due from core.SystemAddress and seals
+// them, together with the transactions received since the previous tick, into
+// the tick's block.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/curio-research/keystone/server"
+	"github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultCallGas is the gas given to calls that don't set their own limit.
+const DefaultCallGas = uint64(5000000)
+
+// Call is a system call run on the game tick, e.g. settling auctions or
+// advancing a season.
+type Call struct {
+	Name       string         // Label used in errors
+	Contract   common.Address // Contract to call
+	Input      []byte         // Calldata
+	Gas        uint64         // Gas limit, DefaultCallGas if zero
+	IntervalMs int            // How often to run the call, every tick if zero
+}
+
+func (c *Call) due(tick *server.GameTick) bool {
+	if c.IntervalMs == 0 {
+		return true
+	}
+	return server.ShouldTriggerTick(tick.TickNumber, tick.TickRateMs, c.IntervalMs)
+}
+
+// Scheduler runs registered system calls against a node on every game tick.
+type Scheduler struct {
+	exec *core.Executor
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// New creates a scheduler for the node run by exec.
+func New(exec *core.Executor) *Scheduler {
+	return &Scheduler{exec: exec}
+}
+
+// Register adds a system call. Calls due on the same tick run in the order
+// they were registered.
+func (s *Scheduler) Register(c Call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, c)
+}
+
+// Tick runs the calls due on the current game tick and seals the block. A
+// failing call is reverted and reported, without stopping the others. The
+// tick is a single job of the executor, so no transaction lands between the
+// calls and the seal.
+func (s *Scheduler) Tick(ctx *server.EngineCtx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	err := s.exec.Do(func(node *core.NodeCtx) error {
+		for i := range s.calls {
+			c := &s.calls[i]
+			if !c.due(ctx.GameTick) {
+				continue
+			}
+			gas := c.Gas
+			if gas == 0 {
+				gas = DefaultCallGas
+			}
+			if _, _, err := node.ApplySystemCall(c.Contract, c.Input, gas); err != nil {
+				errs = append(errs, fmt.Errorf("scheduler: call %q: %w", c.Name, err))
+			}
+		}
+		if _, err := node.SealBlock(); err != nil {
+			errs = append(errs, fmt.Errorf("scheduler: sealing block: %w", err))
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// Attach schedules the scheduler to run on every tick of the engine. It should
+// be attached after the game systems, so the block closes the tick.
+func (s *Scheduler) Attach(ctx *server.EngineCtx) {
+	ctx.AddSystem(ctx.GameTick.TickRateMs, s.Tick)
+}
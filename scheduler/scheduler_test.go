@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/curio-research/keystone/server"
+	"github.com/daweth/gevm/core"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterCode increments slot 0 and stores the caller in slot 1.
+var counterCode = hexutil.MustDecode("0x60005460010160005533600155" + "00")
+
+func newNode() *core.NodeCtx {
+	node := core.NewMemoryNodeContext(1e12, 0, common.HexToAddress("alice"), common.HexToAddress("bob"))
+	return &node
+}
+
+func TestSchedulerRunsCallsEveryTick(t *testing.T) {
+	node := newNode()
+	counter := common.HexToAddress("0xc0ffee")
+	node.StateDB.SetCode(counter, counterCode)
+
+	exec := core.NewExecutor(node)
+	defer exec.Close()
+	s := New(exec)
+	s.Register(Call{Name: "count", Contract: counter})
+	s.Register(Call{Name: "slow", Contract: counter, IntervalMs: 300})
+
+	engine := &server.EngineCtx{GameTick: server.NewGameTick(100)}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Tick(engine))
+		engine.GameTick.TickNumber++
+	}
+
+	// three ticks of "count" plus one of "slow"
+	assert.Equal(t, uint64(4), node.StateDB.GetState(counter, common.Hash{}).Big().Uint64())
+	assert.Equal(t, common.BytesToHash(core.SystemAddress.Bytes()), node.StateDB.GetState(counter, common.BigToHash(common.Big1)))
+
+	head := node.Head()
+	require.NotNil(t, head)
+	assert.Equal(t, node.StateDB.IntermediateRoot(false), head.Header.Root)
+	assert.Len(t, head.Transactions, 2)
+	assert.NotZero(t, head.Header.GasUsed)
+	assert.Equal(t, head.NumberU64()+1, node.PendingHeader().Number.Uint64())
+	assert.Equal(t, head, node.BlockByHash(node.PendingHeader().ParentHash))
+}
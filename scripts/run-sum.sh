@@ -2,22 +2,21 @@
 
 
 # first, check 
-# if go exists and is the right version
+# if go exists and is recent enough
 if ! command -v go &> /dev/null; then
     echo "go isn't configured correctly or is not installed."
     exit 1
 else
     v=`go version | { read _ _ v _; echo ${v#go}; }`
 
-    if [[ "$v" != "1.20" ]]; then
-        echo "the go version is incorrect."
+    if [[ "$(printf '%s\n' 1.20 "$v" | sort -V | head -n1)" != "1.20" ]]; then
+        echo "go 1.20 or later is required, found $v."
         exit 1
     else
         # echo "go version: $v"
         cd ../examples/sum
         OUTPUT=$(go run main.go)
         echo "ran the main script, cleaning up files"
-        rm -rf ./gevm-db/
         rm ./gevm
         echo $OUTPUT
     fi 
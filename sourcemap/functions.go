@@ -0,0 +1,51 @@
+package sourcemap
+
+import "github.com/daweth/gevm/vm"
+
+// InternalCall is a call to an internal function of a contract.
+type InternalCall struct {
+	Site     uint64 // pc of the jump into the function
+	Function string // name of the function, empty if unknown
+}
+
+// Functions follows the internal functions an execution of a contract is in,
+// with the jumps into and out of functions of its source map.
+type Functions struct {
+	contract *Contract // nil if not registered
+	pc       uint64
+	jump     byte // type of the jump at pc
+
+	Calls []InternalCall // the outermost first
+}
+
+// NewFunctions returns a follower of the functions of an execution of c, which
+// follows nothing if c is nil.
+func NewFunctions(c *Contract) *Functions {
+	return &Functions{contract: c}
+}
+
+// Step moves to the instruction at pc, about to be executed.
+func (f *Functions) Step(pc uint64, op vm.OpCode) {
+	if f.contract != nil {
+		switch f.jump {
+		case JumpIn:
+			f.Calls = append(f.Calls, InternalCall{Site: f.pc, Function: f.contract.Function(pc)})
+		case JumpOut:
+			if len(f.Calls) > 0 {
+				f.Calls = f.Calls[:len(f.Calls)-1]
+			}
+		}
+		f.jump = JumpNone
+		if op == vm.JUMP {
+			if e, ok := f.contract.Entry(pc); ok {
+				f.jump = e.Jump
+			}
+		}
+	}
+	f.pc = pc
+}
+
+// PC returns the pc of the instruction being executed.
+func (f *Functions) PC() uint64 {
+	return f.pc
+}
@@ -0,0 +1,263 @@
+package sourcemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Artifact is the output of the compiler for a contract.
+type Artifact struct {
+	Name      string          `json:"name"`
+	ABI       json.RawMessage `json:"abi,omitempty"`
+	Code      hexutil.Bytes   `json:"code"`      // deployed code
+	SourceMap string          `json:"sourceMap"` // of the deployed code
+	Sources   []Source        `json:"sources"`   // by the file index of the source map
+}
+
+// Contract is a registered artifact.
+type Contract struct {
+	*Artifact
+	abi     *abi.ABI // nil without an ABI
+	entries []Entry
+	indexes []int // instruction index by pc
+}
+
+func newContract(a *Artifact) (*Contract, error) {
+	entries, err := Parse(a.SourceMap)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Name, err)
+	}
+	c := &Contract{Artifact: a, entries: entries, indexes: instructionIndexes(a.Code)}
+	if len(a.ABI) > 0 {
+		parsed, err := abi.JSON(bytes.NewReader(a.ABI))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Name, err)
+		}
+		c.abi = &parsed
+	}
+	for i := range a.Sources {
+		a.Sources[i].indexLines()
+	}
+	return c, nil
+}
+
+// Entry returns the source map entry of the instruction at pc.
+func (c *Contract) Entry(pc uint64) (Entry, bool) {
+	if pc >= uint64(len(c.indexes)) || c.indexes[pc] < 0 || c.indexes[pc] >= len(c.entries) {
+		return Entry{}, false
+	}
+	return c.entries[c.indexes[pc]], true
+}
+
+func (c *Contract) source(e Entry) *Source {
+	if e.File < 0 || e.File >= len(c.Sources) || e.Offset > len(c.Sources[e.File].Content) {
+		return nil
+	}
+	return &c.Sources[e.File]
+}
+
+// Location returns the position in the sources of the instruction at pc,
+// false if it was generated by the compiler.
+func (c *Contract) Location(pc uint64) (Location, bool) {
+	e, ok := c.Entry(pc)
+	if !ok {
+		return Location{}, false
+	}
+	src := c.source(e)
+	if src == nil {
+		return Location{}, false
+	}
+	line, column := src.position(e.Offset)
+	return Location{File: src.Name, Line: line, Column: column}, true
+}
+
+var definition = regexp.MustCompile(`^(?:(?:function|modifier)\s+([A-Za-z_$][\w$]*)|(constructor|fallback|receive)\b)`)
+
+// Function returns the name of the function whose definition the instruction
+// at pc was compiled from, such as the destination of a jump into a function.
+func (c *Contract) Function(pc uint64) string {
+	e, ok := c.Entry(pc)
+	if !ok {
+		return ""
+	}
+	src := c.source(e)
+	if src == nil {
+		return ""
+	}
+	end := e.Offset + e.Length
+	if end > len(src.Content) {
+		end = len(src.Content)
+	}
+	m := definition.FindStringSubmatch(src.Content[e.Offset:end])
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+// Call returns the name of the method called by input and its arguments,
+// false if the ABI has no such method.
+func (c *Contract) Call(input []byte) (name string, args []string, ok bool) {
+	if c.abi == nil || len(input) < 4 {
+		return "", nil, false
+	}
+	method, err := c.abi.MethodById(input[:4])
+	if err != nil {
+		return "", nil, false
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return method.RawName, nil, true
+	}
+	args = make([]string, len(values))
+	for i, v := range values {
+		args[i] = fmt.Sprintf("%s=%v", method.Inputs[i].Name, v)
+	}
+	return method.RawName, args, true
+}
+
+// Registry holds the artifacts of the contracts deployed on a chain, by the
+// hash of their deployed code. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	contracts map[common.Hash]*Contract
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{contracts: make(map[common.Hash]*Contract)}
+}
+
+// Load returns a registry with the artifacts of the given files, see
+// LoadCombinedJSON.
+func Load(paths ...string) (*Registry, error) {
+	r := NewRegistry()
+	for _, path := range paths {
+		if err := r.LoadCombinedJSON(path); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Add registers an artifact for its deployed code. The code must be the one
+// deployed: code with immutables or linked libraries must be registered once
+// deployed.
+func (r *Registry) Add(a *Artifact) error {
+	c, err := newContract(a)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contracts[crypto.Keccak256Hash(a.Code)] = c
+	return nil
+}
+
+// Lookup returns the contract of code, nil if it is not registered.
+func (r *Registry) Lookup(code []byte) *Contract {
+	if len(code) == 0 {
+		return nil
+	}
+	hash := crypto.Keccak256Hash(code)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.contracts[hash]
+}
+
+// Contracts returns the registered contracts, sorted by name.
+func (r *Registry) Contracts() []*Contract {
+	r.mu.RLock()
+	contracts := make([]*Contract, 0, len(r.contracts))
+	for _, c := range r.contracts {
+		contracts = append(contracts, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(contracts, func(i, j int) bool {
+		if contracts[i].Name != contracts[j].Name {
+			return contracts[i].Name < contracts[j].Name
+		}
+		return bytes.Compare(contracts[i].Code, contracts[j].Code) < 0
+	})
+	return contracts
+}
+
+// Len returns the number of registered contracts.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.contracts)
+}
+
+// combinedJSON is the output of solc --combined-json abi,bin-runtime,srcmap-runtime.
+type combinedJSON struct {
+	Contracts map[string]struct {
+		ABI        json.RawMessage `json:"abi"`
+		BinRuntime string          `json:"bin-runtime"`
+		SrcMap     string          `json:"srcmap-runtime"`
+	} `json:"contracts"`
+	SourceList []string `json:"sourceList"`
+}
+
+// LoadCombinedJSON registers the contracts of the output of
+//
+//	solc --combined-json abi,bin-runtime,srcmap-runtime
+//
+// The sources are read from the paths of its source list, relative to the
+// directory of the file; sources that can't be read have no lines.
+func (r *Registry) LoadCombinedJSON(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("sourcemap: %w", err)
+	}
+	var out combinedJSON
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("sourcemap: %s: %w", path, err)
+	}
+	sources := make([]Source, len(out.SourceList))
+	for i, name := range out.SourceList {
+		sources[i].Name = name
+		file := name
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		if content, err := os.ReadFile(file); err == nil {
+			sources[i].Content = string(content)
+		}
+	}
+	for id, contract := range out.Contracts {
+		code, err := hexutil.Decode("0x" + strings.TrimPrefix(contract.BinRuntime, "0x"))
+		if err != nil || len(code) == 0 {
+			continue // an interface or an abstract contract
+		}
+		abiJSON := contract.ABI
+		var s string
+		if json.Unmarshal(abiJSON, &s) == nil {
+			// older versions of solc encode the ABI as a string
+			abiJSON = json.RawMessage(s)
+		}
+		err = r.Add(&Artifact{
+			Name:      id[strings.LastIndex(id, ":")+1:],
+			ABI:       abiJSON,
+			Code:      code,
+			SourceMap: contract.SrcMap,
+			Sources:   append([]Source(nil), sources...),
+		})
+		if err != nil {
+			return fmt.Errorf("sourcemap: %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package sourcemap maps the bytecode of contracts back to their Solidity
+// sources with the source maps of the compiler, to describe executions at
+// the source level: stack traces of failed transactions, gas profiles and
+// coverage.
+package sourcemap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daweth/gevm/vm"
+)
+
+// Jump types of a source map entry.
+const (
+	JumpNone = '-' // a regular jump, or not a jump
+	JumpIn   = 'i' // into a function
+	JumpOut  = 'o' // out of a function
+)
+
+// Entry is the source range an instruction was compiled from.
+type Entry struct {
+	Offset int  // byte offset of the range in the source
+	Length int  // byte length of the range
+	File   int  // index of the source, -1 for code generated by the compiler
+	Jump   byte // JumpNone, JumpIn or JumpOut
+}
+
+// Parse decodes a source map in the compressed format of solc, with one entry
+// per instruction: "s:l:f:j;s:l:f:j;...", where an empty field, or entry,
+// repeats the one before.
+func Parse(sourceMap string) ([]Entry, error) {
+	if sourceMap == "" {
+		return nil, nil
+	}
+	var (
+		items   = strings.Split(sourceMap, ";")
+		entries = make([]Entry, len(items))
+		prev    = Entry{File: -1, Jump: JumpNone}
+	)
+	for i, item := range items {
+		entry := prev
+		for j, field := range strings.Split(item, ":") {
+			if field == "" {
+				continue
+			}
+			var err error
+			switch j {
+			case 0:
+				entry.Offset, err = strconv.Atoi(field)
+			case 1:
+				entry.Length, err = strconv.Atoi(field)
+			case 2:
+				entry.File, err = strconv.Atoi(field)
+			case 3:
+				entry.Jump = field[0]
+			}
+			if err != nil {
+				return nil, fmt.Errorf("sourcemap: entry %d: %w", i, err)
+			}
+		}
+		entries[i], prev = entry, entry
+	}
+	return entries, nil
+}
+
+// instructionIndexes returns the index of the instruction at each pc of code,
+// -1 for the immediate data of the PUSH opcodes.
+func instructionIndexes(code []byte) []int {
+	indexes := make([]int, len(code))
+	n := 0
+	for pc := 0; pc < len(code); pc++ {
+		indexes[pc] = n
+		n++
+		if op := vm.OpCode(code[pc]); op.IsPush() {
+			for i := 0; i < int(op-vm.PUSH1+1) && pc+1 < len(code); i++ {
+				pc++
+				indexes[pc] = -1
+			}
+		}
+	}
+	return indexes
+}
+
+// Source is a source file of a contract.
+type Source struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+
+	lines []int // offsets of the line starts
+}
+
+func (s *Source) indexLines() {
+	s.lines = []int{0}
+	for i := 0; i < len(s.Content); i++ {
+		if s.Content[i] == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+}
+
+// position returns the line and column, counted from 1, of an offset.
+func (s *Source) position(offset int) (line, column int) {
+	line = sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset })
+	return line, offset - s.lines[line-1] + 1
+}
+
+// Location is a position in a source file.
+type Location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
@@ -0,0 +1,179 @@
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daweth/gevm/internal/evmtest"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vaultSource = `contract Vault {
+    function withdraw(uint256 amount) external {
+        check(amount);
+    }
+
+    function check(uint256 amount) internal pure {
+        require(amount < 10);
+    }
+}
+`
+
+const vaultABI = `[{"type":"function","name":"withdraw","stateMutability":"nonpayable","outputs":[],
+	"inputs":[{"name":"amount","type":"uint256"}]}]`
+
+// vaultCode is Vault compiled by hand the way solc lays out functions: the
+// dispatcher jumps into withdraw, which jumps into check.
+var vaultCode = common.FromHex("0x" +
+	"6008" + "6004" + "35" + "600a" + "56" + // dispatcher: withdraw(calldata[4])
+	"5b" + "00" + // return from withdraw at pc 8
+	"5b" + "6011" + "81" + "6014" + "56" + // withdraw at pc 10: check(amount)
+	"5b" + "50" + "56" + // return from check at pc 17
+	"5b" + "600a" + "81" + "10" + "6020" + "57" + // check at pc 20: require(amount < 10)
+	"6000" + "80" + "fd" + // revert at pc 31
+	"5b" + "50" + "56") // pc 32
+
+// vaultSourceMap maps the instructions of vaultCode to vaultSource.
+func vaultSourceMap() string {
+	span := func(text, jump string) string {
+		start := strings.Index(vaultSource, text)
+		return fmt.Sprintf("%d:%d:0:%s", start, len(text), jump)
+	}
+	var (
+		gen      = "0:0:-1:-"
+		withdraw = vaultSource[strings.Index(vaultSource, "function withdraw"):strings.Index(vaultSource, "\n\n")]
+		call     = "check(amount)"
+		check    = vaultSource[strings.Index(vaultSource, "function check") : strings.LastIndex(vaultSource, "}\n}")+1]
+		cond     = "require(amount < 10)"
+	)
+	entries := []string{
+		gen, gen, gen, gen, span(withdraw, "i"), gen, gen,
+		span(withdraw, "-"), span(call, "-"), span(call, "-"), span(call, "-"), span(call, "i"), span(call, "-"), span(call, "-"), span(withdraw, "o"),
+		span(check, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(cond, "-"), span(check, "o"),
+	}
+	return strings.Join(entries, ";")
+}
+
+func vaultArtifact() *Artifact {
+	return &Artifact{
+		Name:      "Vault",
+		ABI:       json.RawMessage(vaultABI),
+		Code:      vaultCode,
+		SourceMap: vaultSourceMap(),
+		Sources:   []Source{{Name: "Vault.sol", Content: vaultSource}},
+	}
+}
+
+func TestParse(t *testing.T) {
+	entries, err := Parse("1:2:0:-;:3;;4::-1:i;:::o")
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Offset: 1, Length: 2, File: 0, Jump: JumpNone},
+		{Offset: 1, Length: 3, File: 0, Jump: JumpNone},
+		{Offset: 1, Length: 3, File: 0, Jump: JumpNone},
+		{Offset: 4, Length: 3, File: -1, Jump: JumpIn},
+		{Offset: 4, Length: 3, File: -1, Jump: JumpOut},
+	}, entries)
+
+	_, err = Parse("1:x")
+	assert.Error(t, err)
+}
+
+func TestContract(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Add(vaultArtifact()))
+	c := r.Lookup(vaultCode)
+	require.NotNil(t, c)
+	assert.Nil(t, r.Lookup([]byte{0}))
+	assert.Equal(t, []*Contract{c}, r.Contracts())
+
+	loc, ok := c.Location(31)
+	require.True(t, ok)
+	assert.Equal(t, Location{File: "Vault.sol", Line: 7, Column: 9}, loc)
+	_, ok = c.Location(0)
+	assert.False(t, ok, "generated code")
+	_, ok = c.Entry(1)
+	assert.False(t, ok, "push data")
+
+	assert.Equal(t, "withdraw", c.Function(10))
+	assert.Equal(t, "check", c.Function(20))
+	assert.Equal(t, "", c.Function(31))
+}
+
+// run calls vault with amount, with tracer attached.
+func run(t *testing.T, tracer vm.EVMLogger, amount int64) error {
+	vault := common.HexToAddress("0x7a017")
+	input := append(crypto.Keccak256([]byte("withdraw(uint256)"))[:4], common.BigToHash(big.NewInt(amount)).Bytes()...)
+	_, _, err := evmtest.Run(t, map[common.Address][]byte{vault: vaultCode}, tracer, vault, input)
+	return err
+}
+
+func TestStackTracer(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Add(vaultArtifact()))
+
+	tracer := NewStackTracer(r)
+	require.ErrorIs(t, run(t, tracer, 20), vm.ErrExecutionReverted)
+	trace := tracer.StackTrace()
+	require.Len(t, trace, 2)
+	assert.Equal(t, "check", trace[0].Function)
+	assert.Equal(t, &Location{File: "Vault.sol", Line: 7, Column: 9}, trace[0].Location)
+	assert.Equal(t, "withdraw", trace[1].Function)
+	assert.Equal(t, []string{"amount=20"}, trace[1].Args)
+	assert.Equal(t, &Location{File: "Vault.sol", Line: 3, Column: 9}, trace[1].Location)
+	assert.Equal(t, "Vault.check() at Vault.sol:7:9\nVault.withdraw(amount=20) at Vault.sol:3:9", trace.String())
+
+	require.NoError(t, run(t, tracer, 5))
+	assert.Nil(t, tracer.StackTrace())
+
+	// unknown code
+	tracer = NewStackTracer(NewRegistry())
+	require.Error(t, run(t, tracer, 20))
+	require.Len(t, tracer.StackTrace(), 1)
+	assert.Equal(t, uint64(31), tracer.StackTrace()[0].PC)
+}
+
+func TestLoadCombinedJSON(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Vault.sol"), []byte(vaultSource), 0o644))
+	out, err := json.Marshal(map[string]interface{}{
+		"contracts": map[string]interface{}{
+			"Vault.sol:Vault": map[string]interface{}{
+				"abi":            vaultABI, // as a string, like older versions of solc
+				"bin-runtime":    common.Bytes2Hex(vaultCode),
+				"srcmap-runtime": vaultSourceMap(),
+			},
+			"Vault.sol:IVault": map[string]interface{}{"abi": json.RawMessage("[]"), "bin-runtime": ""},
+		},
+		"sourceList": []string{"Vault.sol"},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(dir, "combined.json")
+	require.NoError(t, os.WriteFile(path, out, 0o644))
+
+	r, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.Len())
+	c := r.Lookup(vaultCode)
+	require.NotNil(t, c)
+	assert.Equal(t, "Vault", c.Name)
+	loc, ok := c.Location(31)
+	assert.True(t, ok)
+	assert.Equal(t, 7, loc.Line)
+	name, args, ok := c.Call(append(crypto.Keccak256([]byte("withdraw(uint256)"))[:4], common.BigToHash(big.NewInt(3)).Bytes()...))
+	assert.True(t, ok)
+	assert.Equal(t, "withdraw", name)
+	assert.Equal(t, []string{"amount=3"}, args)
+
+	_, err = Load(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
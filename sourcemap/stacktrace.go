@@ -0,0 +1,184 @@
+package sourcemap
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StackFrame is a function being executed when an execution failed.
+type StackFrame struct {
+	Address  common.Address `json:"address"`
+	PC       uint64         `json:"pc"`
+	Contract string         `json:"contract,omitempty"`
+	Function string         `json:"function,omitempty"`
+	Args     []string       `json:"args,omitempty"` // name=value, for the called method
+	Location *Location      `json:"location,omitempty"`
+}
+
+func (f StackFrame) String() string {
+	var b strings.Builder
+	switch {
+	case f.Contract != "" && f.Function != "":
+		fmt.Fprintf(&b, "%s.%s(%s)", f.Contract, f.Function, strings.Join(f.Args, ", "))
+	case f.Contract != "":
+		b.WriteString(f.Contract)
+	default:
+		b.WriteString(f.Address.Hex())
+	}
+	if f.Location != nil {
+		fmt.Fprintf(&b, " at %v", *f.Location)
+	} else {
+		fmt.Fprintf(&b, " at pc %d", f.PC)
+	}
+	return b.String()
+}
+
+// StackTrace is the stack of a failed execution, the failing function first.
+type StackTrace []StackFrame
+
+func (st StackTrace) String() string {
+	lines := make([]string, len(st))
+	for i, f := range st {
+		lines[i] = f.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// call is a call being executed, with the internal functions it is in.
+type call struct {
+	address   common.Address
+	input     []byte
+	contract  *Contract // nil if not registered
+	functions *Functions
+}
+
+// StackTracer is an EVM logger recording the stack trace of the failure of
+// the execution it is attached to, mapped to the Solidity sources of the
+// contracts in a registry. Calls are split into the internal functions they
+// are in with the jumps into and out of functions of the source maps.
+type StackTracer struct {
+	registry *Registry
+	env      *vm.EVM
+	calls    []*call
+	trace    StackTrace
+}
+
+// NewStackTracer returns a stack tracer mapping the contracts of registry.
+func NewStackTracer(registry *Registry) *StackTracer {
+	return &StackTracer{registry: registry}
+}
+
+// StackTrace returns the stack trace of the failure of the execution, nil if
+// it didn't fail.
+func (t *StackTracer) StackTrace() StackTrace {
+	return t.trace
+}
+
+func (t *StackTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *StackTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *StackTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.calls, t.trace = nil, nil
+	t.enter(to, create, input)
+}
+
+func (t *StackTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+func (t *StackTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.enter(to, typ == vm.CREATE || typ == vm.CREATE2, input)
+}
+
+func (t *StackTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	t.exit(err)
+}
+
+func (t *StackTracer) enter(to common.Address, create bool, input []byte) {
+	c := &call{address: to, input: common.CopyBytes(input)}
+	if !create {
+		// the initialization code of created contracts is not mapped
+		c.contract = t.registry.Lookup(t.env.StateDB.GetCode(to))
+	}
+	c.functions = NewFunctions(c.contract)
+	t.calls = append(t.calls, c)
+}
+
+// exit records the stack trace of the first call to fail, which is the one
+// the failure started in. A failure caught by a caller is forgotten when the
+// caller returns.
+func (t *StackTracer) exit(err error) {
+	if len(t.calls) == 0 {
+		return
+	}
+	if err == nil {
+		t.trace = nil
+	} else if t.trace == nil {
+		t.trace = t.stackTrace()
+	}
+	t.calls = t.calls[:len(t.calls)-1]
+}
+
+func (t *StackTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if len(t.calls) == 0 {
+		return
+	}
+	t.calls[len(t.calls)-1].functions.Step(pc, op)
+}
+
+func (t *StackTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// stackTrace returns the stack of the calls being executed.
+func (t *StackTracer) stackTrace() StackTrace {
+	var trace StackTrace
+	for i := len(t.calls) - 1; i >= 0; i-- {
+		trace = append(trace, t.calls[i].stackFrames()...)
+	}
+	return trace
+}
+
+// stackFrames returns the functions the call is in, the innermost first.
+func (c *call) stackFrames() []StackFrame {
+	frame := func(pc uint64, function string) StackFrame {
+		f := StackFrame{Address: c.address, PC: pc, Function: function}
+		if c.contract != nil {
+			f.Contract = c.contract.Name
+			if loc, ok := c.contract.Location(pc); ok {
+				f.Location = &loc
+			}
+		}
+		return f
+	}
+	var (
+		frames []StackFrame
+		pc     = c.functions.PC()
+		method string
+		args   []string
+		known  bool
+	)
+	if c.contract != nil {
+		method, args, known = c.contract.Call(c.input)
+	}
+	internal := c.functions.Calls
+	for i := len(internal) - 1; i >= 0; i-- {
+		in := internal[i]
+		f := frame(pc, in.Function)
+		if i == 0 && known && in.Function == method {
+			// the body of the called method, jumped into by the dispatcher
+			f.Args = args
+			return append(frames, f)
+		}
+		frames = append(frames, f)
+		pc = in.Site
+	}
+	f := frame(pc, method)
+	f.Args = args
+	return append(frames, f)
+}
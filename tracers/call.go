@@ -0,0 +1,187 @@
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/daweth/gevm/types"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func init() {
+	Register("callTracer", newCallTracer)
+}
+
+// CallFrame is a call of the tree built by the call tracer, in the format of
+// geth's callTracer. CodeHash, which geth doesn't have, is the hash of the code
+// the call ran, as of the traced transaction; it is left out for calls to
+// accounts without code and for failed creations.
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to,omitempty"`
+	CodeHash     *common.Hash    `json:"codeHash,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []CallFrame     `json:"calls,omitempty"`
+}
+
+func (f *CallFrame) processOutput(output []byte, err error) {
+	output = common.CopyBytes(output)
+	if err == nil {
+		f.Output = output
+		return
+	}
+	f.Error = err.Error()
+	if f.Type == vm.CREATE.String() || f.Type == vm.CREATE2.String() {
+		f.To = nil
+	}
+	if !errors.Is(err, vm.ErrExecutionReverted) || len(output) == 0 {
+		return
+	}
+	f.Output = output
+	if reason, err := abi.UnpackRevert(output); err == nil {
+		f.RevertReason = reason
+	}
+}
+
+// CallTracerConfig holds the options of the call tracer.
+type CallTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"` // leaves out the calls made by the called contract
+}
+
+// callTracer builds the tree of the calls made by a transaction from the
+// enter and exit events of the EVM, without tracing opcodes.
+type callTracer struct {
+	config   CallTracerConfig
+	env      *vm.EVM // the code hashes of the frames are read from its state
+	gasLimit uint64
+	stack    []CallFrame // the open calls, the top-level one first
+	err      error       // set if the tree is malformed
+}
+
+func newCallTracer(cfg json.RawMessage) (Tracer, error) {
+	var config CallTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &callTracer{config: config}, nil
+}
+
+func (t *callTracer) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+func (t *callTracer) CaptureTxEnd(restGas uint64) {
+	if len(t.stack) == 0 {
+		return
+	}
+	t.stack[0].GasUsed = hexutil.Uint64(t.gasLimit - restGas)
+}
+
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+	t.env = env
+	t.stack = []CallFrame{t.newCallFrame(typ, from, to, input, gas, value)}
+}
+
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if len(t.stack) == 0 {
+		return
+	}
+	t.stack[0].GasUsed = hexutil.Uint64(gasUsed)
+	t.stack[0].processOutput(output, err)
+	t.createdCode(&t.stack[0])
+}
+
+func (t *callTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.config.OnlyTopCall {
+		return
+	}
+	t.stack = append(t.stack, t.newCallFrame(typ, from, to, input, gas, value))
+}
+
+func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.config.OnlyTopCall {
+		return
+	}
+	size := len(t.stack)
+	if size <= 1 {
+		t.err = errors.New("call exit without enter")
+		return
+	}
+	call := t.stack[size-1]
+	t.stack = t.stack[:size-1]
+	call.GasUsed = hexutil.Uint64(gasUsed)
+	call.processOutput(output, err)
+	t.createdCode(&call)
+	t.stack[size-2].Calls = append(t.stack[size-2].Calls, call)
+}
+
+func (t *callTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (t *callTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// GetResult returns the top-level call, with the calls it made nested in it.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if len(t.stack) != 1 {
+		return nil, errors.New("incorrect number of top-level calls")
+	}
+	return json.Marshal(t.stack[0])
+}
+
+func (t *callTracer) newCallFrame(typ vm.OpCode, from, to common.Address, input []byte, gas uint64, value *big.Int) CallFrame {
+	frame := CallFrame{
+		Type:  typ.String(),
+		From:  from,
+		To:    &to,
+		Gas:   hexutil.Uint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	if typ != vm.CREATE && typ != vm.CREATE2 {
+		frame.CodeHash = t.codeHash(to)
+	}
+	return frame
+}
+
+// createdCode sets the code hash of a creation frame once the code is
+// deployed.
+func (t *callTracer) createdCode(f *CallFrame) {
+	if (f.Type == vm.CREATE.String() || f.Type == vm.CREATE2.String()) && f.To != nil {
+		f.CodeHash = t.codeHash(*f.To)
+	}
+}
+
+// codeHash returns the hash of the code at addr, nil if it has none.
+func (t *callTracer) codeHash(addr common.Address) *common.Hash {
+	if t.env == nil {
+		return nil
+	}
+	hash := t.env.StateDB.GetCodeHash(addr)
+	if hash == (common.Hash{}) || hash == types.EmptyCodeHash {
+		return nil
+	}
+	return &hash
+}
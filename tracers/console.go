@@ -0,0 +1,13 @@
+package tracers
+
+import (
+	"encoding/json"
+
+	"github.com/daweth/gevm/console"
+)
+
+func init() {
+	Register("consoleTracer", func(cfg json.RawMessage) (Tracer, error) {
+		return console.NewLogger(), nil
+	})
+}
@@ -0,0 +1,78 @@
+package tracers
+
+import (
+	"math/big"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// multiplexer forwards the events of the EVM to several loggers.
+type multiplexer []vm.EVMLogger
+
+// Multiplex returns a logger forwarding the events of the EVM to each of
+// loggers in order, skipping the nil ones. It returns nil if they are all
+// nil, and the logger itself if there is only one.
+func Multiplex(loggers ...vm.EVMLogger) vm.EVMLogger {
+	var m multiplexer
+	for _, l := range loggers {
+		if l != nil {
+			m = append(m, l)
+		}
+	}
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+	return m
+}
+
+func (m multiplexer) CaptureTxStart(gasLimit uint64) {
+	for _, l := range m {
+		l.CaptureTxStart(gasLimit)
+	}
+}
+
+func (m multiplexer) CaptureTxEnd(restGas uint64) {
+	for _, l := range m {
+		l.CaptureTxEnd(restGas)
+	}
+}
+
+func (m multiplexer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	for _, l := range m {
+		l.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (m multiplexer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	for _, l := range m {
+		l.CaptureEnd(output, gasUsed, err)
+	}
+}
+
+func (m multiplexer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	for _, l := range m {
+		l.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (m multiplexer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	for _, l := range m {
+		l.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (m multiplexer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	for _, l := range m {
+		l.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (m multiplexer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	for _, l := range m {
+		l.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
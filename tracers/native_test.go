@@ -0,0 +1,120 @@
+package tracers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daweth/gevm/internal/evmtest"
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	sender   = evmtest.Sender
+	counter  = common.HexToAddress("0xc0ffee")
+	caller   = common.HexToAddress("0xca11")
+	reverter = common.HexToAddress("0xbad")
+
+	// increments slot 0
+	counterCode = common.FromHex("0x60005460010160005500")
+	// calls counter with no value and all the gas left
+	callerCode = common.FromHex("0x6000600060006000600062c0ffee5af100")
+	// reverts with Error("nope")
+	reverterCode = common.FromHex("0x6064600c60003960646000fd" +
+		"08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000")
+)
+
+// run executes a call from sender to addr traced by tracer, the way the node
+// runs transactions.
+func run(t *testing.T, tracer Tracer, addr common.Address) {
+	statedb := evmtest.NewState(t, map[common.Address][]byte{
+		counter:  counterCode,
+		caller:   callerCode,
+		reverter: reverterCode,
+	})
+	statedb.SetState(counter, common.Hash{}, common.BytesToHash([]byte{41}))
+	evmtest.Call(statedb, tracer, addr, nil)
+}
+
+func result(t *testing.T, tracer Tracer, v any) {
+	raw, err := tracer.GetResult()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, v))
+}
+
+func TestCallTracer(t *testing.T) {
+	tracer, err := New(&Config{Tracer: "callTracer"})
+	require.NoError(t, err)
+	run(t, tracer, caller)
+
+	var frame CallFrame
+	result(t, tracer, &frame)
+	assert.Equal(t, "CALL", frame.Type)
+	assert.Equal(t, sender, frame.From)
+	assert.Equal(t, caller, *frame.To)
+	assert.Equal(t, uint64(100000), uint64(frame.Gas))
+	require.Len(t, frame.Calls, 1)
+	inner := frame.Calls[0]
+	assert.Equal(t, "CALL", inner.Type)
+	assert.Equal(t, caller, inner.From)
+	assert.Equal(t, counter, *inner.To)
+	assert.Equal(t, crypto.Keccak256Hash(counterCode), *inner.CodeHash, "the code the call ran")
+	assert.NotZero(t, inner.GasUsed)
+	assert.Less(t, uint64(inner.GasUsed), uint64(frame.GasUsed))
+
+	tracer, err = New(&Config{Tracer: "callTracer", TracerConfig: json.RawMessage(`{"onlyTopCall":true}`)})
+	require.NoError(t, err)
+	run(t, tracer, caller)
+	frame = CallFrame{}
+	result(t, tracer, &frame)
+	assert.Empty(t, frame.Calls)
+}
+
+func TestCallTracerRevertReason(t *testing.T) {
+	tracer, err := New(&Config{Tracer: "callTracer"})
+	require.NoError(t, err)
+	run(t, tracer, reverter)
+
+	var frame CallFrame
+	result(t, tracer, &frame)
+	assert.Equal(t, vm.ErrExecutionReverted.Error(), frame.Error)
+	assert.Equal(t, "nope", frame.RevertReason)
+	assert.NotEmpty(t, frame.Output)
+}
+
+func TestPrestateTracer(t *testing.T) {
+	tracer, err := New(&Config{Tracer: "prestateTracer"})
+	require.NoError(t, err)
+	run(t, tracer, caller)
+
+	var pre State
+	result(t, tracer, &pre)
+	require.Contains(t, pre, counter)
+	assert.Equal(t, common.BytesToHash([]byte{41}), pre[counter].Storage[common.Hash{}])
+	assert.Equal(t, counterCode, []byte(pre[counter].Code))
+	assert.Contains(t, pre, caller)
+	assert.Contains(t, pre, sender)
+}
+
+func TestPrestateTracerDiffMode(t *testing.T) {
+	tracer, err := New(&Config{Tracer: "prestateTracer", TracerConfig: json.RawMessage(`{"diffMode":true}`)})
+	require.NoError(t, err)
+	run(t, tracer, caller)
+
+	var diff struct{ Pre, Post State }
+	result(t, tracer, &diff)
+	assert.Equal(t, State{counter: {
+		Balance: diff.Pre[counter].Balance,
+		Code:    counterCode,
+		Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{41})},
+	}}, diff.Pre, "only the changed account is kept")
+	assert.Equal(t, State{counter: {
+		Storage: map[common.Hash]common.Hash{{}: common.BytesToHash([]byte{42})},
+	}}, diff.Post)
+}
@@ -0,0 +1,220 @@
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+
+	"github.com/daweth/gevm/vm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func init() {
+	Register("prestateTracer", newPrestateTracer)
+}
+
+// Account is the state of an account seen by the prestate tracer, with the
+// storage slots the transaction touched.
+type Account struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+func (a *Account) exists() bool {
+	return a.Nonce > 0 || len(a.Code) > 0 || len(a.Storage) > 0 || (a.Balance != nil && a.Balance.ToInt().Sign() != 0)
+}
+
+// State holds the accounts seen by the prestate tracer.
+type State map[common.Address]*Account
+
+// PrestateTracerConfig holds the options of the prestate tracer.
+type PrestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // returns the values before and after the transaction
+}
+
+// prestateTracer records the state of every account and storage slot a
+// transaction touches before it runs, and in diff mode what the transaction
+// changed of it.
+type prestateTracer struct {
+	config  PrestateTracerConfig
+	env     *vm.EVM
+	pre     State
+	post    State
+	created map[common.Address]bool
+	deleted map[common.Address]bool
+}
+
+func newPrestateTracer(cfg json.RawMessage) (Tracer, error) {
+	var config PrestateTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	return &prestateTracer{
+		config:  config,
+		pre:     make(State),
+		post:    make(State),
+		created: make(map[common.Address]bool),
+		deleted: make(map[common.Address]bool),
+	}, nil
+}
+
+func (t *prestateTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	t.lookupAccount(env.Context.Coinbase)
+	if create {
+		t.created[to] = true
+	}
+}
+
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (t *prestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil {
+		return
+	}
+	var (
+		stack     = scope.Stack
+		stackData = stack.Data()
+		stackLen  = len(stackData)
+		caller    = scope.Contract.Address()
+	)
+	switch {
+	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
+		t.lookupStorage(caller, common.Hash(stack.Back(0).Bytes32()))
+	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE):
+		t.lookupAccount(common.Address(stack.Back(0).Bytes20()))
+	case stackLen >= 1 && op == vm.SELFDESTRUCT:
+		addr := common.Address(stack.Back(0).Bytes20())
+		t.lookupAccount(addr)
+		t.deleted[caller] = true
+	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
+		t.lookupAccount(common.Address(stack.Back(1).Bytes20()))
+	case op == vm.CREATE:
+		addr := crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	case stackLen >= 4 && op == vm.CREATE2:
+		offset, size := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		init := scope.Memory.GetCopy(int64(offset), int64(size))
+		addr := crypto.CreateAddress2(caller, stack.Back(3).Bytes32(), crypto.Keccak256(init))
+		t.lookupAccount(addr)
+		t.created[addr] = true
+	}
+}
+
+func (t *prestateTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureTxEnd computes the changes of the transaction in diff mode: the
+// accounts it didn't change are dropped from pre, and post holds the changed
+// values only.
+func (t *prestateTracer) CaptureTxEnd(restGas uint64) {
+	if !t.config.DiffMode || t.env == nil {
+		return
+	}
+	statedb := t.env.StateDB
+	for addr, pre := range t.pre {
+		if t.deleted[addr] {
+			continue
+		}
+		var (
+			modified bool
+			post     = new(Account)
+			balance  = statedb.GetBalance(addr)
+			nonce    = statedb.GetNonce(addr)
+			code     = statedb.GetCode(addr)
+		)
+		if pre.Balance.ToInt().Cmp(balance) != 0 {
+			modified = true
+			post.Balance = (*hexutil.Big)(new(big.Int).Set(balance))
+		}
+		if pre.Nonce != nonce {
+			modified = true
+			post.Nonce = nonce
+		}
+		if !bytes.Equal(pre.Code, code) {
+			modified = true
+			post.Code = common.CopyBytes(code)
+		}
+		for slot, value := range pre.Storage {
+			// Empty and unchanged slots are left out of pre.
+			if value == (common.Hash{}) {
+				delete(pre.Storage, slot)
+			}
+			after := statedb.GetState(addr, slot)
+			if after == value {
+				delete(pre.Storage, slot)
+				continue
+			}
+			modified = true
+			if post.Storage == nil {
+				post.Storage = make(map[common.Hash]common.Hash)
+			}
+			if after != (common.Hash{}) {
+				post.Storage[slot] = after
+			}
+		}
+		if modified {
+			t.post[addr] = post
+		} else {
+			delete(t.pre, addr)
+		}
+	}
+	// Accounts created by the transaction had no state before it.
+	for addr := range t.created {
+		if pre, ok := t.pre[addr]; ok && !pre.exists() {
+			delete(t.pre, addr)
+		}
+	}
+}
+
+// GetResult returns the state before the transaction, or in diff mode an
+// object with the pre and post states.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.config.DiffMode {
+		return json.Marshal(struct {
+			Post State `json:"post"`
+			Pre  State `json:"pre"`
+		}{t.post, t.pre})
+	}
+	return json.Marshal(t.pre)
+}
+
+// lookupAccount records the state of addr the first time it is touched.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	statedb := t.env.StateDB
+	t.pre[addr] = &Account{
+		Balance: (*hexutil.Big)(new(big.Int).Set(statedb.GetBalance(addr))),
+		Nonce:   statedb.GetNonce(addr),
+		Code:    common.CopyBytes(statedb.GetCode(addr)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage records the value of a storage slot the first time it is
+// touched.
+func (t *prestateTracer) lookupStorage(addr common.Address, slot common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[slot]; ok {
+		return
+	}
+	t.pre[addr].Storage[slot] = t.env.StateDB.GetState(addr, slot)
+}
@@ -0,0 +1,72 @@
+// Package tracers creates the tracers attached to the EVM of a traced request,
+// selected by name like with the tracing options of geth's debug API.
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/daweth/gevm/logger"
+	"github.com/daweth/gevm/vm"
+)
+
+// Tracer is an EVM logger whose result is returned to the caller of a traced
+// request.
+type Tracer interface {
+	vm.EVMLogger
+	GetResult() (json.RawMessage, error)
+}
+
+// Config selects the tracer of a request. The options of the struct logger
+// are the ones of geth.
+type Config struct {
+	Tracer string `json:"tracer,omitempty"` // name of the tracer, the struct logger if empty
+
+	EnableMemory     bool `json:"enableMemory,omitempty"`
+	DisableStack     bool `json:"disableStack,omitempty"`
+	DisableStorage   bool `json:"disableStorage,omitempty"`
+	EnableReturnData bool `json:"enableReturnData,omitempty"`
+	Limit            int  `json:"limit,omitempty"` // maximum number of opcodes logged, zero means unlimited
+
+	TracerConfig json.RawMessage `json:"tracerConfig,omitempty"` // options of a named tracer
+}
+
+// Constructor creates a named tracer from its options.
+type Constructor func(cfg json.RawMessage) (Tracer, error)
+
+var (
+	mu           sync.RWMutex
+	constructors = make(map[string]Constructor)
+)
+
+// Register makes a tracer available under name.
+func Register(name string, ctor Constructor) {
+	mu.Lock()
+	defer mu.Unlock()
+	constructors[name] = ctor
+}
+
+// New creates the tracer selected by cfg, the struct logger with its default
+// options if cfg is nil.
+func New(cfg *Config) (Tracer, error) {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+	if cfg.Tracer == "" {
+		return logger.NewStructLogger(&logger.Config{
+			EnableMemory:     cfg.EnableMemory,
+			DisableStack:     cfg.DisableStack,
+			DisableStorage:   cfg.DisableStorage,
+			EnableReturnData: cfg.EnableReturnData,
+			Limit:            cfg.Limit,
+		}), nil
+	}
+	mu.RLock()
+	ctor, ok := constructors[cfg.Tracer]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tracer %q", cfg.Tracer)
+	}
+	return ctor(cfg.TracerConfig)
+}
@@ -0,0 +1,58 @@
+package tracers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daweth/gevm/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nop is a struct logger registered under a name.
+type nop struct{ *logger.StructLogger }
+
+func TestNew(t *testing.T) {
+	tracer, err := New(nil)
+	require.NoError(t, err)
+	assert.IsType(t, &logger.StructLogger{}, tracer)
+
+	_, err = New(&Config{Tracer: "nop"})
+	assert.Error(t, err)
+
+	var got json.RawMessage
+	Register("nop", func(cfg json.RawMessage) (Tracer, error) {
+		got = cfg
+		return nop{logger.NewStructLogger(nil)}, nil
+	})
+	tracer, err = New(&Config{Tracer: "nop", TracerConfig: json.RawMessage(`{"onlyTopCall":true}`)})
+	require.NoError(t, err)
+	assert.IsType(t, nop{}, tracer)
+	assert.JSONEq(t, `{"onlyTopCall":true}`, string(got))
+}
+
+// txCounter counts the transactions it is told about.
+type txCounter struct {
+	*logger.StructLogger
+	txs int
+}
+
+func (c *txCounter) CaptureTxStart(gasLimit uint64) { c.txs++ }
+
+func TestMultiplex(t *testing.T) {
+	assert.Nil(t, Multiplex(nil, nil))
+	a, b := &txCounter{StructLogger: logger.NewStructLogger(nil)}, &txCounter{StructLogger: logger.NewStructLogger(nil)}
+	assert.Same(t, a, Multiplex(nil, a))
+
+	Multiplex(a, nil, b).CaptureTxStart(21000)
+	assert.Equal(t, 1, a.txs)
+	assert.Equal(t, 1, b.txs)
+}
+
+func TestConsoleTracer(t *testing.T) {
+	tracer, err := New(&Config{Tracer: "consoleTracer"})
+	require.NoError(t, err)
+	result, err := tracer.GetResult()
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(result))
+}
@@ -14,7 +14,7 @@ func ConvertGConfigToConfig(a gvm.Config, lc glogger.Config) vm.Config {
 	logConfig := logger.Config{
 		EnableMemory:     lc.EnableMemory,
 		DisableStack:     lc.DisableStack,
-		DisableStorage:   lc.DisableStack,
+		DisableStorage:   lc.DisableStorage,
 		EnableReturnData: lc.EnableReturnData,
 		Debug:            lc.Debug,
 		Limit:            lc.Limit,
@@ -37,7 +37,6 @@ import (
 	"github.com/curio-research/keystone/server"
 	// "github.com/curio-research/keystone/state"
 
-	"github.com/curio-research/keystone-starter-kit/server/helper"
 )
 
 // PrecompiledContract is the basic interface for native Go contracts. The implementation
@@ -48,6 +47,22 @@ type PrecompiledContract interface {
 	Run(input []byte) ([]byte, error) // Run runs the precompiled contract
 }
 
+// StatefulPrecompiledContract is a native Go contract that needs the executing
+// EVM, e.g. to access chain state or to know its caller. RequiredGas is
+// charged before RunStateful as for any other precompile.
+type StatefulPrecompiledContract interface {
+	PrecompiledContract
+	RunStateful(evm *EVM, caller common.Address, input []byte, readOnly bool) ([]byte, error) // RunStateful runs the contract within the EVM
+}
+
+// EnginePrecompiledContract is a game precompile reading a Keystone world. It
+// is run with the engine of the executing EVM, see Config.Engine, so that
+// chains bound to different worlds can share it.
+type EnginePrecompiledContract interface {
+	PrecompiledContract
+	RunEngine(engine *server.EngineCtx, input []byte) ([]byte, error) // RunEngine runs the contract against the given engine
+}
+
 // PrecompiledContractsHomestead contains the default set of pre-compiled Ethereum
 // contracts used in the Frontier and Homestead releases.
 var PrecompiledContractsHomestead = map[common.Address]PrecompiledContract{
@@ -97,6 +112,7 @@ var PrecompiledContractsBerlin = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}):    &bn256PairingIstanbul{},
 	common.BytesToAddress([]byte{9}):    &blake2F{},
 	common.BytesToAddress([]byte{0x0b}): &gameWeather{},
+	OracleAddress:                       &gameOracle{},
 }
 
 // PrecompiledContractsCancun contains the default set of pre-compiled Ethereum
@@ -154,6 +170,15 @@ func init() {
 	}
 }
 
+// RegisterPrecompile adds a game precompile at addr, such as one generated by
+// cmd/precompilegen. It must be called before any EVM runs.
+func RegisterPrecompile(addr common.Address, p PrecompiledContract) {
+	if _, ok := PrecompiledContractsBerlin[addr]; !ok {
+		PrecompiledAddressesBerlin = append(PrecompiledAddressesBerlin, addr)
+	}
+	PrecompiledContractsBerlin[addr] = p
+}
+
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
 	switch {
@@ -185,36 +210,80 @@ func RunPrecompiledContract(p PrecompiledContract, input []byte, suppliedGas uin
 	return output, suppliedGas, err
 }
 
+// runPrecompiledContract runs the precompile at addr on behalf of caller.
+// Stateful precompiles are handed the EVM, and the outputs of external ones go
+// through the witness when one is configured. readOnly is set in static call
+// contexts.
+func (evm *EVM) runPrecompiledContract(p PrecompiledContract, addr, caller common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	gasCost := p.RequiredGas(input)
+	if suppliedGas < gasCost {
+		return nil, 0, ErrOutOfGas
+	}
+	suppliedGas -= gasCost
+
+	run := p.Run
+	if ep, ok := p.(EnginePrecompiledContract); ok {
+		run = func(input []byte) ([]byte, error) { return ep.RunEngine(evm.engine(), input) }
+	}
+	var output []byte
+	switch p := p.(type) {
+	case StatefulPrecompiledContract:
+		output, err = p.RunStateful(evm, caller, input, readOnly)
+	case ExternalPrecompiledContract:
+		if evm.Config.Witness != nil {
+			output, err = evm.Config.Witness.run(run, addr, input)
+		} else {
+			output, err = run(input)
+		}
+	default:
+		output, err = run(input)
+	}
+	return output, suppliedGas, err
+}
+
 var (
 	errConstInvalidInputLength = errors.New("invalid input length")
 )
 
+// gameWeather reads the latest weather written to the game oracle, as of the
+// current block, as a 32-byte word. It is the same value as get(WeatherKey) of
+// the oracle, and re-executing a transaction reads what it read originally.
 type gameWeather struct{}
 
 func (g *gameWeather) RequiredGas(input []byte) uint64 {
-	// same as 'identity'
-	return uint64(15)
+	// same as a read of the oracle
+	return 4 * params.ColdSloadCostEIP2929
 }
 
 // type GameState *state.IWorld
 
 var gameState *server.EngineCtx
 
+// InitializeEngine sets the game engine read by the game precompiles of the
+// EVMs that are not bound to their own, see Config.Engine.
 func InitializeEngine(w *server.EngineCtx) {
 	gameState=w
 }
 
+// engine returns the game engine of the EVM.
+func (evm *EVM) engine() *server.EngineCtx {
+	if evm.Config.Engine != nil {
+		return evm.Config.Engine
+	}
+	return gameState
+}
+
 func (g *gameWeather) Run(input []byte) ([]byte, error) {
+	return nil, errOracleStateless
+}
+
+// RunStateful reads the weather from the storage of the oracle.
+func (g *gameWeather) RunStateful(evm *EVM, caller common.Address, input []byte, readOnly bool) ([]byte, error) {
 	if len(input) > 4 {
 		return nil, errConstInvalidInputLength
 	}
-	weatherInt := helper.GetWeather(gameState)
-	// insert logic for game engine
-	// w := data.Game.Get(gameEngine.World, 200).Weather
-	output := make([]byte, 32)         // create a 32-byte slice filled with zeroes
-	output[31] = byte(weatherInt) // set the last byte to the uint8 value 1
-
-	return output, nil
+	weather, _, _ := ReadOracle(evm.StateDB, WeatherKey, evm.Context.BlockNumber.Uint64())
+	return weather.Bytes(), nil
 }
 
 // ECRECOVER implemented as a native contract.
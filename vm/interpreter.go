@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"github.com/curio-research/keystone/server"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -29,6 +30,15 @@ type Config struct {
 	NoBaseFee               bool      // Forces the EIP-1559 baseFee to 0 (needed for 0 price calls)
 	EnablePreimageRecording bool      // Enables recording of SHA3/keccak preimages
 	ExtraEips               []int     // Additional EIPS that are to be enabled
+	Witness                 *Witness  // Records or replays the outputs of external precompiles
+
+	// Engine is the Keystone world read by the game precompiles, the one
+	// given to InitializeEngine if nil.
+	Engine *server.EngineCtx
+	// Precompiles are precompiles of this EVM only, such as game precompiles
+	// bound to the world of one chain. They take precedence over the
+	// registered ones.
+	Precompiles map[common.Address]PrecompiledContract
 }
 
 // ScopeContext contains the things that are per-call, such as stack and memory,
@@ -0,0 +1,207 @@
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SystemAddress is the sender of the calls the node makes on its own behalf.
+// Contracts, and precompiles such as the game oracle, may restrict privileged
+// functions to it.
+var SystemAddress = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+
+// OracleAddress is the address of the game oracle precompile. The oracle keeps
+// every value it was given, together with the block it was written in, in the
+// storage of this account.
+var OracleAddress = common.BytesToAddress([]byte{0x0c})
+
+// WeatherKey is the oracle key of the game weather.
+var WeatherKey = OracleKey("weather")
+
+// OracleKey returns the oracle key for a named game value, keccak256(name).
+func OracleKey(name string) common.Hash {
+	return crypto.Keccak256Hash([]byte(name))
+}
+
+var (
+	oracleGet        = crypto.Keccak256([]byte("get(bytes32)"))[:4]
+	oracleGetAt      = crypto.Keccak256([]byte("getAt(bytes32,uint256)"))[:4]
+	oracleSet        = crypto.Keccak256([]byte("set(bytes32,uint256)"))[:4]
+	oracleSetUpdater = crypto.Keccak256([]byte("setUpdater(address,bool)"))[:4]
+
+	errOracleUnauthorized = errors.New("oracle: caller is not an authorized updater")
+	errOracleStateless    = errors.New("oracle: must be run by the EVM")
+)
+
+// PackOracleGet encodes a call reading the latest value of key.
+func PackOracleGet(key common.Hash) []byte {
+	return append(common.CopyBytes(oracleGet), key.Bytes()...)
+}
+
+// PackOracleGetAt encodes a call reading the value key had at the given block.
+func PackOracleGetAt(key common.Hash, block uint64) []byte {
+	input := append(common.CopyBytes(oracleGetAt), key.Bytes()...)
+	return append(input, common.BigToHash(new(big.Int).SetUint64(block)).Bytes()...)
+}
+
+// PackOracleSet encodes a call writing value under key.
+func PackOracleSet(key common.Hash, value *big.Int) []byte {
+	input := append(common.CopyBytes(oracleSet), key.Bytes()...)
+	return append(input, common.BigToHash(value).Bytes()...)
+}
+
+// PackOracleSetUpdater encodes a call granting or revoking write access.
+func PackOracleSetUpdater(updater common.Address, allowed bool) []byte {
+	input := append(common.CopyBytes(oracleSetUpdater), common.BytesToHash(updater.Bytes()).Bytes()...)
+	var flag common.Hash
+	if allowed {
+		flag[31] = 1
+	}
+	return append(input, flag.Bytes()...)
+}
+
+// oracle storage layout, for a key k:
+//
+//	keccak(k)              number of entries
+//	keccak(k, i, 0)        block number of entry i
+//	keccak(k, i, 1)        value of entry i
+//	keccak("updater", a)   1 if a may write values
+func oracleCountSlot(key common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes())
+}
+
+func oracleEntrySlot(key common.Hash, i uint64, field byte) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), common.BigToHash(new(big.Int).SetUint64(i)).Bytes(), []byte{field})
+}
+
+func oracleUpdaterSlot(updater common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte("updater"), updater.Bytes())
+}
+
+// ReadOracle returns the value key had at the given block, and the block it
+// was written in. ok is false if no value was written at or before the block.
+func ReadOracle(db StateDB, key common.Hash, block uint64) (value common.Hash, at uint64, ok bool) {
+	count := db.GetState(OracleAddress, oracleCountSlot(key)).Big().Uint64()
+	entryBlock := func(i int) uint64 {
+		return db.GetState(OracleAddress, oracleEntrySlot(key, uint64(i), 0)).Big().Uint64()
+	}
+	// entries are appended in block order, find the first one past the block
+	i := sort.Search(int(count), func(i int) bool { return entryBlock(i) > block })
+	if i == 0 {
+		return common.Hash{}, 0, false
+	}
+	return db.GetState(OracleAddress, oracleEntrySlot(key, uint64(i-1), 1)), entryBlock(i - 1), true
+}
+
+// writeOracle records value under key at the given block. A second write in
+// the same block replaces the first.
+func writeOracle(db StateDB, key common.Hash, block uint64, value common.Hash) {
+	// keep the account from being considered empty
+	if db.GetNonce(OracleAddress) == 0 {
+		db.SetNonce(OracleAddress, 1)
+	}
+	count := db.GetState(OracleAddress, oracleCountSlot(key)).Big().Uint64()
+	if count > 0 && db.GetState(OracleAddress, oracleEntrySlot(key, count-1, 0)).Big().Uint64() == block {
+		db.SetState(OracleAddress, oracleEntrySlot(key, count-1, 1), value)
+		return
+	}
+	db.SetState(OracleAddress, oracleEntrySlot(key, count, 0), common.BigToHash(new(big.Int).SetUint64(block)))
+	db.SetState(OracleAddress, oracleEntrySlot(key, count, 1), value)
+	db.SetState(OracleAddress, oracleCountSlot(key), common.BigToHash(new(big.Int).SetUint64(count+1)))
+}
+
+// IsOracleUpdater reports whether updater may write oracle values.
+func IsOracleUpdater(db StateDB, updater common.Address) bool {
+	return db.GetState(OracleAddress, oracleUpdaterSlot(updater)) != (common.Hash{})
+}
+
+// gameOracle serves game values, such as the weather, that authorized updaters
+// write into chain state. Values can be read as of the current block or any
+// earlier one, so re-executing a transaction reads what it read originally.
+//
+//	get(bytes32 key) returns (uint256 value, uint256 writtenAt)
+//	getAt(bytes32 key, uint256 block) returns (uint256 value, uint256 writtenAt)
+//	set(bytes32 key, uint256 value)             SystemAddress and authorized updaters only
+//	setUpdater(address updater, bool allowed)   SystemAddress only
+type gameOracle struct{}
+
+func (g *gameOracle) RequiredGas(input []byte) uint64 {
+	if len(input) < 4 {
+		return 0
+	}
+	switch string(input[:4]) {
+	case string(oracleSet):
+		return 3 * params.SstoreSetGasEIP2200
+	case string(oracleSetUpdater):
+		return params.SstoreSetGasEIP2200
+	default:
+		// the history lookup reads a handful of slots
+		return 4 * params.ColdSloadCostEIP2929
+	}
+}
+
+func (g *gameOracle) Run(input []byte) ([]byte, error) {
+	return nil, errOracleStateless
+}
+
+func (g *gameOracle) RunStateful(evm *EVM, caller common.Address, input []byte, readOnly bool) ([]byte, error) {
+	if len(input) < 4 {
+		return nil, errConstInvalidInputLength
+	}
+	current := evm.Context.BlockNumber.Uint64()
+	selector, args := input[:4], input[4:]
+
+	switch string(selector) {
+	case string(oracleGet), string(oracleGetAt):
+		if len(args) < 32 {
+			return nil, errConstInvalidInputLength
+		}
+		block := current
+		if string(selector) == string(oracleGetAt) {
+			if len(args) < 64 {
+				return nil, errConstInvalidInputLength
+			}
+			// values can't be read from the future
+			if at := new(big.Int).SetBytes(args[32:64]); at.IsUint64() && at.Uint64() < current {
+				block = at.Uint64()
+			}
+		}
+		value, at, _ := ReadOracle(evm.StateDB, common.BytesToHash(args[:32]), block)
+		return append(value.Bytes(), common.BigToHash(new(big.Int).SetUint64(at)).Bytes()...), nil
+
+	case string(oracleSet):
+		if len(args) < 64 {
+			return nil, errConstInvalidInputLength
+		}
+		if readOnly {
+			return nil, ErrWriteProtection
+		}
+		if caller != SystemAddress && !IsOracleUpdater(evm.StateDB, caller) {
+			return nil, errOracleUnauthorized
+		}
+		writeOracle(evm.StateDB, common.BytesToHash(args[:32]), current, common.BytesToHash(args[32:64]))
+		return nil, nil
+
+	case string(oracleSetUpdater):
+		if len(args) < 64 {
+			return nil, errConstInvalidInputLength
+		}
+		if readOnly {
+			return nil, ErrWriteProtection
+		}
+		if caller != SystemAddress {
+			return nil, errOracleUnauthorized
+		}
+		if evm.StateDB.GetNonce(OracleAddress) == 0 {
+			evm.StateDB.SetNonce(OracleAddress, 1)
+		}
+		evm.StateDB.SetState(OracleAddress, oracleUpdaterSlot(common.BytesToAddress(args[:32])), common.BytesToHash(args[32:64]))
+		return nil, nil
+	}
+	return nil, errConstInvalidInputLength
+}
@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestGameOracleHistory(t *testing.T) {
+	var (
+		statedb, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		updater    = common.HexToAddress("0x1234")
+		key        = OracleKey("season")
+	)
+	evmAt := func(block int64) *EVM {
+		ctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(block),
+		}
+		return NewEVM(ctx, TxContext{}, statedb, params.TestChainConfig, Config{})
+	}
+	call := func(evm *EVM, from common.Address, input []byte) ([]byte, error) {
+		ret, _, err := evm.Call(AccountRef(from), OracleAddress, input, 1000000, new(big.Int))
+		return ret, err
+	}
+
+	if _, err := call(evmAt(10), updater, PackOracleSet(key, big.NewInt(1))); err != errOracleUnauthorized {
+		t.Fatalf("unauthorized set: have %v, want %v", err, errOracleUnauthorized)
+	}
+	if _, err := call(evmAt(9), SystemAddress, PackOracleSet(OracleKey("other"), big.NewInt(1))); err != nil {
+		t.Fatalf("system set: %v", err)
+	}
+	if _, err := call(evmAt(10), SystemAddress, PackOracleSetUpdater(updater, true)); err != nil {
+		t.Fatalf("setUpdater: %v", err)
+	}
+	for _, w := range []struct{ block, value int64 }{{10, 1}, {12, 2}, {12, 3}} {
+		if _, err := call(evmAt(w.block), updater, PackOracleSet(key, big.NewInt(w.value))); err != nil {
+			t.Fatalf("set at block %d: %v", w.block, err)
+		}
+	}
+	if _, _, err := evmAt(13).StaticCall(AccountRef(updater), OracleAddress, PackOracleSet(key, big.NewInt(4)), 1000000); err != ErrWriteProtection {
+		t.Fatalf("static set: have %v, want %v", err, ErrWriteProtection)
+	}
+
+	tests := []struct {
+		input       []byte
+		block       int64
+		value, from uint64
+	}{
+		{PackOracleGetAt(key, 9), 20, 0, 0},
+		{PackOracleGetAt(key, 11), 20, 1, 10},
+		{PackOracleGetAt(key, 12), 20, 3, 12},
+		{PackOracleGetAt(key, 30), 11, 1, 10}, // the future is capped at the current block
+		{PackOracleGet(key), 20, 3, 12},
+	}
+	for i, test := range tests {
+		ret, err := call(evmAt(test.block), updater, test.input)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if len(ret) != 64 {
+			t.Fatalf("test %d: output length %d", i, len(ret))
+		}
+		if v := new(big.Int).SetBytes(ret[:32]).Uint64(); v != test.value {
+			t.Errorf("test %d: value %d, want %d", i, v, test.value)
+		}
+		if at := new(big.Int).SetBytes(ret[32:]).Uint64(); at != test.from {
+			t.Errorf("test %d: written at %d, want %d", i, at, test.from)
+		}
+	}
+}
+
+func TestGameWeatherReadsOracle(t *testing.T) {
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	weather := common.BytesToAddress([]byte{0x0b})
+	evmAt := func(block int64) *EVM {
+		ctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(block),
+		}
+		return NewEVM(ctx, TxContext{}, statedb, params.TestChainConfig, Config{})
+	}
+	read := func(block int64) uint64 {
+		ret, _, err := evmAt(block).StaticCall(AccountRef(common.Address{}), weather, nil, 100000)
+		if err != nil {
+			t.Fatalf("read at block %d: %v", block, err)
+		}
+		if len(ret) != 32 {
+			t.Fatalf("output length %d", len(ret))
+		}
+		return new(big.Int).SetBytes(ret).Uint64()
+	}
+
+	if w := read(5); w != 0 {
+		t.Errorf("weather before any write %d, want 0", w)
+	}
+	writeOracle(statedb, WeatherKey, 10, common.BigToHash(big.NewInt(2)))
+	writeOracle(statedb, WeatherKey, 12, common.BigToHash(big.NewInt(3)))
+	for _, test := range []struct {
+		block int64
+		want  uint64
+	}{{9, 0}, {10, 2}, {11, 2}, {12, 3}} {
+		if w := read(test.block); w != test.want {
+			t.Errorf("weather at block %d: %d, want %d", test.block, w, test.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ExternalPrecompiledContract is a precompile whose output depends on state
+// outside of the chain, such as the Keystone game world. Executing it twice
+// can give different results, so its outputs are recorded in a Witness.
+type ExternalPrecompiledContract interface {
+	PrecompiledContract
+	ReadsExternalState()
+}
+
+// ErrWitnessMismatch is returned when a replayed execution calls an external
+// precompile that the recorded execution did not call.
+var ErrWitnessMismatch = errors.New("witness mismatch")
+
+// WitnessEntry is a recorded call to an external precompile.
+type WitnessEntry struct {
+	TxIndex int            `json:"txIndex"`         // Transaction of the block that made the call
+	Address common.Address `json:"address"`         // Precompile that was called
+	Input   []byte         `json:"input"`           // Call input
+	Output  []byte         `json:"output"`          // Call output
+	Err     string         `json:"error,omitempty"` // Call error, if the call failed
+}
+
+// Witness holds the outputs of the external precompiles called in a block. A
+// recording witness runs the precompiles and records what they returned; a
+// replaying witness returns the recorded outputs instead, so that tracing,
+// debug re-execution and follower nodes see what the original execution saw.
+//
+// Witness is not thread safe, like the EVM using it.
+type Witness struct {
+	Entries []WitnessEntry
+
+	replay bool
+	tx     int // transaction being executed
+	next   int // next entry to replay
+}
+
+// NewWitness returns a witness that records external precompile calls.
+func NewWitness() *Witness {
+	return &Witness{}
+}
+
+// NewReplayWitness returns a witness that replays the recorded entries.
+func NewReplayWitness(entries []WitnessEntry) *Witness {
+	return &Witness{Entries: entries, replay: true}
+}
+
+// Replaying reports whether the witness replays recorded outputs.
+func (w *Witness) Replaying() bool {
+	return w.replay
+}
+
+// SetTx positions the witness at the start of the transaction with the given
+// index in the block.
+func (w *Witness) SetTx(index int) {
+	w.tx = index
+	if w.replay {
+		w.next = sort.Search(len(w.Entries), func(i int) bool { return w.Entries[i].TxIndex >= index })
+	}
+}
+
+// run executes the precompile at addr, whose Run is exec, through the
+// witness.
+func (w *Witness) run(exec func([]byte) ([]byte, error), addr common.Address, input []byte) ([]byte, error) {
+	if w.replay {
+		if w.next >= len(w.Entries) {
+			return nil, fmt.Errorf("%w: unexpected call to %v", ErrWitnessMismatch, addr)
+		}
+		entry := w.Entries[w.next]
+		if entry.TxIndex != w.tx || entry.Address != addr || !bytes.Equal(entry.Input, input) {
+			return nil, fmt.Errorf("%w: call to %v does not match recorded call to %v", ErrWitnessMismatch, addr, entry.Address)
+		}
+		w.next++
+		if entry.Err != "" {
+			return nil, errors.New(entry.Err)
+		}
+		return common.CopyBytes(entry.Output), nil
+	}
+	output, err := exec(input)
+	entry := WitnessEntry{
+		TxIndex: w.tx,
+		Address: addr,
+		Input:   common.CopyBytes(input),
+		Output:  common.CopyBytes(output),
+	}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	w.Entries = append(w.Entries, entry)
+	return output, err
+}
@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ticker returns a different output on every call, like a game clock.
+type ticker struct{ n byte }
+
+func (t *ticker) ReadsExternalState()              {}
+func (t *ticker) RequiredGas(input []byte) uint64 { return 10 }
+func (t *ticker) Run(input []byte) ([]byte, error) {
+	t.n++
+	return []byte{t.n}, nil
+}
+
+func TestWitnessRecordAndReplay(t *testing.T) {
+	addr := common.BytesToAddress([]byte{0xff})
+	PrecompiledContractsBerlin[addr] = &ticker{}
+	defer delete(PrecompiledContractsBerlin, addr)
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	newEVM := func(w *Witness) *EVM {
+		ctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(1),
+		}
+		return NewEVM(ctx, TxContext{}, statedb, params.TestChainConfig, Config{Witness: w})
+	}
+	call := func(evm *EVM, input []byte) []byte {
+		ret, _, err := evm.Call(AccountRef(common.Address{}), addr, input, 100000, new(big.Int))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+
+	recorder := NewWitness()
+	evm := newEVM(recorder)
+	var recorded [][]byte
+	for tx := 0; tx < 3; tx++ {
+		recorder.SetTx(tx)
+		recorded = append(recorded, call(evm, []byte{byte(tx)}))
+	}
+	if len(recorder.Entries) != 3 {
+		t.Fatalf("recorded %d entries, want 3", len(recorder.Entries))
+	}
+
+	// replaying a single transaction returns what it returned originally,
+	// even though the precompile itself has moved on
+	replay := NewReplayWitness(recorder.Entries)
+	evm = newEVM(replay)
+	replay.SetTx(1)
+	if have := call(evm, []byte{1}); !bytes.Equal(have, recorded[1]) {
+		t.Errorf("replayed output %x, want %x", have, recorded[1])
+	}
+
+	// a call the original execution did not make is rejected
+	replay.SetTx(2)
+	if _, _, err := evm.Call(AccountRef(common.Address{}), addr, []byte{9}, 100000, new(big.Int)); !errors.Is(err, ErrWitnessMismatch) {
+		t.Errorf("mismatched call: have %v, want %v", err, ErrWitnessMismatch)
+	}
+}